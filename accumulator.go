@@ -0,0 +1,141 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ResultAccumulator collects the samples of a run's workers. Each worker
+// records into a WorkerSamples buffer of its own, so recording never contends
+// and nothing needs to know up front how many queries a worker will run; the
+// buffers are merged in worker order once the workers have returned, or have
+// been abandoned by the watchdog.
+type ResultAccumulator struct {
+	mu      sync.Mutex
+	workers map[int]*WorkerSamples
+}
+
+// NewResultAccumulator returns an empty accumulator
+func NewResultAccumulator() *ResultAccumulator {
+	return &ResultAccumulator{workers: make(map[int]*WorkerSamples)}
+}
+
+// Worker returns workerID's buffer, creating it on first use. It takes the
+// accumulator's lock, so a worker should fetch its buffer once and keep it.
+func (a *ResultAccumulator) Worker(workerID int) *WorkerSamples {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w, ok := a.workers[workerID]
+	if !ok {
+		w = &WorkerSamples{errors: make(map[ErrorCategory]int)}
+		a.workers[workerID] = w
+	}
+	return w
+}
+
+// WorkerSamples buffers one worker's samples. Only its worker records into
+// it. Its lock is only contended by a merge while a worker abandoned by the
+// watchdog is still running; once merged, further samples are dropped.
+type WorkerSamples struct {
+	mu          sync.Mutex
+	merged      bool
+	times       []time.Duration // Each query's time, 0 for a failure
+	completions []time.Duration // Completion offset of each times entry
+	queueWaits  []time.Duration
+	queryTimes  []time.Duration // Per-query, batch or transaction times within each acquisition
+	errors      map[ErrorCategory]int
+	lastSuccess time.Duration // Completion offset of the last successful query
+}
+
+// Record adds a query's time, 0 if it failed, and its completion offset from
+// the run start
+func (w *WorkerSamples) Record(queryTime, completedAt time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.merged {
+		return
+	}
+	w.times = append(w.times, queryTime)
+	w.completions = append(w.completions, completedAt)
+	if queryTime > 0 {
+		w.lastSuccess = completedAt
+	}
+}
+
+// RecordQueueWait adds how long a rate-limited query waited past its scheduled start
+func (w *WorkerSamples) RecordQueueWait(wait time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.merged {
+		return
+	}
+	w.queueWaits = append(w.queueWaits, wait)
+}
+
+// RecordQueryTimes adds the times of the queries run within one acquisition
+func (w *WorkerSamples) RecordQueryTimes(times []time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.merged {
+		return
+	}
+	w.queryTimes = append(w.queryTimes, times...)
+}
+
+// RecordError counts a failed query
+func (w *WorkerSamples) RecordError(category ErrorCategory) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.merged {
+		return
+	}
+	w.errors[category]++
+}
+
+// MergedSamples are the samples of a run's workers, flattened in worker order
+type MergedSamples struct {
+	Times       []time.Duration // Each query's time, 0 for a failure
+	WorkerIDs   []int           // Worker that ran each Times entry
+	Completions []time.Duration // Completion offset of each Times entry
+	QueueWaits  []time.Duration
+	QueryTimes  []time.Duration
+	Errors      map[ErrorCategory]int
+	PerWorker   [][]time.Duration // Query times by worker id
+	LastSuccess []time.Duration   // Completion offset of each worker's last successful query, 0 if none
+}
+
+// Merge flattens the buffers of workers 0 through workers-1; a worker that
+// recorded nothing contributes empty entries. Workers should have returned: one
+// still running contributes what it recorded so far and nothing after.
+func (a *ResultAccumulator) Merge(workers int) MergedSamples {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	merged := MergedSamples{
+		Errors:      make(map[ErrorCategory]int),
+		PerWorker:   make([][]time.Duration, workers),
+		LastSuccess: make([]time.Duration, workers),
+	}
+	for workerID := 0; workerID < workers; workerID++ {
+		w, ok := a.workers[workerID]
+		if !ok {
+			continue
+		}
+		w.mu.Lock()
+		w.merged = true
+		merged.Times = append(merged.Times, w.times...)
+		for range w.times {
+			merged.WorkerIDs = append(merged.WorkerIDs, workerID)
+		}
+		merged.Completions = append(merged.Completions, w.completions...)
+		merged.QueueWaits = append(merged.QueueWaits, w.queueWaits...)
+		merged.QueryTimes = append(merged.QueryTimes, w.queryTimes...)
+		for category, n := range w.errors {
+			merged.Errors[category] += n
+		}
+		merged.PerWorker[workerID] = w.times
+		merged.LastSuccess[workerID] = w.lastSuccess
+		w.mu.Unlock()
+	}
+	return merged
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResultAccumulatorMergesInWorkerOrder(t *testing.T) {
+	const ms = time.Millisecond
+	acc := NewResultAccumulator()
+
+	// Workers record concurrently and in no particular order
+	var wg sync.WaitGroup
+	for workerID := 2; workerID >= 0; workerID-- {
+		if workerID == 1 {
+			continue // Worker 1 never gets to record anything
+		}
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			w := acc.Worker(workerID)
+			for i := 1; i <= workerID+1; i++ {
+				w.Record(time.Duration(workerID*10+i)*ms, time.Duration(i)*ms)
+				w.RecordQueryTimes([]time.Duration{ms})
+			}
+			w.RecordQueueWait(ms)
+			w.Record(0, 9*ms)
+			w.RecordError(ErrOther)
+		}(workerID)
+	}
+	wg.Wait()
+
+	merged := acc.Merge(3)
+	wantTimes := []time.Duration{1 * ms, 0, 21 * ms, 22 * ms, 23 * ms, 0}
+	wantIDs := []int{0, 0, 2, 2, 2, 2}
+	if len(merged.Times) != len(wantTimes) {
+		t.Fatalf("Times = %v, want %v", merged.Times, wantTimes)
+	}
+	for i := range wantTimes {
+		if merged.Times[i] != wantTimes[i] || merged.WorkerIDs[i] != wantIDs[i] {
+			t.Errorf("entry %d = %v by worker %d, want %v by worker %d",
+				i, merged.Times[i], merged.WorkerIDs[i], wantTimes[i], wantIDs[i])
+		}
+	}
+	if len(merged.Completions) != len(merged.Times) {
+		t.Errorf("%d completions for %d times", len(merged.Completions), len(merged.Times))
+	}
+	if len(merged.QueryTimes) != 4 || len(merged.QueueWaits) != 2 {
+		t.Errorf("query times %d, queue waits %d, want 4 and 2", len(merged.QueryTimes), len(merged.QueueWaits))
+	}
+	if merged.Errors[ErrOther] != 2 {
+		t.Errorf("Errors = %v, want 2 %s", merged.Errors, ErrOther)
+	}
+
+	// Per-worker views keep a slot for the worker that recorded nothing
+	if counts := completionCounts(merged.PerWorker); len(counts) != 3 || counts[0] != 1 || counts[1] != 0 || counts[2] != 3 {
+		t.Errorf("completion counts = %v, want [1 0 3]", counts)
+	}
+	// The last successful query's offset, not the failure recorded after it
+	if want := []time.Duration{1 * ms, 0, 3 * ms}; merged.LastSuccess[0] != want[0] || merged.LastSuccess[1] != want[1] || merged.LastSuccess[2] != want[2] {
+		t.Errorf("LastSuccess = %v, want %v", merged.LastSuccess, want)
+	}
+}
+
+func TestResultAccumulatorDropsSamplesAfterMerge(t *testing.T) {
+	acc := NewResultAccumulator()
+	w := acc.Worker(0)
+	w.Record(time.Millisecond, time.Millisecond)
+
+	// A worker abandoned by the watchdog may record while and after the merge runs
+	merged := acc.Merge(1)
+	w.Record(2*time.Millisecond, 2*time.Millisecond)
+	w.RecordError(ErrOther)
+
+	if len(merged.Times) != 1 || len(merged.PerWorker[0]) != 1 || len(merged.Errors) != 0 {
+		t.Errorf("merged %v (per worker %v, errors %v), want only the sample recorded before the merge",
+			merged.Times, merged.PerWorker[0], merged.Errors)
+	}
+}
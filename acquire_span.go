@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AcquireSpanName is the span around each connection acquisition. It keeps
+// the name it has always had rather than a shorter pool.acquire, so traces
+// and queries written against earlier runs still find it.
+const AcquireSpanName = "pool.acquire_connection"
+
+// Attribute keys of the acquisition span and its events, taken from the
+// pool's Stat() as the acquisition started and ended. The wait and the empty
+// acquire delta are only on the pool.acquired event: as span attributes they
+// would be about as unique as a timestamp.
+const (
+	AttrPoolIdleConns         = attribute.Key("pool.idle_conns")
+	AttrPoolAcquiredConns     = attribute.Key("pool.acquired_conns")
+	AttrPoolTotalConns        = attribute.Key("pool.total_conns")
+	AttrPoolMaxConns          = attribute.Key("pool.max_conns")
+	AttrPoolAcquireState      = attribute.Key("pool.acquire.state")
+	AttrPoolAcquireWait       = attribute.Key("pool.acquire.wait_us")
+	AttrPoolEmptyAcquireDelta = attribute.Key("pool.empty_acquire_delta")
+)
+
+// What an acquisition found when it started (AttrPoolAcquireState): an idle
+// connection to take, room to open a new one, or a saturated pool it had to
+// wait on until another worker released a connection
+const (
+	AcquireStateIdle    = "idle"
+	AcquireStateGrow    = "grow"
+	AcquireStateStarved = "starved"
+)
+
+// acquireState classifies the pool an acquisition started on
+func acquireState(stat *pgxpool.Stat) string {
+	switch {
+	case stat.IdleConns() > 0:
+		return AcquireStateIdle
+	case stat.TotalConns() < stat.MaxConns():
+		return AcquireStateGrow
+	}
+	return AcquireStateStarved
+}
+
+// poolStateAttributes describes a pool's connections at one moment
+func poolStateAttributes(stat *pgxpool.Stat) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		AttrPoolIdleConns.Int64(int64(stat.IdleConns())),
+		AttrPoolAcquiredConns.Int64(int64(stat.AcquiredConns())),
+		AttrPoolTotalConns.Int64(int64(stat.TotalConns())),
+		AttrPoolMaxConns.Int64(int64(stat.MaxConns())),
+	}
+}
+
+// annotateAcquireStart records the pool's state on an acquisition span as it
+// starts. On a saturated pool it adds a pool.exhausted event, so a trace shows
+// the wait began with every connection checked out. stat may be nil for pools
+// without statistics.
+func annotateAcquireStart(span trace.Span, stat *pgxpool.Stat) {
+	if stat == nil {
+		return
+	}
+	state := acquireState(stat)
+	span.SetAttributes(append(poolStateAttributes(stat), AttrPoolAcquireState.String(state))...)
+	if state == AcquireStateStarved {
+		span.AddEvent("pool.exhausted", trace.WithAttributes(poolStateAttributes(stat)...))
+	}
+}
+
+// annotateAcquireEnd adds a pool.acquired event with how long an acquisition
+// waited. When the pool keeps statistics, the event also gets how many
+// acquisitions across the pool found it empty in the meantime and the state
+// the pool ended in. before and after may be nil.
+func annotateAcquireEnd(span trace.Span, wait time.Duration, before, after *pgxpool.Stat) {
+	attrs := []attribute.KeyValue{AttrPoolAcquireWait.Int64(wait.Microseconds())}
+	if before != nil && after != nil {
+		attrs = append(attrs, AttrPoolEmptyAcquireDelta.Int64(after.EmptyAcquireCount()-before.EmptyAcquireCount()))
+		attrs = append(attrs, poolStateAttributes(after)...)
+	}
+	span.AddEvent("pool.acquired", trace.WithAttributes(attrs...))
+}
+
+// diagnoseTrace tells from a trace's spans whether the time went to acquiring
+// a connection or to the database, e.g. "acquire 9ms (starved, 12 empty
+// acquires), db 1ms: pool starvation"
+func diagnoseTrace(spans []sdktrace.ReadOnlySpan) string {
+	var acquire, db time.Duration
+	var state string
+	var emptyDelta int64
+	for _, span := range spans {
+		d := span.EndTime().Sub(span.StartTime())
+		switch {
+		case span.Name() == AcquireSpanName:
+			acquire += d
+			for _, kv := range span.Attributes() {
+				if kv.Key == AttrPoolAcquireState {
+					state = kv.Value.AsString()
+				}
+			}
+			for _, event := range span.Events() {
+				for _, kv := range event.Attributes {
+					if kv.Key == AttrPoolEmptyAcquireDelta {
+						emptyDelta += kv.Value.AsInt64()
+					}
+				}
+			}
+		case strings.HasPrefix(span.Name(), "db."):
+			db += d
+		}
+	}
+
+	detail := ""
+	if state != "" {
+		detail = fmt.Sprintf(" (%s, %d empty acquires)", state, emptyDelta)
+	}
+	verdict := "server-side"
+	if acquire > db {
+		switch state {
+		case AcquireStateStarved:
+			verdict = "pool starvation"
+		case AcquireStateGrow:
+			verdict = "connection setup"
+		default:
+			verdict = "acquisition"
+		}
+	}
+	return fmt.Sprintf("acquire %v%s, db %v: %s", acquire, detail, db, verdict)
+}
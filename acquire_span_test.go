@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestAcquireSpanShowsPoolStarvation(t *testing.T) {
+	server := startFakePGServer(t, 0)
+	poolConfig, err := pgxpool.ParseConfig(server.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolConfig.MaxConns = 1
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	collector := NewTraceCollector()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(collector))
+	defer tp.Shutdown(context.Background())
+	cfg := testWorkerConfig()
+	cfg.Tracer = tp.Tracer("test")
+
+	// Hold the only connection so the worker has to wait for it
+	held, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const hold = 30 * time.Millisecond
+	time.AfterFunc(hold, held.Release)
+	if _, _, err := executeWorkerQuery(context.Background(), pgxPooler{pool}, 0, 0, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var acquire sdktrace.ReadOnlySpan
+	for _, span := range collector.GetSpans() {
+		if span.Name() == AcquireSpanName {
+			acquire = span
+		}
+	}
+	if acquire == nil {
+		t.Fatal("no acquisition span")
+	}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range acquire.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if got := attrs[AttrPoolAcquireState].AsString(); got != AcquireStateStarved {
+		t.Errorf("state = %q, want %q", got, AcquireStateStarved)
+	}
+	if attrs[AttrPoolIdleConns].AsInt64() != 0 || attrs[AttrPoolAcquiredConns].AsInt64() != 1 || attrs[AttrPoolMaxConns].AsInt64() != 1 {
+		t.Errorf("pool state = %v, want the one connection acquired", attrs)
+	}
+	if _, ok := attrs[AttrPoolEmptyAcquireDelta]; ok {
+		t.Error("empty acquire delta should only be on the pool.acquired event")
+	}
+
+	events := acquire.Events()
+	if len(events) != 2 || events[0].Name != "pool.exhausted" || events[1].Name != "pool.acquired" {
+		t.Fatalf("events = %+v, want pool.exhausted then pool.acquired", events)
+	}
+	for _, kv := range events[1].Attributes {
+		if kv.Key == AttrPoolAcquireWait && time.Duration(kv.Value.AsInt64())*time.Microsecond < hold {
+			t.Errorf("wait = %dµs, want at least %v", kv.Value.AsInt64(), hold)
+		}
+		if kv.Key == AttrPoolEmptyAcquireDelta && kv.Value.AsInt64() != 1 {
+			t.Errorf("empty acquire delta = %d, want 1", kv.Value.AsInt64())
+		}
+	}
+
+	if got := diagnoseTrace(collector.GetSpans()); !strings.HasSuffix(got, ": pool starvation") || !strings.Contains(got, "(starved, 1 empty acquires)") {
+		t.Errorf("diagnosis = %q, want pool starvation", got)
+	}
+}
+
+func TestAcquireSpanWithoutPoolStats(t *testing.T) {
+	collector := NewTraceCollector()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(collector))
+	defer tp.Shutdown(context.Background())
+	cfg := testWorkerConfig()
+	cfg.Tracer = tp.Tracer("test")
+
+	// The fake pool keeps no statistics: the wait is still recorded
+	if _, _, err := executeWorkerQuery(context.Background(), newFakePooler(0), 0, 0, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, span := range collector.GetSpans() {
+		if span.Name() != AcquireSpanName {
+			continue
+		}
+		if len(span.Attributes()) != 0 || len(span.Events()) != 1 || span.Events()[0].Name != "pool.acquired" {
+			t.Errorf("attributes %v, events %+v, want only the pool.acquired event", span.Attributes(), span.Events())
+		}
+	}
+	if got := diagnoseTrace(collector.GetSpans()); !strings.HasPrefix(got, "acquire ") || strings.Contains(got, "empty acquires") {
+		t.Errorf("diagnosis = %q, want no pool state", got)
+	}
+}
+
+// statCountingPooler counts the Stat calls made on a fakePooler
+type statCountingPooler struct {
+	*fakePooler
+	stats int
+}
+
+func (p *statCountingPooler) Stat() *pgxpool.Stat {
+	p.stats++
+	return p.fakePooler.Stat()
+}
+
+func TestAcquireSkipsPoolStatsForUnsampledSpans(t *testing.T) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+	defer tp.Shutdown(context.Background())
+	cfg := testWorkerConfig()
+	cfg.Tracer = tp.Tracer("test")
+
+	pool := &statCountingPooler{fakePooler: newFakePooler(0)}
+	if _, _, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if pool.stats != 0 {
+		t.Errorf("Stat called %d times for a span that isn't recorded, want 0", pool.stats)
+	}
+}
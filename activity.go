@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ActivityQuery lists the benchmark database's client backends other than the
+// sampler's own, with what each one is waiting on
+const ActivityQuery = `SELECT COALESCE(state, ''), COALESCE(wait_event_type, ''), COALESCE(wait_event, '')
+FROM pg_stat_activity
+WHERE datname = current_database() AND pid <> pg_backend_pid() AND backend_type = 'client backend'`
+
+// DefaultTopWaits is how many wait events the report lists per run and connection type
+const DefaultTopWaits = 5
+
+// activityQuerier is the part of *pgx.Conn the activity sampler uses
+type activityQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// ActivitySampler polls pg_stat_activity over a dedicated connection at a fixed
+// interval and tallies what server backends are doing
+type ActivitySampler struct {
+	conn     activityQuerier
+	interval time.Duration
+
+	mu      sync.Mutex
+	samples int
+	waits   map[string]int
+	warned  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartActivitySampler takes an initial sample and keeps sampling in the background until Stop is called
+func StartActivitySampler(conn activityQuerier, interval time.Duration) *ActivitySampler {
+	s := &ActivitySampler{
+		conn:     conn,
+		interval: interval,
+		waits:    make(map[string]int),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	s.sample()
+	go s.run()
+
+	return s
+}
+
+func (s *ActivitySampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sample tallies one snapshot of pg_stat_activity. A failed sample is skipped,
+// with a warning the first time.
+func (s *ActivitySampler) sample() {
+	ctx, cancel := context.WithTimeout(context.Background(), max(s.interval, time.Second))
+	defer cancel()
+
+	var labels []string
+	rows, err := s.conn.Query(ctx, ActivityQuery)
+	if err == nil {
+		_, err = drainRows(rows, func(rows pgx.Rows) error {
+			var state, waitType, waitEvent string
+			if err := rows.Scan(&state, &waitType, &waitEvent); err != nil {
+				return err
+			}
+			labels = append(labels, waitLabel(state, waitType, waitEvent))
+			return nil
+		})
+		rows.Close()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		if !s.warned {
+			s.warned = true
+			slog.Warn("Failed to sample pg_stat_activity", "error", err)
+		}
+		return
+	}
+	s.samples++
+	for _, label := range labels {
+		s.waits[label]++
+	}
+}
+
+// Stop stops sampling, takes a final sample and returns the tallies
+func (s *ActivitySampler) Stop() ActivitySummary {
+	close(s.stop)
+	<-s.done
+	s.sample()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ActivitySummary{Samples: s.samples, Waits: s.waits}
+}
+
+// ActivitySummary counts backend observations by what the backend was doing
+type ActivitySummary struct {
+	Samples int            // Snapshots taken
+	Waits   map[string]int // Backends seen per waitLabel, summed over snapshots
+}
+
+// waitLabel describes a backend as its state and wait event, e.g.
+// "active (Lock:transactionid)", "idle (Client:ClientRead)" or "active (running)"
+func waitLabel(state, waitType, waitEvent string) string {
+	if state == "" {
+		state = "unknown"
+	}
+	if waitType == "" {
+		if state == "active" {
+			return "active (running)"
+		}
+		return state
+	}
+	return fmt.Sprintf("%s (%s:%s)", state, waitType, waitEvent)
+}
+
+// formatTopWaits renders the n most frequent wait labels with their share of
+// all observations, e.g. "idle (Client:ClientRead)=120 (60%)"
+func formatTopWaits(waits map[string]int, n int) string {
+	total := 0
+	labels := make([]string, 0, len(waits))
+	for label, count := range waits {
+		labels = append(labels, label)
+		total += count
+	}
+	if total == 0 {
+		return "none observed"
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if waits[labels[i]] != waits[labels[j]] {
+			return waits[labels[i]] > waits[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	if len(labels) > n {
+		labels = labels[:n]
+	}
+
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = fmt.Sprintf("%s=%d (%.0f%%)", label, waits[label], float64(waits[label])/float64(total)*100)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// connectActivityMonitor opens the sampler's dedicated connection straight to
+// PostgreSQL at dsn
+func connectActivityMonitor(ctx context.Context, dsn string, tls TLSOptions) (*pgx.Conn, error) {
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	if err := applyTLS(&connConfig.Config, tls); err != nil {
+		return nil, err
+	}
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return conn, nil
+}
+
+// renderServerWaits lists the top server wait events of each connection type's
+// actual runs combined, or returns "" when pg_stat_activity wasn't sampled
+func renderServerWaits(results []BenchmarkResult) string {
+	byType := make(map[ConnectionType]map[string]int)
+	for _, r := range results {
+		if r.IsWarmup || r.ActivitySamples == 0 {
+			continue
+		}
+		if byType[r.ConnectionType] == nil {
+			byType[r.ConnectionType] = make(map[string]int)
+		}
+		for label, count := range r.ServerWaits {
+			byType[r.ConnectionType][label] += count
+		}
+	}
+
+	var sb strings.Builder
+	for _, connType := range sortedConnTypes(byType) {
+		sb.WriteString(fmt.Sprintf("%-22s %s\n", connType, formatTopWaits(byType[connType], DefaultTopWaits)))
+	}
+	return sb.String()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeActivity answers ActivityQuery with a fixed set of backends
+type fakeActivity struct {
+	rows [][]any
+	err  error
+}
+
+func (f *fakeActivity) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &fakeRows{values: f.rows}, nil
+}
+
+func TestActivitySamplerTalliesWaits(t *testing.T) {
+	conn := &fakeActivity{rows: [][]any{
+		{"active", "Lock", "transactionid"},
+		{"idle", "Client", "ClientRead"},
+		{"idle", "Client", "ClientRead"},
+		{"active", "", ""},
+	}}
+
+	summary := StartActivitySampler(conn, time.Hour).Stop()
+	if summary.Samples != 2 {
+		t.Fatalf("samples = %d, want the initial and final sample", summary.Samples)
+	}
+	want := map[string]int{
+		"active (Lock:transactionid)": 2,
+		"idle (Client:ClientRead)":    4,
+		"active (running)":            2,
+	}
+	for label, count := range want {
+		if summary.Waits[label] != count {
+			t.Errorf("%s = %d, want %d", label, summary.Waits[label], count)
+		}
+	}
+
+	out := formatTopWaits(summary.Waits, 2)
+	if out != "idle (Client:ClientRead)=4 (50%), active (Lock:transactionid)=2 (25%)" {
+		t.Errorf("formatTopWaits = %q", out)
+	}
+}
+
+func TestActivitySamplerSkipsFailedSamples(t *testing.T) {
+	summary := StartActivitySampler(&fakeActivity{err: errors.New("permission denied")}, time.Hour).Stop()
+	if summary.Samples != 0 || len(summary.Waits) != 0 {
+		t.Errorf("summary = %+v, want nothing recorded", summary)
+	}
+}
+
+func TestRenderServerWaits(t *testing.T) {
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerSession, IsWarmup: true, ActivitySamples: 1, ServerWaits: map[string]int{"warmup": 100}},
+		{ConnectionType: PgBouncerSession, ActivitySamples: 1, ServerWaits: map[string]int{"idle": 3}},
+		{ConnectionType: PgBouncerSession, ActivitySamples: 1, ServerWaits: map[string]int{"idle": 1}},
+	}
+
+	out := renderServerWaits(results)
+	if !strings.Contains(out, "idle=4 (100%)") || strings.Contains(out, "warmup") {
+		t.Errorf("unexpected server waits:\n%s", out)
+	}
+	if renderServerWaits(nil) != "" {
+		t.Error("expected nothing without samples")
+	}
+}
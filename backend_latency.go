@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BackendLatencies splits query times by the backend that served each query,
+// from the pg_backend_pid() the worker query returns. A query is on a new
+// backend when it is the first on its client connection or ran on a different
+// backend than the query before it there, and on a reused backend when the
+// same backend served it again. Session mode pins a client connection to one
+// backend; transaction mode hands it whichever backend is free, so its new
+// backend queries carry the cost of switching servers between clients.
+// It is safe for concurrent use; a nil value ignores additions.
+type BackendLatencies struct {
+	mu     sync.Mutex
+	last   map[any]uint32 // Backend of each client connection's previous query
+	newer  []time.Duration
+	reused []time.Duration
+}
+
+// NewBackendLatencies returns an empty recorder
+func NewBackendLatencies() *BackendLatencies {
+	return &BackendLatencies{last: make(map[any]uint32)}
+}
+
+// Add records a query that took d on backend pid, run over client connection conn
+func (b *BackendLatencies) Add(conn PooledConn, pid uint32, d time.Duration) {
+	if b == nil {
+		return
+	}
+	client := clientConn(conn)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if prev, ok := b.last[client]; ok && prev == pid {
+		b.reused = append(b.reused, d)
+	} else {
+		b.newer = append(b.newer, d)
+	}
+	b.last[client] = pid
+}
+
+// Times returns the recorded times of queries on new and on reused backends
+func (b *BackendLatencies) Times() (newBackend, reusedBackend []time.Duration) {
+	if b == nil {
+		return nil, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]time.Duration(nil), b.newer...), append([]time.Duration(nil), b.reused...)
+}
+
+// clientConn identifies the client connection behind conn, which outlives the
+// pool's wrapper handed out by each acquire
+func clientConn(conn PooledConn) any {
+	switch c := conn.(type) {
+	case *pgxpool.Conn:
+		return c.Conn().PgConn()
+	case *sqlConn:
+		return c.Conn.PgConn()
+	}
+	return conn
+}
+
+// formatBackendLatency compares a run's queries on new and reused backends, e.g.
+// "new 40 avg 2.1ms p99 4ms, reused 960 avg 1.2ms p99 2ms (+900µs)"
+func formatBackendLatency(newBackend, reusedBackend []time.Duration) string {
+	s := fmt.Sprintf("new %d avg %v p99 %v, reused %d avg %v p99 %v",
+		len(newBackend), averageDuration(newBackend), percentile(newBackend, 99),
+		len(reusedBackend), averageDuration(reusedBackend), percentile(reusedBackend, 99))
+	if len(newBackend) > 0 && len(reusedBackend) > 0 {
+		s += fmt.Sprintf(" (%s)", signedDuration(averageDuration(newBackend)-averageDuration(reusedBackend)))
+	}
+	return s
+}
+
+// signedDuration formats d with its sign, e.g. "+900µs" or "-1ms"
+func signedDuration(d time.Duration) string {
+	if d >= 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
+
+// renderBackendLatencies compares each connection type's query latency on new
+// and reused backends over its measured runs, or returns "" when no run recorded any
+func renderBackendLatencies(results []BenchmarkResult) string {
+	type times struct{ newer, reused []time.Duration }
+	byType := make(map[ConnectionType]*times)
+	for _, r := range results {
+		if r.IsWarmup || len(r.NewBackendQueryTimes)+len(r.ReusedBackendQueryTimes) == 0 {
+			continue
+		}
+		t, ok := byType[r.ConnectionType]
+		if !ok {
+			t = &times{}
+			byType[r.ConnectionType] = t
+		}
+		t.newer = append(t.newer, r.NewBackendQueryTimes...)
+		t.reused = append(t.reused, r.ReusedBackendQueryTimes...)
+	}
+	if len(byType) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("  %-26s %16s %10s %10s %16s %10s %10s %10s\n", "Connection Type",
+		"New Backend", "Avg", "P99", "Reused Backend", "Avg", "P99", "Overhead"))
+	for _, connType := range reportTypeOrder {
+		t, ok := byType[connType]
+		if !ok {
+			continue
+		}
+		overhead := "-"
+		if len(t.newer) > 0 && len(t.reused) > 0 {
+			overhead = signedDuration(averageDuration(t.newer) - averageDuration(t.reused))
+		}
+		total := float64(len(t.newer) + len(t.reused))
+		sb.WriteString(fmt.Sprintf("  %-26s %16s %10v %10v %16s %10v %10v %10s\n", connType,
+			fmt.Sprintf("%d (%.1f%%)", len(t.newer), float64(len(t.newer))/total*100),
+			averageDuration(t.newer), percentile(t.newer, 99),
+			fmt.Sprintf("%d (%.1f%%)", len(t.reused), float64(len(t.reused))/total*100),
+			averageDuration(t.reused), percentile(t.reused, 99), overhead))
+	}
+	sb.WriteString("\n  New backend: the first query on a client connection, or one that ran on a\n")
+	sb.WriteString("  different backend than the query before it there. Overhead is the avg difference.\n")
+	return sb.String()
+}
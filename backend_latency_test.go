@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackendLatenciesClassifyByClientConnection(t *testing.T) {
+	pool := newFakePooler(0)
+	a, _ := pool.Acquire(context.Background())
+	b, _ := pool.Acquire(context.Background())
+
+	l := NewBackendLatencies()
+	l.Add(a, 100, 5*time.Millisecond) // a's first query
+	l.Add(a, 100, 1*time.Millisecond) // same backend again
+	l.Add(a, 200, 6*time.Millisecond) // multiplexed onto another backend
+	l.Add(b, 200, 4*time.Millisecond) // b's first query, on a backend a used
+	l.Add(b, 200, 2*time.Millisecond)
+
+	newer, reused := l.Times()
+	if len(newer) != 3 || len(reused) != 2 {
+		t.Fatalf("new %v, reused %v; want 3 new, 2 reused", newer, reused)
+	}
+	if averageDuration(newer) != 5*time.Millisecond || averageDuration(reused) != 1500*time.Microsecond {
+		t.Errorf("avg new %v, reused %v", averageDuration(newer), averageDuration(reused))
+	}
+
+	var nilLatencies *BackendLatencies
+	nilLatencies.Add(a, 1, time.Millisecond)
+	if newer, reused := nilLatencies.Times(); newer != nil || reused != nil {
+		t.Error("a nil recorder should record nothing")
+	}
+}
+
+func TestExecuteWorkerQueryRecordsBackendLatencyAgainstFakeServer(t *testing.T) {
+	server := startFakePGServer(t, 0)
+	pools, err := NewPoolSet(Config{ConnType: PgBouncerSession, DSN: server.DSN(), PoolInstances: 1, MaxConns: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pools.Close()
+
+	cfg := testWorkerConfig()
+	cfg.QueriesPerConn = 3
+	cfg.BackendLatencies = NewBackendLatencies()
+	for i := 0; i < 2; i++ {
+		if _, _, err := executeWorkerQuery(context.Background(), pools.Poolers()[0], 0, 0, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The fake server gives each connection a backend of its own, as session
+	// mode does, so only the connection's first query lands on a new one
+	newer, reused := cfg.BackendLatencies.Times()
+	if len(newer) != 1 || len(reused) != 5 {
+		t.Errorf("new %d, reused %d; want 1 new and 5 reused across two acquires of one connection", len(newer), len(reused))
+	}
+}
+
+func TestExecuteWorkerQueryRecordsBackendLatencyForBatchesAndTransactions(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		apply func(*WorkerConfig)
+	}{
+		{"single queries", func(*WorkerConfig) {}},
+		{"batches", func(cfg *WorkerConfig) { cfg.BatchSize = 3 }},
+		{"transactions", func(cfg *WorkerConfig) { cfg.Transactions = true }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testWorkerConfig()
+			cfg.QueriesPerConn = 2
+			cfg.BackendLatencies = NewBackendLatencies()
+			tt.apply(&cfg)
+			if _, _, err := executeWorkerQuery(context.Background(), newFakePooler(0), 0, 0, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			// Every query (batch, transaction) is attributed to the fake backend
+			newer, reused := cfg.BackendLatencies.Times()
+			if len(newer) != 1 || len(reused) != 1 {
+				t.Errorf("new %d, reused %d; want the first on a new backend and the second reusing it", len(newer), len(reused))
+			}
+		})
+	}
+}
+
+func TestRenderBackendLatencies(t *testing.T) {
+	ms := time.Millisecond
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerTransaction, IsWarmup: true, NewBackendQueryTimes: []time.Duration{100 * ms}},
+		{ConnectionType: PgBouncerTransaction, NewBackendQueryTimes: []time.Duration{3 * ms, 5 * ms},
+			ReusedBackendQueryTimes: []time.Duration{1 * ms, 1 * ms}},
+		{ConnectionType: PgBouncerSession, NewBackendQueryTimes: []time.Duration{2 * ms},
+			ReusedBackendQueryTimes: []time.Duration{1 * ms, 1 * ms, 1 * ms}},
+	}
+	got := renderBackendLatencies(results)
+	lines := strings.Split(got, "\n")
+	if !strings.HasPrefix(strings.TrimSpace(lines[1]), string(PgBouncerSession)) {
+		t.Errorf("types should follow report order:\n%s", got)
+	}
+	if fields := strings.Fields(lines[2]); fields[len(fields)-1] != "+3ms" || fields[2] != "(50.0%)" || fields[3] != "4ms" {
+		t.Errorf("transaction line = %q, want 2 (50.0%%) new at avg 4ms, +3ms overhead, warmup left out", lines[2])
+	}
+
+	if got := renderBackendLatencies([]BenchmarkResult{{ConnectionType: PgBouncerSession}}); got != "" {
+		t.Errorf("expected no section without recorded backends, got:\n%s", got)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "sync"
+
+// BackendPIDSet records the distinct PostgreSQL backend PIDs that served queries.
+// It is safe for concurrent use; a nil set ignores additions.
+type BackendPIDSet struct {
+	mu   sync.Mutex
+	pids map[uint32]struct{}
+}
+
+// NewBackendPIDSet returns an empty set
+func NewBackendPIDSet() *BackendPIDSet {
+	return &BackendPIDSet{pids: make(map[uint32]struct{})}
+}
+
+// Add records a backend PID
+func (s *BackendPIDSet) Add(pid uint32) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.pids[pid] = struct{}{}
+	s.mu.Unlock()
+}
+
+// Len returns the number of distinct PIDs recorded
+func (s *BackendPIDSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pids)
+}
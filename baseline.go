@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResultSummary is the part of a BenchmarkResult saved for later regression checks
+type ResultSummary struct {
+	ConnectionType     ConnectionType `json:"conn_type"`
+	Concurrency        int            `json:"concurrency"`
+	Iteration          int            `json:"iteration,omitempty"`
+	PoolInstances      int            `json:"pool_instances,omitempty"`
+	MaxConns           int32          `json:"max_conns,omitempty"`
+	AvgAcquisitionTime time.Duration  `json:"avg_acquisition_ns"`
+	P99AcquisitionTime time.Duration  `json:"p99_acquisition_ns"`
+	QueriesPerSecond   float64        `json:"qps"`
+	TotalQueries       int            `json:"total_queries"`
+	AvgReleaseTime     time.Duration  `json:"avg_release_ns,omitempty"`
+
+	// Set when the connection type ran its own levels (-session-concurrency,
+	// -transaction-concurrency), so a merged report pairs them the same way
+	ConcurrencyOverridden bool `json:"concurrency_overridden,omitempty"`
+
+	// Per-second QPS and latency, for plotting how throughput held up over the run
+	Timeline []TimelineBucket `json:"timeline,omitempty"`
+}
+
+// Regression describes one metric that got worse than the baseline allows
+type Regression struct {
+	ConnectionType ConnectionType
+	Concurrency    int
+	Metric         string
+	Baseline       string
+	Current        string
+	Change         float64 // Relative change in the worse direction, e.g. 0.25 = 25% worse
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s c=%d %s regressed %.1f%%: baseline %s, now %s",
+		r.ConnectionType, r.Concurrency, r.Metric, r.Change*100, r.Baseline, r.Current)
+}
+
+// summarizeResults keeps the actual (non-warmup) runs as ResultSummary entries
+func summarizeResults(results []BenchmarkResult) []ResultSummary {
+	var summaries []ResultSummary
+	for _, r := range results {
+		if r.IsWarmup {
+			continue
+		}
+		summaries = append(summaries, ResultSummary{
+			ConnectionType:     r.ConnectionType,
+			Concurrency:        r.Concurrency,
+			Iteration:          r.Iteration,
+			PoolInstances:      r.PoolInstances,
+			MaxConns:           r.MaxConns,
+			AvgAcquisitionTime: r.AvgAcquisitionTime,
+			P99AcquisitionTime: r.P99AcquisitionTime,
+			QueriesPerSecond:   r.QueriesPerSecond,
+			TotalQueries:       r.TotalQueries,
+			AvgReleaseTime:     r.AvgReleaseTime,
+			Timeline:           r.Timeline,
+
+			ConcurrencyOverridden: r.ConcurrencyOverridden,
+		})
+	}
+	return summaries
+}
+
+// SavedResults is the JSON document written by SaveResults
+type SavedResults struct {
+	Metadata RunMetadata     `json:"metadata"`
+	Results  []ResultSummary `json:"results"`
+}
+
+// SaveResults writes the run metadata, including the pool tuning the results were
+// measured with, and the actual runs' summaries to filename as JSON
+func SaveResults(results []BenchmarkResult, tuning PoolTuning, filename string) error {
+	saved := SavedResults{Metadata: collectRunMetadata(results), Results: summarizeResults(results)}
+	saved.Metadata.PoolTuning = tuning.String()
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	return nil
+}
+
+// LoadBaseline reads result summaries previously written by SaveResults
+func LoadBaseline(filename string) ([]ResultSummary, error) {
+	saved, err := LoadSavedResults(filename)
+	if err != nil {
+		return nil, err
+	}
+	return saved.Results, nil
+}
+
+// LoadSavedResults reads a file written by SaveResults. Files saved before
+// metadata was recorded, holding just the summary array, load with empty metadata.
+func LoadSavedResults(filename string) (SavedResults, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return SavedResults{}, fmt.Errorf("failed to read results: %w", err)
+	}
+
+	var saved SavedResults
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &saved.Results)
+	} else {
+		err = json.Unmarshal(data, &saved)
+	}
+	if err != nil {
+		return SavedResults{}, fmt.Errorf("failed to parse results: %w", err)
+	}
+	return saved, nil
+}
+
+// compareToBaseline returns every p99 acquisition or QPS figure of current that is
+// worse than the matching baseline entry by more than threshold (0.10 = 10%).
+// Repeated iterations are averaged on both sides first. Entries without a
+// baseline counterpart are skipped.
+func compareToBaseline(current, baseline []ResultSummary, threshold float64) []Regression {
+	current = averageIterations(current)
+	baseline = averageIterations(baseline)
+
+	type key struct {
+		connType    ConnectionType
+		concurrency int
+	}
+	byKey := make(map[key]ResultSummary, len(baseline))
+	for _, b := range baseline {
+		byKey[key{b.ConnectionType, b.Concurrency}] = b
+	}
+
+	var regressions []Regression
+	for _, c := range current {
+		b, ok := byKey[key{c.ConnectionType, c.Concurrency}]
+		if !ok {
+			continue
+		}
+
+		// Higher p99 is worse
+		if b.P99AcquisitionTime > 0 {
+			change := float64(c.P99AcquisitionTime-b.P99AcquisitionTime) / float64(b.P99AcquisitionTime)
+			if change > threshold {
+				regressions = append(regressions, Regression{
+					ConnectionType: c.ConnectionType,
+					Concurrency:    c.Concurrency,
+					Metric:         "p99_acquisition",
+					Baseline:       b.P99AcquisitionTime.String(),
+					Current:        c.P99AcquisitionTime.String(),
+					Change:         change,
+				})
+			}
+		}
+
+		// Lower QPS is worse
+		if b.QueriesPerSecond > 0 {
+			change := (b.QueriesPerSecond - c.QueriesPerSecond) / b.QueriesPerSecond
+			if change > threshold {
+				regressions = append(regressions, Regression{
+					ConnectionType: c.ConnectionType,
+					Concurrency:    c.Concurrency,
+					Metric:         "qps",
+					Baseline:       fmt.Sprintf("%.2f", b.QueriesPerSecond),
+					Current:        fmt.Sprintf("%.2f", c.QueriesPerSecond),
+					Change:         change,
+				})
+			}
+		}
+	}
+
+	return regressions
+}
+
+// percentFlag is a flag.Value holding a fraction, accepting "10%" or "10" for 0.10
+type percentFlag float64
+
+func (p *percentFlag) String() string {
+	if p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*p)*100, 'f', -1, 64) + "%"
+}
+
+func (p *percentFlag) Set(value string) error {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return fmt.Errorf("percentage must not be negative")
+	}
+	*p = percentFlag(v / 100)
+	return nil
+}
+
+// printRegressions reports the outcome of a baseline comparison
+func printRegressions(regressions []Regression, baselineFile string, threshold float64) {
+	if len(regressions) == 0 {
+		fmt.Printf("No regressions beyond %.1f%% against %s\n", threshold*100, baselineFile)
+		return
+	}
+
+	fmt.Printf("REGRESSIONS beyond %.1f%% against %s:\n", threshold*100, baselineFile)
+	for _, r := range regressions {
+		fmt.Printf("  %s\n", r)
+	}
+}
+
+// averageIterations collapses the iterations of each connection type and
+// concurrency level into one summary holding their means, in first-seen order
+func averageIterations(summaries []ResultSummary) []ResultSummary {
+	type key struct {
+		connType    ConnectionType
+		concurrency int
+	}
+	groups := make(map[key][]ResultSummary)
+	var order []key
+	for _, s := range summaries {
+		k := key{s.ConnectionType, s.Concurrency}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], s)
+	}
+
+	averaged := make([]ResultSummary, 0, len(order))
+	for _, k := range order {
+		group := groups[k]
+		avg := ResultSummary{ConnectionType: k.connType, Concurrency: k.concurrency}
+		var avgAcquisition, p99Acquisition time.Duration
+		for _, s := range group {
+			avgAcquisition += s.AvgAcquisitionTime
+			p99Acquisition += s.P99AcquisitionTime
+			avg.QueriesPerSecond += s.QueriesPerSecond
+			avg.TotalQueries += s.TotalQueries
+		}
+		n := len(group)
+		avg.AvgAcquisitionTime = avgAcquisition / time.Duration(n)
+		avg.P99AcquisitionTime = p99Acquisition / time.Duration(n)
+		avg.QueriesPerSecond /= float64(n)
+		avg.TotalQueries /= n
+		averaged = append(averaged, avg)
+	}
+	return averaged
+}
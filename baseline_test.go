@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompareToBaseline(t *testing.T) {
+	baseline := []ResultSummary{
+		{ConnectionType: PgBouncerSession, Concurrency: 100, P99AcquisitionTime: 10 * time.Millisecond, QueriesPerSecond: 1000},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 100, P99AcquisitionTime: 10 * time.Millisecond, QueriesPerSecond: 1000},
+	}
+	current := []ResultSummary{
+		// Within tolerance
+		{ConnectionType: PgBouncerSession, Concurrency: 100, P99AcquisitionTime: 10500 * time.Microsecond, QueriesPerSecond: 950},
+		// p99 25% worse and QPS 20% lower
+		{ConnectionType: PgBouncerTransaction, Concurrency: 100, P99AcquisitionTime: 12500 * time.Microsecond, QueriesPerSecond: 800},
+		// No baseline entry
+		{ConnectionType: DirectPostgres, Concurrency: 100, P99AcquisitionTime: time.Second, QueriesPerSecond: 1},
+	}
+
+	regressions := compareToBaseline(current, baseline, 0.10)
+	if len(regressions) != 2 {
+		t.Fatalf("got %d regressions, want 2: %v", len(regressions), regressions)
+	}
+	for _, r := range regressions {
+		if r.ConnectionType != PgBouncerTransaction {
+			t.Errorf("unexpected regression %s", r)
+		}
+	}
+	if regressions[0].Metric != "p99_acquisition" || regressions[1].Metric != "qps" {
+		t.Errorf("unexpected metrics: %v", regressions)
+	}
+}
+
+func TestSaveAndLoadBaseline(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerSession, Concurrency: 10, IsWarmup: true},
+		{ConnectionType: PgBouncerSession, Concurrency: 10, P99AcquisitionTime: 3 * time.Millisecond, QueriesPerSecond: 42},
+	}
+
+	if err := SaveResults(results, DefaultPoolTuning(), filename); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := LoadBaseline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline) != 1 || baseline[0].P99AcquisitionTime != 3*time.Millisecond || baseline[0].QueriesPerSecond != 42 {
+		t.Errorf("round trip lost data: %+v", baseline)
+	}
+}
+
+func TestLoadBaselineWithoutMetadata(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+	legacy := `[{"conn_type": "pgbouncer-session", "concurrency": 10, "p99_acquisition_ns": 3000000, "qps": 42}]`
+	if err := os.WriteFile(filename, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err := LoadBaseline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline) != 1 || baseline[0].P99AcquisitionTime != 3*time.Millisecond {
+		t.Errorf("legacy baseline = %+v", baseline)
+	}
+}
+
+func TestPercentFlag(t *testing.T) {
+	var p percentFlag
+	for value, want := range map[string]float64{"10%": 0.10, "25": 0.25, " 5% ": 0.05} {
+		if err := p.Set(value); err != nil || float64(p) != want {
+			t.Errorf("Set(%q) = %v (err %v), want %v", value, float64(p), err, want)
+		}
+	}
+	if err := p.Set("-1%"); err == nil {
+		t.Error("expected an error for a negative percentage")
+	}
+}
+
+func TestCompareToBaselineAveragesIterations(t *testing.T) {
+	baseline := []ResultSummary{
+		{ConnectionType: PgBouncerSession, Concurrency: 10, Iteration: 1, P99AcquisitionTime: 10 * time.Millisecond, QueriesPerSecond: 900},
+		{ConnectionType: PgBouncerSession, Concurrency: 10, Iteration: 2, P99AcquisitionTime: 10 * time.Millisecond, QueriesPerSecond: 1100},
+	}
+	// One slow iteration alone would regress, but the mean stays within tolerance
+	current := []ResultSummary{
+		{ConnectionType: PgBouncerSession, Concurrency: 10, Iteration: 1, P99AcquisitionTime: 10 * time.Millisecond, QueriesPerSecond: 850},
+		{ConnectionType: PgBouncerSession, Concurrency: 10, Iteration: 2, P99AcquisitionTime: 10 * time.Millisecond, QueriesPerSecond: 1100},
+	}
+
+	if regressions := compareToBaseline(current, baseline, 0.10); len(regressions) != 0 {
+		t.Errorf("unexpected regressions: %v", regressions)
+	}
+}
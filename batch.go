@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// runWorkerBatch queues cfg.BatchSize worker queries in one pgx.Batch, sends them
+// to the server in a single pipelined round trip and reads every result. Under
+// transaction-mode PgBouncer the whole batch has to complete within one server
+// connection assignment. It returns the backend PID that served the batch and
+// when the last result was read.
+func runWorkerBatch(ctx context.Context, conn PooledConn, workerLog *slog.Logger, cfg WorkerConfig) (uint32, time.Time, error) {
+	batch := &pgx.Batch{}
+	for i := 0; i < cfg.BatchSize; i++ {
+		sql, args := workerQuery(cfg)
+		batch.Queue(sql, args...)
+	}
+
+	// Span: The whole pipelined batch, from sending to the last result read
+	_, batchSpan := cfg.Tracer.Start(ctx, "db.batch")
+	batchSpan.SetAttributes(attribute.Int("batch.size", cfg.BatchSize))
+	defer batchSpan.End()
+
+	// The whole pipeline runs on one backend; the last PID read stands for it
+	var servedBy uint32
+	results := conn.SendBatch(ctx, batch)
+	for i := 0; i < cfg.BatchSize; i++ {
+		rows, err := results.Query()
+		if err != nil {
+			results.Close()
+			batchSpan.RecordError(err)
+			return 0, time.Now(), fmt.Errorf("batch query %d: %w", i, err)
+		}
+		_, pid, err := streamWorkerRows(rows, workerLog, cfg)
+		rows.Close()
+		if err != nil {
+			results.Close()
+			batchSpan.RecordError(err)
+			return 0, time.Now(), fmt.Errorf("batch query %d: %w", i, err)
+		}
+		if pid != 0 {
+			servedBy = pid
+		}
+	}
+
+	if err := results.Close(); err != nil {
+		batchSpan.RecordError(err)
+		return 0, time.Now(), fmt.Errorf("close batch: %w", err)
+	}
+	return servedBy, time.Now(), nil
+}
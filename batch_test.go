@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeBatchResults answers each queued query of a batch through its fakeConn.
+// Methods the worker doesn't use are left to the nil embedded interface.
+type fakeBatchResults struct {
+	pgx.BatchResults
+	conn      *fakeConn
+	remaining int
+	queryErr  error
+	closed    bool
+}
+
+func (r *fakeBatchResults) Query() (pgx.Rows, error) {
+	if r.remaining == 0 {
+		return nil, errors.New("no result left in batch")
+	}
+	r.remaining--
+	if r.queryErr != nil {
+		return nil, r.queryErr
+	}
+	return r.conn.Query(context.Background(), WorkerQuery)
+}
+
+func (r *fakeBatchResults) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestExecuteWorkerQueryBatches(t *testing.T) {
+	pool := newFakePooler(0)
+	cfg := testWorkerConfig()
+	cfg.BatchSize = 5
+	cfg.QueriesPerConn = 2
+	cfg.BackendPIDs = NewBackendPIDSet()
+
+	_, batchTimes, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batchTimes) != 2 {
+		t.Errorf("got %d batch times, want one per batch (2)", len(batchTimes))
+	}
+	if got := pool.queries.Load(); got != 10 {
+		t.Errorf("ran %d queries, want 2 batches of 5", got)
+	}
+	if cfg.BackendPIDs.Len() != 1 {
+		t.Errorf("backend PIDs = %d, want the batch results' PID recorded", cfg.BackendPIDs.Len())
+	}
+}
+
+func TestRunWorkerBatchFailsOnQueryError(t *testing.T) {
+	results := &fakeBatchResults{remaining: 3, queryErr: errors.New("prepared statement does not exist")}
+	conn := &batchConn{fakeConn: &fakeConn{pool: newFakePooler(0)}, results: results}
+	cfg := testWorkerConfig()
+	cfg.BatchSize = 3
+
+	if _, _, err := runWorkerBatch(context.Background(), conn, slog.Default(), cfg); err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	if !results.closed {
+		t.Error("batch results were not closed after the failure")
+	}
+}
+
+// batchConn is a fakeConn returning preset batch results
+type batchConn struct {
+	*fakeConn
+	results *fakeBatchResults
+}
+
+func (c *batchConn) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return c.results
+}
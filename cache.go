@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultCacheSettle is how long the cache test waits after a run before reading
+// pg_statio_user_tables. Since PostgreSQL 15, backends flush their I/O counters
+// at most once a second while busy and within 10 seconds once idle, so reading
+// right after a run would miss its last second.
+const DefaultCacheSettle = 11 * time.Second
+
+// cacheStatsSQL reads the buffer counters of the benchmark table and its indexes
+const cacheStatsSQL = `SELECT coalesce(heap_blks_read, 0), coalesce(heap_blks_hit, 0),
+	coalesce(idx_blks_read, 0), coalesce(idx_blks_hit, 0)
+	FROM pg_statio_user_tables WHERE relname = 'benchmark_data'`
+
+// CacheStats are shared-buffer counters of benchmark_data: blocks read from
+// outside shared buffers (the OS cache or storage) and blocks found in them
+type CacheStats struct {
+	HeapRead int64
+	HeapHit  int64
+	IdxRead  int64
+	IdxHit   int64
+}
+
+// Sub returns the counters accumulated since before
+func (s CacheStats) Sub(before CacheStats) CacheStats {
+	return CacheStats{
+		HeapRead: s.HeapRead - before.HeapRead,
+		HeapHit:  s.HeapHit - before.HeapHit,
+		IdxRead:  s.IdxRead - before.IdxRead,
+		IdxHit:   s.IdxHit - before.IdxHit,
+	}
+}
+
+// Reads is the number of blocks read from outside shared buffers
+func (s CacheStats) Reads() int64 { return s.HeapRead + s.IdxRead }
+
+// HitRatio is the fraction of block accesses served from shared buffers, and
+// false when there were none
+func (s CacheStats) HitRatio() (float64, bool) {
+	hits := s.HeapHit + s.IdxHit
+	if hits+s.Reads() == 0 {
+		return 0, false
+	}
+	return float64(hits) / float64(hits+s.Reads()), true
+}
+
+func (s CacheStats) formatHitRatio() string {
+	ratio, ok := s.HitRatio()
+	if !ok {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", ratio*100)
+}
+
+// readCacheStats reads the benchmark_data counters through pool
+func readCacheStats(ctx context.Context, pool Pooler) (CacheStats, error) {
+	rows, err := pool.Query(ctx, cacheStatsSQL)
+	if err != nil {
+		return CacheStats{}, err
+	}
+	defer rows.Close()
+
+	var s CacheStats
+	n, err := drainRows(rows, func(rows pgx.Rows) error {
+		return rows.Scan(&s.HeapRead, &s.HeapHit, &s.IdxRead, &s.IdxHit)
+	})
+	if err == nil && n == 0 {
+		err = fmt.Errorf("benchmark_data not found in pg_statio_user_tables")
+	}
+	return s, err
+}
+
+// resetCacheStats zeroes the database's statistics counters, which needs
+// superuser or an explicit grant on pg_stat_reset
+func resetCacheStats(ctx context.Context, pool Pooler) error {
+	rows, err := pool.Query(ctx, "SELECT pg_stat_reset()")
+	if err != nil {
+		return err
+	}
+	rows.Close()
+	return rows.Err()
+}
+
+// CacheRun is one run of the cache test with the buffer counters it produced
+type CacheRun struct {
+	AvgAcquisitionTime time.Duration
+	P99AcquisitionTime time.Duration
+	QueriesPerSecond   float64
+	Stats              CacheStats
+}
+
+func newCacheRun(r BenchmarkResult, stats CacheStats) CacheRun {
+	return CacheRun{
+		AvgAcquisitionTime: r.AvgAcquisitionTime,
+		P99AcquisitionTime: r.P99AcquisitionTime,
+		QueriesPerSecond:   r.QueriesPerSecond,
+		Stats:              stats,
+	}
+}
+
+// CacheTestResult compares the first run of a connection type, before anything
+// warmed the pools or the table, with an identical run straight after it
+type CacheTestResult struct {
+	Concurrency int
+	Cold        CacheRun
+	Warm        CacheRun
+	StatsErr    string // Why the buffer counters are missing, if they are
+}
+
+// CacheShare is the fraction of the cold run's average latency the warm run
+// no longer paid: what the cold cache and cold pools cost
+func (c CacheTestResult) CacheShare() float64 {
+	if c.Cold.AvgAcquisitionTime <= 0 {
+		return 0
+	}
+	return float64(c.Cold.AvgAcquisitionTime-c.Warm.AvgAcquisitionTime) / float64(c.Cold.AvgAcquisitionTime)
+}
+
+// runCacheTest resets the statistics counters, then runs the workload twice at
+// concurrency, reading the benchmark_data buffer counters around each run. The
+// server can't be restarted from here, so the cold run is only as cold as its
+// hit ratio shows; counters are diffed, so a refused reset still gives
+// per-run numbers.
+func runCacheTest(ctx context.Context, config Config, pools *PoolSet, concurrency int, collector *TraceCollector, opts Options) CacheTestResult {
+	result := CacheTestResult{Concurrency: concurrency}
+	statsPool := pools.Poolers()[0]
+
+	if err := resetCacheStats(ctx, statsPool); err != nil {
+		slog.Warn("pg_stat_reset failed; diffing counters instead", "conn_type", config.ConnType, "error", err)
+	}
+	readStats := func() CacheStats {
+		stats, err := readCacheStats(ctx, statsPool)
+		if err != nil && result.StatsErr == "" {
+			slog.Warn("Failed to read pg_statio_user_tables", "conn_type", config.ConnType, "error", err)
+			result.StatsErr = err.Error()
+		}
+		return stats
+	}
+
+	before := readStats()
+	fmt.Printf("🧊 Cache Test, cold run - Concurrency: %d\n", concurrency)
+	opts.RunVariant = "cold"
+	cold := runBenchmark(config, pools.Poolers(), pools.ConnectTimer(), concurrency, false, collector, opts)
+	time.Sleep(opts.CacheSettle)
+	afterCold := readStats()
+
+	fmt.Printf("🔥 Cache Test, warm run - Concurrency: %d\n", concurrency)
+	opts.RunVariant = "warm"
+	warm := runBenchmark(config, pools.Poolers(), pools.ConnectTimer(), concurrency, false, collector, opts)
+	time.Sleep(opts.CacheSettle)
+	afterWarm := readStats()
+
+	result.Cold = newCacheRun(cold, afterCold.Sub(before))
+	result.Warm = newCacheRun(warm, afterWarm.Sub(afterCold))
+	return result
+}
+
+// renderCacheTests compares the cold and warm runs of each connection type's
+// cache test. Returns "" without any.
+func renderCacheTests(results []BenchmarkResult) string {
+	var sb strings.Builder
+	for _, r := range results {
+		c := r.CacheTest
+		if c == nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s, concurrency %d:\n", r.ConnectionType, c.Concurrency))
+		sb.WriteString(fmt.Sprintf("  %-6s %10s %12s %14s %14s %10s\n", "Run", "Hit Ratio", "Blocks Read", "Avg Acq", "P99 Acq", "QPS"))
+		for _, run := range []struct {
+			name string
+			run  CacheRun
+		}{{"cold", c.Cold}, {"warm", c.Warm}} {
+			reads := fmt.Sprint(run.run.Stats.Reads())
+			if c.StatsErr != "" {
+				reads = "n/a"
+			}
+			sb.WriteString(fmt.Sprintf("  %-6s %10s %12s %14v %14v %10.2f\n", run.name, run.run.Stats.formatHitRatio(), reads,
+				run.run.AvgAcquisitionTime, run.run.P99AcquisitionTime, run.run.QueriesPerSecond))
+		}
+		sb.WriteString(fmt.Sprintf("  Warm minus cold: avg %v, p99 %v (%.1f%% of the cold average went to warming up)\n",
+			c.Warm.AvgAcquisitionTime-c.Cold.AvgAcquisitionTime, c.Warm.P99AcquisitionTime-c.Cold.P99AcquisitionTime, c.CacheShare()*100))
+		if c.StatsErr != "" {
+			sb.WriteString(fmt.Sprintf("  Buffer counters unavailable: %s\n", c.StatsErr))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
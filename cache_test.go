@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheStatsSubAndHitRatio(t *testing.T) {
+	before := CacheStats{HeapRead: 10, HeapHit: 100, IdxRead: 5, IdxHit: 50}
+	after := CacheStats{HeapRead: 30, HeapHit: 160, IdxRead: 5, IdxHit: 110}
+
+	delta := after.Sub(before)
+	if want := (CacheStats{HeapRead: 20, HeapHit: 60, IdxHit: 60}); delta != want {
+		t.Errorf("Sub = %+v, want %+v", delta, want)
+	}
+	if delta.Reads() != 20 {
+		t.Errorf("Reads = %d, want 20", delta.Reads())
+	}
+	if ratio, ok := delta.HitRatio(); !ok || ratio != 120.0/140.0 {
+		t.Errorf("HitRatio = %v, %v; want %v", ratio, ok, 120.0/140.0)
+	}
+
+	if _, ok := (CacheStats{}).HitRatio(); ok {
+		t.Error("no block accesses should have no hit ratio")
+	}
+	if got := (CacheStats{}).formatHitRatio(); got != "n/a" {
+		t.Errorf("formatHitRatio = %q, want n/a", got)
+	}
+}
+
+func TestReadCacheStats(t *testing.T) {
+	pool := newFakePooler(0)
+	pool.rows = [][]any{{int64(3), int64(97), int64(1), int64(49)}}
+
+	stats, err := readCacheStats(context.Background(), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CacheStats{HeapRead: 3, HeapHit: 97, IdxRead: 1, IdxHit: 49}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	pool.rows = nil
+	if _, err := readCacheStats(context.Background(), pool); err == nil {
+		t.Error("expected an error when benchmark_data has no statio row")
+	}
+}
+
+func TestCacheShare(t *testing.T) {
+	c := CacheTestResult{
+		Cold: CacheRun{AvgAcquisitionTime: 4 * time.Millisecond},
+		Warm: CacheRun{AvgAcquisitionTime: 3 * time.Millisecond},
+	}
+	if got := c.CacheShare(); got != 0.25 {
+		t.Errorf("CacheShare = %v, want 0.25", got)
+	}
+	if got := (CacheTestResult{}).CacheShare(); got != 0 {
+		t.Errorf("CacheShare without a cold run = %v, want 0", got)
+	}
+}
+
+func TestRenderCacheTests(t *testing.T) {
+	if got := renderCacheTests([]BenchmarkResult{{ConnectionType: PgBouncerSession}}); got != "" {
+		t.Errorf("without cache tests got %q, want empty", got)
+	}
+
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerTransaction, CacheTest: &CacheTestResult{
+			Concurrency: 100,
+			Cold:        CacheRun{AvgAcquisitionTime: 4 * time.Millisecond, Stats: CacheStats{HeapRead: 25, HeapHit: 75}},
+			Warm:        CacheRun{AvgAcquisitionTime: 3 * time.Millisecond, Stats: CacheStats{HeapHit: 100}},
+		}},
+		{ConnectionType: PgBouncerSession, CacheTest: &CacheTestResult{Concurrency: 100, StatsErr: "permission denied"}},
+	}
+	got := renderCacheTests(results)
+	for _, want := range []string{
+		"pgbouncer-transaction, concurrency 100:",
+		"75.0%",
+		"100.0%",
+		"avg -1ms",
+		"25.0% of the cold average went to warming up",
+		"Buffer counters unavailable: permission denied",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CancelledQueries counts queries cut off by their context mid-execution and
+// whether their connection went back to the pool afterwards. It is safe for
+// concurrent use; a nil value ignores additions.
+type CancelledQueries struct {
+	returned atomic.Int64
+	closed   atomic.Int64
+}
+
+func (c *CancelledQueries) add(returned bool) {
+	if c == nil {
+		return
+	}
+	if returned {
+		c.returned.Add(1)
+	} else {
+		c.closed.Add(1)
+	}
+}
+
+// Returned is the number of cancelled queries whose connection went back to the pool
+func (c *CancelledQueries) Returned() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.returned.Load()
+}
+
+// Closed is the number of cancelled queries whose connection was closed on
+// release, so the pool had to open a replacement
+func (c *CancelledQueries) Closed() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.closed.Load()
+}
+
+// queryCancelled reports whether err came from queryCtx cutting a query off.
+// pgx surfaces its context's error, but if the server handled the cancel
+// request first the query fails with 57014 instead, so the context decides.
+func queryCancelled(queryCtx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || queryCtx.Err() != nil
+}
+
+// connReusable reports whether releasing conn will return it to its pool
+// rather than close it. pgxpool destroys a connection that is closed, still
+// busy with a query or not idle outside a transaction, which is the state a
+// cancelled query usually leaves it in; database/sql discards closed ones.
+func connReusable(conn PooledConn) bool {
+	switch c := conn.(type) {
+	case *pgxpool.Conn:
+		return pgxConnReusable(c.Conn())
+	case *sqlConn:
+		return pgxConnReusable(c.Conn)
+	case interface{ Reusable() bool }:
+		return c.Reusable()
+	}
+	return true
+}
+
+func pgxConnReusable(conn *pgx.Conn) bool {
+	return !conn.IsClosed() && !conn.PgConn().IsBusy() && conn.PgConn().TxStatus() == 'I'
+}
+
+// cancellationError categorizes err as ErrCancelled when queryCtx cut the
+// query off, counting whether conn survives in cfg.Cancellations. Other
+// errors are returned as they are.
+func cancellationError(queryCtx context.Context, conn PooledConn, err error, cfg WorkerConfig) error {
+	if !queryCancelled(queryCtx, err) {
+		return err
+	}
+	cfg.Cancellations.add(connReusable(conn))
+	return &categorizedError{category: ErrCancelled, err: err}
+}
+
+// formatCancellations summarizes a run's cancelled queries, e.g.
+// "12 (timeout 50ms; connection returned 3, closed 9)"
+func formatCancellations(r BenchmarkResult) string {
+	return fmt.Sprintf("%d (timeout %v; connection returned %d, closed %d)",
+		r.CancelledQueries, r.QueryTimeout, r.CancelledConnsReturned, r.CancelledConnsClosed)
+}
+
+// renderCancellations totals each connection type's cancelled queries and the
+// connections they cost over its measured runs, or returns "" without -query-timeout
+func renderCancellations(results []BenchmarkResult) string {
+	type totals struct {
+		queries, cancelled, returned, closed, newConns int64
+		timeout                                        string
+	}
+	byType := make(map[ConnectionType]*totals)
+	for _, r := range results {
+		if r.IsWarmup || r.QueryTimeout <= 0 {
+			continue
+		}
+		t, ok := byType[r.ConnectionType]
+		if !ok {
+			t = &totals{timeout: r.QueryTimeout.String()}
+			byType[r.ConnectionType] = t
+		}
+		t.queries += int64(r.TotalQueries)
+		t.cancelled += r.CancelledQueries
+		t.returned += r.CancelledConnsReturned
+		t.closed += r.CancelledConnsClosed
+		t.newConns += r.NewConns
+	}
+	if len(byType) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("  %-26s %8s %16s %9s %8s %9s\n", "Connection Type", "Timeout", "Cancelled", "Returned", "Closed", "New Conns"))
+	for _, connType := range reportTypeOrder {
+		t, ok := byType[connType]
+		if !ok {
+			continue
+		}
+		var rate float64
+		if t.queries > 0 {
+			rate = float64(t.cancelled) / float64(t.queries) * 100
+		}
+		sb.WriteString(fmt.Sprintf("  %-26s %8s %16s %9d %8d %9d\n", connType, t.timeout,
+			fmt.Sprintf("%d (%.1f%%)", t.cancelled, rate), t.returned, t.closed, t.newConns))
+	}
+	return sb.String()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// hangingPooler wraps a fakePooler so its queries run until their context
+// ends, leaving the connection reusable or not
+type hangingPooler struct {
+	*fakePooler
+	reusable bool
+}
+
+func (p *hangingPooler) Acquire(ctx context.Context) (PooledConn, error) {
+	conn, err := p.fakePooler.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &hangingConn{conn.(*fakeConn), p.reusable}, nil
+}
+
+type hangingConn struct {
+	*fakeConn
+	reusable bool
+}
+
+func (c *hangingConn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("timeout: %w", ctx.Err())
+}
+
+func (c *hangingConn) Reusable() bool { return c.reusable }
+
+func TestExecuteWorkerQueryCancelledByQueryTimeout(t *testing.T) {
+	for _, reusable := range []bool{true, false} {
+		pool := &hangingPooler{newFakePooler(0), reusable}
+		cfg := testWorkerConfig()
+		cfg.QueryTimeout = 10 * time.Millisecond
+		cfg.Cancellations = &CancelledQueries{}
+
+		_, _, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg)
+		if got := errorCategory(err); got != ErrCancelled {
+			t.Fatalf("category = %s (err %v), want %s", got, err, ErrCancelled)
+		}
+		if returned, closed := cfg.Cancellations.Returned(), cfg.Cancellations.Closed(); reusable && (returned != 1 || closed != 0) ||
+			!reusable && (returned != 0 || closed != 1) {
+			t.Errorf("reusable=%v: returned %d, closed %d", reusable, returned, closed)
+		}
+		if pool.releases.Load() != 1 {
+			t.Errorf("connection not released after cancellation")
+		}
+	}
+
+	// The timeout only bounds the queries: a slow acquisition isn't cancelled
+	pool := newFakePooler(30 * time.Millisecond)
+	cfg := testWorkerConfig()
+	cfg.QueryTimeout = 10 * time.Millisecond
+	if _, _, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg); err != nil {
+		t.Errorf("query timeout applied to the acquisition: %v", err)
+	}
+}
+
+func TestQueryCancelledDecidedByContext(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	// The server got pgx's cancel request in before the deadline surfaced
+	serverCancel := &pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"}
+	if !queryCancelled(expired, serverCancel) {
+		t.Error("a 57014 after the deadline should count as cancelled")
+	}
+	if queryCancelled(context.Background(), serverCancel) {
+		t.Error("a 57014 without a deadline is someone else's cancel, not ours")
+	}
+	if !queryCancelled(context.Background(), fmt.Errorf("query: %w", context.DeadlineExceeded)) {
+		t.Error("a wrapped DeadlineExceeded should count as cancelled")
+	}
+}
+
+func TestRenderCancellations(t *testing.T) {
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerTransaction, QueryTimeout: 50 * time.Millisecond, IsWarmup: true, CancelledQueries: 100},
+		{ConnectionType: PgBouncerTransaction, QueryTimeout: 50 * time.Millisecond, TotalQueries: 200,
+			CancelledQueries: 20, CancelledConnsReturned: 2, CancelledConnsClosed: 18, NewConns: 18},
+		{ConnectionType: PgBouncerSession, QueryTimeout: 50 * time.Millisecond, TotalQueries: 200},
+	}
+	got := renderCancellations(results)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and a line per type:\n%s", got)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(lines[1]), string(PgBouncerSession)) {
+		t.Errorf("types should follow report order:\n%s", got)
+	}
+	if fields := strings.Fields(lines[2]); len(fields) != 7 || fields[2] != "20" || fields[3] != "(10.0%)" || fields[5] != "18" {
+		t.Errorf("transaction line = %q, want 20 (10.0%%) cancelled, 18 closed, warmup left out", lines[2])
+	}
+
+	if got := renderCancellations([]BenchmarkResult{{ConnectionType: PgBouncerSession, TotalQueries: 10}}); got != "" {
+		t.Errorf("expected no section without -query-timeout, got:\n%s", got)
+	}
+}
+
+func TestRunBenchmarkQueryTimeoutAgainstFakeServer(t *testing.T) {
+	server := startFakePGServer(t, 200*time.Millisecond)
+	opts, err := parseOptions([]string{"-quiet", "-log-level", "error", "-query-timeout", "20ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{ConnType: PgBouncerTransaction, DSN: server.DSN(), ExecMode: opts.ExecMode}
+	pools, err := NewPoolSet(config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pools.Close()
+
+	const concurrency = 4
+	r := runBenchmark(config, pools.Poolers(), pools.ConnectTimer(), concurrency, false, nil, opts)
+
+	if r.ErrorCategories[ErrCancelled] != concurrency || r.CancelledQueries != concurrency {
+		t.Fatalf("errors %v, cancelled %d, want all %d queries cancelled", r.ErrorCategories, r.CancelledQueries, concurrency)
+	}
+	// pgx closes a connection it had to interrupt mid-query, so none go back
+	if r.CancelledConnsClosed != concurrency {
+		t.Errorf("returned %d, closed %d, want every connection closed", r.CancelledConnsReturned, r.CancelledConnsClosed)
+	}
+}
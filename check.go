@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CheckTimeout bounds each connectivity check so an unreachable DSN fails fast
+const CheckTimeout = 5 * time.Second
+
+// checkConfig verifies a configuration is reachable and benchmark_data exists,
+// using a single-connection pool. It returns the row count on success.
+func checkConfig(ctx context.Context, config Config) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	poolConfig, err := newPoolConfig(config)
+	if err != nil {
+		return 0, fmt.Errorf("invalid DSN: %w", err)
+	}
+	poolConfig.MaxConns = 1
+	poolConfig.MinConns = 0
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create pool: %w", err)
+	}
+	defer pool.Close()
+
+	var one int
+	if err := pool.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil {
+		return 0, fmt.Errorf("SELECT 1 failed: %w", err)
+	}
+
+	var rows int64
+	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM benchmark_data").Scan(&rows); err != nil {
+		return 0, fmt.Errorf("benchmark_data is not readable: %w", err)
+	}
+
+	return rows, nil
+}
+
+// runChecks checks every configuration, and every endpoint of a sharded one,
+// prints pass/fail for each, and reports whether all of them passed
+func runChecks(configs []Config) bool {
+	fmt.Println("Checking connectivity...")
+
+	ok := true
+	for _, config := range configs {
+		for i, dsn := range config.endpoints() {
+			label := string(config.ConnType)
+			if len(config.DSNs) > 1 {
+				label += " " + endpointLabel(dsn, i)
+			}
+			rows, err := checkConfig(context.Background(), config.forPool(i))
+			if err != nil {
+				ok = false
+				fmt.Printf("  FAIL  %-22s %v\n", label, err)
+				continue
+			}
+			fmt.Printf("  PASS  %-22s benchmark_data has %d rows\n", label, rows)
+		}
+	}
+
+	return ok
+}
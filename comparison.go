@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// headToHeadMetric is one row of the session vs transaction comparison
+type headToHeadMetric struct {
+	Name         string
+	HigherBetter bool
+	Value        func(BenchmarkResult) float64
+	Format       func(float64) string
+}
+
+var headToHeadMetrics = []headToHeadMetric{
+	{
+		Name:   "Avg",
+		Value:  func(r BenchmarkResult) float64 { return float64(r.AvgAcquisitionTime) },
+		Format: func(v float64) string { return time.Duration(v).String() },
+	},
+	{
+		Name:   "P99",
+		Value:  func(r BenchmarkResult) float64 { return float64(r.P99AcquisitionTime) },
+		Format: func(v float64) string { return time.Duration(v).String() },
+	},
+	{
+		Name:         "QPS",
+		HigherBetter: true,
+		Value:        func(r BenchmarkResult) float64 { return r.QueriesPerSecond },
+		Format:       func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	},
+	{
+		Name:   "Release",
+		Value:  func(r BenchmarkResult) float64 { return float64(r.AvgReleaseTime) },
+		Format: func(v float64) string { return time.Duration(v).String() },
+	},
+}
+
+// renderHeadToHead compares the actual (non-warmup) runs of transaction mode against
+// session mode, and both against direct PostgreSQL, at each concurrency level.
+// When either side of a pair ran its own levels (-session-concurrency,
+// -transaction-concurrency), the pair is compared step by step through both
+// sweeps instead, e.g. session at 1000 against transaction at 5000.
+func renderHeadToHead(results []BenchmarkResult) string {
+	// Index the first actual result per connection type and concurrency level
+	byType := make(map[ConnectionType]map[int]BenchmarkResult)
+	overridden := make(map[ConnectionType]bool)
+	for _, r := range results {
+		if r.IsWarmup {
+			continue
+		}
+		if byType[r.ConnectionType] == nil {
+			byType[r.ConnectionType] = make(map[int]BenchmarkResult)
+		}
+		if _, ok := byType[r.ConnectionType][r.Concurrency]; !ok {
+			byType[r.ConnectionType][r.Concurrency] = r
+		}
+		overridden[r.ConnectionType] = overridden[r.ConnectionType] || r.ConcurrencyOverridden
+	}
+	levelsOf := func(connType ConnectionType) []int {
+		levels := make([]int, 0, len(byType[connType]))
+		for level := range byType[connType] {
+			levels = append(levels, level)
+		}
+		sort.Ints(levels)
+		return levels
+	}
+
+	pairs := [][2]ConnectionType{
+		{PgBouncerTransaction, PgBouncerSession},
+		{PgBouncerTransaction, DirectPostgres},
+		{PgBouncerSession, DirectPostgres},
+		{PgBouncerTransactionSQL, PgBouncerSessionSQL},
+		{PgBouncerSessionSQL, PgBouncerSession},
+		{PgBouncerTransactionSQL, PgBouncerTransaction},
+	}
+
+	// Comparisons are listed level by level (or step by step), pairs in the order above
+	type comparison struct {
+		order, pair int
+		a, b        BenchmarkResult
+	}
+	var comparisons []comparison
+	for i, pair := range pairs {
+		levelsA, levelsB := levelsOf(pair[0]), levelsOf(pair[1])
+		if overridden[pair[0]] || overridden[pair[1]] {
+			for step := 0; step < min(len(levelsA), len(levelsB)); step++ {
+				comparisons = append(comparisons, comparison{step, i,
+					byType[pair[0]][levelsA[step]], byType[pair[1]][levelsB[step]]})
+			}
+			continue
+		}
+		for _, level := range levelsA {
+			if b, ok := byType[pair[1]][level]; ok {
+				comparisons = append(comparisons, comparison{level, i, byType[pair[0]][level], b})
+			}
+		}
+	}
+	sort.SliceStable(comparisons, func(i, j int) bool {
+		if comparisons[i].order != comparisons[j].order {
+			return comparisons[i].order < comparisons[j].order
+		}
+		return comparisons[i].pair < comparisons[j].pair
+	})
+
+	var sb strings.Builder
+	for _, c := range comparisons {
+		a, b, pair := c.a, c.b, pairs[c.pair]
+		if a.Concurrency == b.Concurrency {
+			sb.WriteString(fmt.Sprintf("Concurrency %d: %s vs %s\n", a.Concurrency, pair[0], pair[1]))
+		} else {
+			sb.WriteString(fmt.Sprintf("Concurrency %d vs %d: %s vs %s\n", a.Concurrency, b.Concurrency, pair[0], pair[1]))
+		}
+		sb.WriteString(fmt.Sprintf("  %-7s %18s %18s %8s  %s\n", "Metric", pair[0], pair[1], "Ratio", "Winner"))
+		for _, m := range headToHeadMetrics {
+			va, vb := m.Value(a), m.Value(b)
+			ratio := "n/a"
+			if vb != 0 {
+				ratio = fmt.Sprintf("%.2fx", va/vb)
+			}
+			sb.WriteString(fmt.Sprintf("  %-7s %18s %18s %8s  %s\n",
+				m.Name, m.Format(va), m.Format(vb), ratio, headToHeadWinner(m, va, vb, pair)))
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// headToHeadWinner names the connection type with the better value for the metric
+func headToHeadWinner(m headToHeadMetric, a, b float64, pair [2]ConnectionType) string {
+	switch {
+	case a == b:
+		return "tie"
+	case (a > b) == m.HigherBetter:
+		return string(pair[0])
+	default:
+		return string(pair[1])
+	}
+}
+
+// WarmupNoiseThreshold is the relative change between warmup and actual runs
+// below which the difference is reported as noise
+const WarmupNoiseThreshold = 0.05
+
+var warmupMetrics = []headToHeadMetric{
+	{
+		Name:   "Total Duration",
+		Value:  func(r BenchmarkResult) float64 { return float64(r.TotalDuration) },
+		Format: func(v float64) string { return time.Duration(v).String() },
+	},
+	headToHeadMetrics[0],
+	headToHeadMetrics[1],
+	{
+		Name:   "New Conns",
+		Value:  func(r BenchmarkResult) float64 { return float64(r.NewConns) },
+		Format: func(v float64) string { return fmt.Sprintf("%.0f", v) },
+	},
+	{
+		Name:   "Connect Time",
+		Value:  func(r BenchmarkResult) float64 { return float64(r.NewConnEstablishTime) },
+		Format: func(v float64) string { return time.Duration(v).String() },
+	},
+	headToHeadMetrics[2],
+}
+
+// renderWarmupComparison tabulates how each metric changed from the cold-pool
+// warmup run to the warm-pool actual run. Improvements are positive; changes
+// smaller than WarmupNoiseThreshold are marked as noise.
+func renderWarmupComparison(warmup, actual BenchmarkResult) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("   %-14s %16s %16s %12s\n", "Metric", "Warmup (cold)", "Actual (warm)", "Improvement"))
+	for _, m := range warmupMetrics {
+		w, a := m.Value(warmup), m.Value(actual)
+
+		improvement, note := "n/a", ""
+		if w != 0 {
+			change := (w - a) / w
+			if m.HigherBetter {
+				change = -change
+			}
+			improvement = fmt.Sprintf("%+.2f%%", change*100)
+			if math.Abs(change) < WarmupNoiseThreshold {
+				note = "  (within noise)"
+			}
+		}
+		sb.WriteString(fmt.Sprintf("   %-14s %16s %16s %12s%s\n", m.Name, m.Format(w), m.Format(a), improvement, note))
+	}
+	return sb.String()
+}
+
+// concurrencyOverrideLines lists, for the report header, the levels each
+// connection type ran at when any ran its own (-session-concurrency,
+// -transaction-concurrency), so unequal head-to-head pairs read as intended
+func concurrencyOverrideLines(results []BenchmarkResult) []string {
+	levels := make(map[ConnectionType][]int)
+	overridden := false
+	for _, r := range results {
+		if r.IsWarmup {
+			continue
+		}
+		overridden = overridden || r.ConcurrencyOverridden
+		if !slices.Contains(levels[r.ConnectionType], r.Concurrency) {
+			levels[r.ConnectionType] = append(levels[r.ConnectionType], r.Concurrency)
+		}
+	}
+	if !overridden {
+		return nil
+	}
+	var lines []string
+	for _, connType := range reportTypeOrder {
+		if l, ok := levels[connType]; ok {
+			slices.Sort(l)
+			lines = append(lines, fmt.Sprintf("Concurrency (%s): %v", connType, l))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderHeadToHeadSkipsWarmupAndPicksWinner(t *testing.T) {
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerSession, Concurrency: 10, IsWarmup: true, AvgAcquisitionTime: time.Millisecond},
+		{ConnectionType: PgBouncerSession, Concurrency: 10, AvgAcquisitionTime: 4 * time.Millisecond, P99AcquisitionTime: 8 * time.Millisecond, QueriesPerSecond: 100},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 10, AvgAcquisitionTime: 2 * time.Millisecond, P99AcquisitionTime: 8 * time.Millisecond, QueriesPerSecond: 200},
+	}
+	results[1].AvgReleaseTime = 10 * time.Microsecond
+	results[2].AvgReleaseTime = 30 * time.Microsecond
+
+	out := renderHeadToHead(results)
+	if !strings.Contains(out, "Concurrency 10: pgbouncer-transaction vs pgbouncer-session") {
+		t.Fatalf("missing comparison header:\n%s", out)
+	}
+	if strings.Contains(out, "vs direct") {
+		t.Errorf("unexpected direct comparison without direct results:\n%s", out)
+	}
+
+	for _, want := range []string{"0.50x  pgbouncer-transaction", "1.00x  tie", "2.00x  pgbouncer-transaction", "3.00x  pgbouncer-session"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderWarmupComparison(t *testing.T) {
+	warmup := BenchmarkResult{
+		TotalDuration:        100 * time.Millisecond,
+		AvgAcquisitionTime:   10 * time.Millisecond,
+		P99AcquisitionTime:   40 * time.Millisecond,
+		NewConns:             50,
+		NewConnEstablishTime: 200 * time.Millisecond,
+		QueriesPerSecond:     1000,
+	}
+	actual := BenchmarkResult{
+		TotalDuration:      98 * time.Millisecond,
+		AvgAcquisitionTime: 2 * time.Millisecond,
+		P99AcquisitionTime: 10 * time.Millisecond,
+		QueriesPerSecond:   1500,
+	}
+
+	out := renderWarmupComparison(warmup, actual)
+	for _, want := range []string{"+2.00%  (within noise)", "+80.00%", "+75.00%", "+100.00%", "+50.00%"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "within noise") != 1 {
+		t.Errorf("expected exactly one noise flag:\n%s", out)
+	}
+
+	// Nothing to compare against when the warmup opened no connections
+	if out := renderWarmupComparison(BenchmarkResult{}, actual); !strings.Contains(out, "n/a") {
+		t.Errorf("expected n/a for zero warmup values:\n%s", out)
+	}
+}
+
+func TestRenderHeadToHeadPairsOverriddenLevelsByStep(t *testing.T) {
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerSession, Concurrency: 1000, QueriesPerSecond: 100, ConcurrencyOverridden: true},
+		{ConnectionType: PgBouncerSession, Concurrency: 100, QueriesPerSecond: 50, ConcurrencyOverridden: true},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 500, QueriesPerSecond: 150},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 5000, QueriesPerSecond: 400},
+		{ConnectionType: DirectPostgres, Concurrency: 500, QueriesPerSecond: 300},
+	}
+
+	out := renderHeadToHead(results)
+	first := strings.Index(out, "Concurrency 500 vs 100: pgbouncer-transaction vs pgbouncer-session")
+	second := strings.Index(out, "Concurrency 5000 vs 1000: pgbouncer-transaction vs pgbouncer-session")
+	if first < 0 || second < first {
+		t.Fatalf("expected step-by-step comparisons in order:\n%s", out)
+	}
+	// Neither side overridden: still compared at the same level only
+	if !strings.Contains(out, "Concurrency 500: pgbouncer-transaction vs direct") || strings.Contains(out, "5000: pgbouncer-transaction vs direct") {
+		t.Errorf("expected transaction vs direct at 500 only:\n%s", out)
+	}
+
+	lines := concurrencyOverrideLines(results)
+	if len(lines) != 3 || lines[1] != "Concurrency (pgbouncer-session): [100 1000]" {
+		t.Errorf("got header lines %q", lines)
+	}
+	if concurrencyOverrideLines(results[2:]) != nil {
+		t.Error("expected no header lines without overrides")
+	}
+}
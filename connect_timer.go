@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ConnectTimer is a pgx tracer that times the establishment of new connections
+// (dial, TLS, auth and PgBouncer handshake). It is safe for concurrent use and
+// can be shared by every pool of a PoolSet.
+type ConnectTimer struct {
+	count atomic.Int64
+	total atomic.Int64 // nanoseconds
+}
+
+// ConnectStats is a snapshot of a ConnectTimer
+type ConnectStats struct {
+	NewConns      int64
+	EstablishTime time.Duration
+}
+
+// Sub returns the connections established between an earlier snapshot and s
+func (s ConnectStats) Sub(earlier ConnectStats) ConnectStats {
+	return ConnectStats{
+		NewConns:      s.NewConns - earlier.NewConns,
+		EstablishTime: s.EstablishTime - earlier.EstablishTime,
+	}
+}
+
+// Snapshot returns the totals so far. A nil timer reports nothing.
+func (t *ConnectTimer) Snapshot() ConnectStats {
+	if t == nil {
+		return ConnectStats{}
+	}
+	return ConnectStats{NewConns: t.count.Load(), EstablishTime: time.Duration(t.total.Load())}
+}
+
+type connectStartKey struct{}
+
+// TraceConnectStart implements pgx.ConnectTracer
+func (t *ConnectTimer) TraceConnectStart(ctx context.Context, _ pgx.TraceConnectStartData) context.Context {
+	return context.WithValue(ctx, connectStartKey{}, time.Now())
+}
+
+// TraceConnectEnd implements pgx.ConnectTracer. Failed connects are not counted.
+func (t *ConnectTimer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	start, ok := ctx.Value(connectStartKey{}).(time.Time)
+	if !ok || data.Err != nil {
+		return
+	}
+	t.count.Add(1)
+	t.total.Add(int64(time.Since(start)))
+}
+
+// TraceQueryStart implements pgx.QueryTracer, which ConnConfig.Tracer requires
+func (t *ConnectTimer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (t *ConnectTimer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
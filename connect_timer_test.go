@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestConnectTimerCountsSuccessfulConnects(t *testing.T) {
+	timer := &ConnectTimer{}
+	before := timer.Snapshot()
+
+	ctx := timer.TraceConnectStart(context.Background(), pgx.TraceConnectStartData{})
+	time.Sleep(5 * time.Millisecond)
+	timer.TraceConnectEnd(ctx, pgx.TraceConnectEndData{})
+
+	failed := timer.TraceConnectStart(context.Background(), pgx.TraceConnectStartData{})
+	timer.TraceConnectEnd(failed, pgx.TraceConnectEndData{Err: errors.New("refused")})
+
+	delta := timer.Snapshot().Sub(before)
+	if delta.NewConns != 1 {
+		t.Errorf("NewConns = %d, want 1", delta.NewConns)
+	}
+	if delta.EstablishTime < 5*time.Millisecond {
+		t.Errorf("EstablishTime = %v, want at least 5ms", delta.EstablishTime)
+	}
+
+	var nilTimer *ConnectTimer
+	if nilTimer.Snapshot() != (ConnectStats{}) {
+		t.Error("nil timer should report nothing")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// How slowest traces are ranked
+const (
+	TraceSortWall         = "wall"
+	TraceSortCriticalPath = "critical-path"
+)
+
+// criticalPath returns the critical-path duration of a trace: the span named
+// rootSpanName with the longest critical path, or the longest among the root
+// spans (those whose parent wasn't collected) when there is no such span
+func criticalPath(spans []sdktrace.ReadOnlySpan, rootSpanName string) time.Duration {
+	inTrace := make(map[trace.SpanID]bool, len(spans))
+	for _, span := range spans {
+		inTrace[span.SpanContext().SpanID()] = true
+	}
+
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+	var roots, named []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		parent := span.Parent()
+		if parent.IsValid() && inTrace[parent.SpanID()] {
+			children[parent.SpanID()] = append(children[parent.SpanID()], span)
+		} else {
+			roots = append(roots, span)
+		}
+		if span.Name() == rootSpanName {
+			named = append(named, span)
+		}
+	}
+
+	candidates := roots
+	if len(named) > 0 {
+		candidates = named
+	}
+
+	var longest time.Duration
+	for _, span := range candidates {
+		longest = max(longest, spanCriticalPath(span, children))
+	}
+	return longest
+}
+
+// spanCriticalPath is the time span spends on its own work, i.e. not covered by
+// any child, plus the heaviest chain of non-overlapping children, each child
+// weighted by its own critical path. Sequential children all count; of
+// concurrent ones, only the chain that dominates does.
+func spanCriticalPath(span sdktrace.ReadOnlySpan, children map[trace.SpanID][]sdktrace.ReadOnlySpan) time.Duration {
+	kids := append([]sdktrace.ReadOnlySpan(nil), children[span.SpanContext().SpanID()]...)
+	if len(kids) == 0 {
+		return span.EndTime().Sub(span.StartTime())
+	}
+
+	// Weighted interval scheduling over the children, ordered by end time
+	sort.Slice(kids, func(i, j int) bool { return kids[i].EndTime().Before(kids[j].EndTime()) })
+	best := make([]time.Duration, len(kids)+1) // best[i]: heaviest chain among the first i children
+	for i, kid := range kids {
+		// Latest earlier child that ends before this one starts
+		prev := sort.Search(i, func(j int) bool { return kids[j].EndTime().After(kid.StartTime()) })
+		best[i+1] = max(best[i], best[prev]+spanCriticalPath(kid, children))
+	}
+
+	return uncoveredTime(span, kids) + best[len(kids)]
+}
+
+// uncoveredTime is the part of span's duration not covered by any of kids,
+// which must be sorted by end time
+func uncoveredTime(span sdktrace.ReadOnlySpan, kids []sdktrace.ReadOnlySpan) time.Duration {
+	intervals := make([][2]time.Time, len(kids))
+	for i, kid := range kids {
+		intervals[i] = [2]time.Time{kid.StartTime(), kid.EndTime()}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i][0].Before(intervals[j][0]) })
+
+	var covered time.Duration
+	var cursor time.Time
+	for _, iv := range intervals {
+		start, end := iv[0], iv[1]
+		if start.Before(span.StartTime()) {
+			start = span.StartTime()
+		}
+		if end.After(span.EndTime()) {
+			end = span.EndTime()
+		}
+		if start.Before(cursor) {
+			start = cursor
+		}
+		if end.After(start) {
+			covered += end.Sub(start)
+			cursor = end
+		}
+	}
+
+	return max(span.EndTime().Sub(span.StartTime())-covered, 0)
+}
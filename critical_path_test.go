@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCriticalPathSkipsConcurrentChildren(t *testing.T) {
+	collector, cleanup, err := InitTracer("test-service")
+	if err != nil {
+		t.Fatalf("Failed to initialize tracer: %v", err)
+	}
+	defer cleanup()
+
+	tracer := GetTracer("test")
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	child := func(ctx context.Context, name string, from, to int) {
+		_, span := tracer.Start(ctx, name, trace.WithTimestamp(at(from)))
+		span.End(trace.WithTimestamp(at(to)))
+	}
+
+	// Sequential children: the critical path is the wall-clock duration
+	ctx, root := tracer.Start(context.Background(), RootSpanName, trace.WithTimestamp(at(0)))
+	child(ctx, "pool.acquire_connection", 0, 3)
+	child(ctx, "db.query", 3, 7)
+	root.End(trace.WithTimestamp(start.Add(9500 * time.Microsecond)))
+
+	// Overlapping children 1-6ms and 2-8ms then 8-9ms: 2ms of own work plus
+	// the heavier chain 2-8ms → 8-9ms
+	ctx, root = tracer.Start(context.Background(), RootSpanName, trace.WithTimestamp(at(0)))
+	child(ctx, "a", 1, 6)
+	child(ctx, "b", 2, 8)
+	child(ctx, "c", 8, 9)
+	root.End(trace.WithTimestamp(at(10)))
+
+	byWall := FindSlowestTracesSorted(collector, RootSpanName, TraceSortWall, 2)
+	if byWall[0].Duration != 10*time.Millisecond || byWall[0].CriticalPath != 9*time.Millisecond {
+		t.Errorf("concurrent trace: wall %v, critical path %v; want 10ms, 9ms", byWall[0].Duration, byWall[0].CriticalPath)
+	}
+	if byWall[1].Duration != 9500*time.Microsecond || byWall[1].CriticalPath != 9500*time.Microsecond {
+		t.Errorf("sequential trace: wall %v, critical path %v; want 9.5ms, 9.5ms", byWall[1].Duration, byWall[1].CriticalPath)
+	}
+
+	byCriticalPath := FindSlowestTracesSorted(collector, RootSpanName, TraceSortCriticalPath, 1)
+	if byCriticalPath[0].CriticalPath != 9500*time.Microsecond {
+		t.Errorf("slowest by critical path = %v, want the sequential trace's 9.5ms", byCriticalPath[0].CriticalPath)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ExportAcquisitionTimesCSV writes one row per query with its acquisition time
+// and the offset from run start at which the issuing worker was launched.
+// Failed queries (recorded as 0) are kept and marked succeeded=false so the
+// row count always matches the number of queries run (the concurrency in burst mode).
+func ExportAcquisitionTimesCSV(result BenchmarkResult, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"worker_id", "pool_index", "duration_ns", "succeeded", "arrival_offset_ns"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	poolInstances := result.PoolInstances
+	if poolInstances <= 0 {
+		poolInstances = 1
+	}
+
+	for i, duration := range result.AcquisitionTimes {
+		// Without recorded worker IDs, entry i belongs to worker i
+		workerID := i
+		if i < len(result.WorkerIDs) {
+			workerID = result.WorkerIDs[i]
+		}
+
+		var arrival time.Duration
+		if workerID < len(result.ArrivalOffsets) {
+			arrival = result.ArrivalOffsets[workerID]
+		}
+
+		row := []string{
+			strconv.Itoa(workerID),
+			strconv.Itoa(workerID % poolInstances),
+			strconv.FormatInt(duration.Nanoseconds(), 10),
+			strconv.FormatBool(duration != 0),
+			strconv.FormatInt(arrival.Nanoseconds(), 10),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// acquisitionCSVFilename builds a timestamped CSV filename for a benchmark result
+func acquisitionCSVFilename(result BenchmarkResult) string {
+	return exportFilename("acquisition_times", result.ConnectionType, runDetail(result.Concurrency, result.IsWarmup, result.Iteration, ""), time.Now(), ".csv")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportAcquisitionTimesCSV(t *testing.T) {
+	result := BenchmarkResult{
+		ConnectionType:   PgBouncerTransaction,
+		Concurrency:      4,
+		PoolInstances:    3,
+		AcquisitionTimes: []time.Duration{5 * time.Millisecond, 0, 7 * time.Millisecond, 0},
+	}
+
+	filename := filepath.Join(t.TempDir(), "times.csv")
+	if err := ExportAcquisitionTimesCSV(result, filename); err != nil {
+		t.Fatalf("Failed to export CSV: %v", err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open CSV: %v", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	// Header plus one row per worker, including failed ones
+	if len(records) != result.Concurrency+1 {
+		t.Fatalf("Expected %d rows, got %d", result.Concurrency+1, len(records))
+	}
+
+	if got := records[2]; got[1] != "1" || got[2] != "0" || got[3] != "false" {
+		t.Errorf("Expected failed worker row [1 1 0 false], got %v", got)
+	}
+	if got := records[3]; got[1] != "2" || got[2] != "7000000" || got[3] != "true" {
+		t.Errorf("Expected worker row [2 2 7000000 true], got %v", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CurvePoint is one concurrency level of a connection type's throughput curve,
+// averaged over iterations
+type CurvePoint struct {
+	Concurrency int
+	QPS         float64
+	P99         time.Duration
+}
+
+// concurrencyCurves groups the actual runs into one curve per connection type,
+// sorted by concurrency
+func concurrencyCurves(results []BenchmarkResult) map[ConnectionType][]CurvePoint {
+	curves := make(map[ConnectionType][]CurvePoint)
+	for _, s := range aggregateIterations(results) {
+		curves[s.ConnectionType] = append(curves[s.ConnectionType], CurvePoint{
+			Concurrency: s.Concurrency,
+			QPS:         s.QPS,
+			P99:         s.P99,
+		})
+	}
+	for _, curve := range curves {
+		sort.Slice(curve, func(i, j int) bool { return curve[i].Concurrency < curve[j].Concurrency })
+	}
+	return curves
+}
+
+// peakQPSIndex returns the index of the point with the highest QPS. Beyond it, adding
+// concurrency only adds latency: that level is the knee where the mode saturates.
+func peakQPSIndex(curve []CurvePoint) int {
+	peak := 0
+	for i, p := range curve {
+		if p.QPS > curve[peak].QPS {
+			peak = i
+		}
+	}
+	return peak
+}
+
+// renderConcurrencyCurves tabulates QPS and p99 against concurrency per connection
+// type, with the change from the previous level, or returns "" when only one
+// level was run
+func renderConcurrencyCurves(results []BenchmarkResult) string {
+	curves := concurrencyCurves(results)
+
+	var sb strings.Builder
+	for _, connType := range sortedConnTypes(curves) {
+		curve := curves[connType]
+		if len(curve) < 2 {
+			continue
+		}
+
+		peak := peakQPSIndex(curve)
+		sb.WriteString(fmt.Sprintf("%s\n", connType))
+		sb.WriteString(fmt.Sprintf("  %11s %12s %9s %14s %9s\n", "Concurrency", "QPS", "ΔQPS", "P99", "ΔP99"))
+		for i, p := range curve {
+			qpsChange, p99Change := "", ""
+			if i > 0 {
+				prev := curve[i-1]
+				qpsChange = relativeChange(prev.QPS, p.QPS)
+				p99Change = relativeChange(float64(prev.P99), float64(p.P99))
+			}
+			marker := ""
+			if i == peak {
+				marker = "  ← peak QPS"
+			}
+			sb.WriteString(fmt.Sprintf("  %11d %12.2f %9s %14v %9s%s\n",
+				p.Concurrency, p.QPS, qpsChange, p.P99.Round(time.Microsecond), p99Change, marker))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// relativeChange formats the change from before to after as a signed percentage
+func relativeChange(before, after float64) string {
+	if before == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.0f%%", (after-before)/before*100)
+}
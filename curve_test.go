@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderConcurrencyCurves(t *testing.T) {
+	ms := time.Millisecond
+	results := []BenchmarkResult{
+		// Out of order on purpose; the curve is sorted by concurrency
+		{ConnectionType: PgBouncerTransaction, Concurrency: 500, QueriesPerSecond: 900, P99AcquisitionTime: 40 * ms},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 100, QueriesPerSecond: 400, P99AcquisitionTime: 10 * ms},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 1000, QueriesPerSecond: 850, P99AcquisitionTime: 120 * ms},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 1000, IsWarmup: true, QueriesPerSecond: 5000},
+		// A single level has no curve
+		{ConnectionType: PgBouncerSession, Concurrency: 100, QueriesPerSecond: 300},
+	}
+
+	curve := concurrencyCurves(results)[PgBouncerTransaction]
+	if len(curve) != 3 || curve[0].Concurrency != 100 || curve[2].Concurrency != 1000 {
+		t.Fatalf("curve = %+v", curve)
+	}
+	if peak := peakQPSIndex(curve); curve[peak].Concurrency != 500 {
+		t.Errorf("peak at %d, want 500", curve[peak].Concurrency)
+	}
+
+	out := renderConcurrencyCurves(results)
+	if strings.Contains(out, string(PgBouncerSession)) {
+		t.Errorf("single-level session curve rendered:\n%s", out)
+	}
+	for _, want := range []string{"+125%", "+300%", "← peak QPS", "-6%", "+200%"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	if renderConcurrencyCurves(results[4:]) != "" {
+		t.Error("expected no curves with a single level")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+)
+
+// minDispatchTick bounds how often the rate-limited dispatcher wakes up; at
+// higher rates several queries are released per tick
+const minDispatchTick = time.Millisecond
+
+// dispatchAtRate schedules queries at a fixed rate between start and deadline,
+// distributing them round-robin across one channel per pool instance that has
+// workers reading from it. Each job carries the time the query was scheduled
+// for, so workers can measure queue wait and latency from the intended start
+// rather than from when they got to it. All channels are closed once the
+// deadline is reached.
+func dispatchAtRate(qps float64, start, deadline time.Time, jobs []chan time.Time) {
+	defer func() {
+		for _, ch := range jobs {
+			close(ch)
+		}
+	}()
+
+	interval := time.Duration(float64(time.Second) / qps)
+	ticker := time.NewTicker(max(interval, minDispatchTick))
+	defer ticker.Stop()
+
+	seq := 0
+	for now := range ticker.C {
+		// Release every query scheduled up to now, catching up on any missed ticks
+		for {
+			scheduledAt := start.Add(time.Duration(seq) * interval)
+			if !scheduledAt.Before(deadline) {
+				return
+			}
+			if scheduledAt.After(now) {
+				break
+			}
+			jobs[seq%len(jobs)] <- scheduledAt
+			seq++
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDispatchAtRate(t *testing.T) {
+	jobs := []chan time.Time{make(chan time.Time, 100), make(chan time.Time, 100)}
+	start := time.Now()
+	deadline := start.Add(100 * time.Millisecond)
+
+	dispatchAtRate(200, start, deadline, jobs)
+
+	// 200 QPS for 100ms schedules 20 queries, alternating between the two pools
+	var counts [2]int
+	var previous time.Time
+	for i, ch := range jobs {
+		for scheduledAt := range ch {
+			counts[i]++
+			if scheduledAt.Before(start) || !scheduledAt.Before(deadline) {
+				t.Errorf("Scheduled time %v outside [%v, %v)", scheduledAt, start, deadline)
+			}
+			if i == 0 && !previous.IsZero() && scheduledAt.Sub(previous) != 10*time.Millisecond {
+				t.Errorf("Expected pool 0 jobs 10ms apart, got %v", scheduledAt.Sub(previous))
+			}
+			if i == 0 {
+				previous = scheduledAt
+			}
+		}
+	}
+
+	if counts[0] != 10 || counts[1] != 10 {
+		t.Errorf("Expected 10 jobs per pool, got %v", counts)
+	}
+
+	// A single channel, as when one worker serves the run, receives every job
+	single := []chan time.Time{make(chan time.Time, 100)}
+	dispatchAtRate(200, start, deadline, single)
+	if n := len(single[0]); n != 20 {
+		t.Errorf("Expected all 20 jobs on the single channel, got %d", n)
+	}
+}
+
+func TestRunBenchmarkAtRateWithFewerWorkersThanPools(t *testing.T) {
+	pools := []Pooler{newFakePooler(0), newFakePooler(0), newFakePooler(0), newFakePooler(0)}
+	opts := Options{Seed: 1, TargetQPS: 200, Duration: 200 * time.Millisecond}
+
+	// Two workers serve only two of the four pools, so every scheduled query has
+	// to land on their channels for the offered load to hold
+	result := runBenchmark(Config{ConnType: DirectPostgres}, pools, nil, 2, false, nil, opts)
+	if result.TotalQueries < 35 || result.TotalQueries > 40 {
+		t.Errorf("TotalQueries = %d, want about 40 at 200 QPS for 200ms", result.TotalQueries)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Environment variables holding the full DSN of each server, so credentials
+// stay out of the code and the command line
+const (
+	EnvDSNDirect      = "DSN_DIRECT"
+	EnvDSNSession     = "DSN_SESSION"
+	EnvDSNTransaction = "DSN_TRANSACTION"
+)
+
+// Ports of the docker-compose servers the default DSNs connect to
+const (
+	DirectPostgresPort       = 5432
+	PgBouncerSessionPort     = 6432
+	PgBouncerTransactionPort = 6433
+)
+
+// DSNs are the servers a run connects to: PostgreSQL itself for schema
+// changes and pg_stat_activity, and the session and transaction mode PgBouncers
+type DSNs struct {
+	Direct      string
+	Session     string
+	Transaction string
+}
+
+// resolveDSNs takes each server's DSN from its DSN_* environment variable,
+// falling back to the docker-compose default. getenv is os.Getenv outside tests.
+//
+// Whatever a DSN leaves out, pgx fills in from the standard PG* variables
+// (PGHOST, PGPORT, PGUSER, PGPASSWORD, ...) when it parses it. The defaults
+// leave out each of host, user, password and database whose PG* variable is
+// set, so those work on their own too; they keep their own ports, as the three
+// servers can't share one PGPORT.
+func resolveDSNs(getenv func(string) string) DSNs {
+	return DSNs{
+		Direct:      envDSN(getenv, EnvDSNDirect, DirectPostgresPort),
+		Session:     envDSN(getenv, EnvDSNSession, PgBouncerSessionPort),
+		Transaction: envDSN(getenv, EnvDSNTransaction, PgBouncerTransactionPort),
+	}
+}
+
+func envDSN(getenv func(string) string, name string, port int) string {
+	if dsn := strings.TrimSpace(getenv(name)); dsn != "" {
+		return dsn
+	}
+	return defaultDSN(getenv, port)
+}
+
+// defaultDSN is the docker-compose DSN of the server on port, in keyword/value
+// form so the parts set by PG* variables can be left for pgx to fill in
+func defaultDSN(getenv func(string) string, port int) string {
+	var parts []string
+	for _, p := range []struct{ env, keyword, value string }{
+		{"PGHOST", "host", "localhost"},
+		{"PGUSER", "user", "benchuser"},
+		{"PGPASSWORD", "password", "benchpass"},
+		{"PGDATABASE", "dbname", "benchdb"},
+	} {
+		if getenv(p.env) == "" {
+			parts = append(parts, p.keyword+"="+p.value)
+		}
+	}
+	parts = append(parts, fmt.Sprintf("port=%d", port))
+	if getenv("PGSSLMODE") == "" {
+		parts = append(parts, "sslmode=disable")
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func envFrom(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestResolveDSNsDefaults(t *testing.T) {
+	dsns := resolveDSNs(envFrom(nil))
+	for _, c := range []struct {
+		dsn  string
+		port uint16
+	}{
+		{dsns.Direct, DirectPostgresPort},
+		{dsns.Session, PgBouncerSessionPort},
+		{dsns.Transaction, PgBouncerTransactionPort},
+	} {
+		cfg, err := pgconn.ParseConfig(c.dsn)
+		if err != nil {
+			t.Fatalf("ParseConfig(%q): %v", c.dsn, err)
+		}
+		if cfg.Host != "localhost" || cfg.Port != c.port || cfg.User != "benchuser" || cfg.Password != "benchpass" || cfg.Database != "benchdb" {
+			t.Errorf("%q parsed to %s@%s:%d/%s", c.dsn, cfg.User, cfg.Host, cfg.Port, cfg.Database)
+		}
+	}
+}
+
+func TestResolveDSNsFromDSNVariables(t *testing.T) {
+	dsns := resolveDSNs(envFrom(map[string]string{
+		EnvDSNSession:     " postgres://u:p@session-host:7432/db ",
+		EnvDSNTransaction: "postgres://u:p@tx-host:7433/db",
+	}))
+	if dsns.Session != "postgres://u:p@session-host:7432/db" || dsns.Transaction != "postgres://u:p@tx-host:7433/db" {
+		t.Errorf("DSN_* variables not used as given: %+v", dsns)
+	}
+	if dsns.Direct != defaultDSN(envFrom(nil), DirectPostgresPort) {
+		t.Errorf("unset %s should keep the default, got %q", EnvDSNDirect, dsns.Direct)
+	}
+}
+
+func TestResolveDSNsPGVariablesFillIn(t *testing.T) {
+	env := map[string]string{
+		"PGHOST":     "pg.internal",
+		"PGUSER":     "alice",
+		"PGPASSWORD": "s3cret",
+		"PGPORT":     "7000",
+		EnvDSNDirect: "postgres:///benchdb?sslmode=disable",
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	dsns := resolveDSNs(envFrom(env))
+
+	// The defaults leave host and credentials to pgx, but keep their own port
+	session, err := pgconn.ParseConfig(dsns.Session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Host != "pg.internal" || session.User != "alice" || session.Password != "s3cret" || session.Port != PgBouncerSessionPort {
+		t.Errorf("session default parsed to %s:%s@%s:%d", session.User, session.Password, session.Host, session.Port)
+	}
+
+	// A DSN_* value takes whatever it leaves out, port included, from PG*
+	direct, err := pgconn.ParseConfig(dsns.Direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct.Host != "pg.internal" || direct.User != "alice" || direct.Password != "s3cret" || direct.Port != 7000 || direct.Database != "benchdb" {
+		t.Errorf("%s parsed to %s:%s@%s:%d/%s", EnvDSNDirect, direct.User, direct.Password, direct.Host, direct.Port, direct.Database)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrorCategory classifies a failed query so modes can be compared by how they break
+type ErrorCategory string
+
+const (
+	ErrPreparedStatementMissing ErrorCategory = "prepared_statement_missing"
+	ErrPreparedStatementExists  ErrorCategory = "prepared_statement_exists"
+	ErrCachedPlanChanged        ErrorCategory = "cached_plan_changed"
+	ErrTooManyConnections       ErrorCategory = "too_many_connections"
+	ErrQueryCanceled            ErrorCategory = "query_canceled"
+	ErrAdminShutdown            ErrorCategory = "admin_shutdown"
+	ErrTimeout                  ErrorCategory = "timeout"
+	ErrAcquireTimedOut          ErrorCategory = "acquire_timed_out"
+	ErrCancelled                ErrorCategory = "cancelled" // Cut off mid-execution by -query-timeout
+	ErrConnection               ErrorCategory = "connection"
+	ErrOther                    ErrorCategory = "other"
+)
+
+// classifyError maps a query error to an ErrorCategory by SQLSTATE, falling back to
+// the message for errors PgBouncer reports without a usable code. PgBouncer also
+// reports prepared statement errors as protocol violations (08P01), so the
+// connection exception class only applies when the message says nothing more specific.
+func classifyError(err error) ErrorCategory {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "26000":
+			return ErrPreparedStatementMissing
+		case "42P05":
+			return ErrPreparedStatementExists
+		case "0A000":
+			if strings.Contains(pgErr.Message, "cached plan") {
+				return ErrCachedPlanChanged
+			}
+		case "53300":
+			return ErrTooManyConnections
+		case "57014":
+			return ErrQueryCanceled
+		case "57P01":
+			return ErrAdminShutdown
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrQueryCanceled
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "prepared statement") && strings.Contains(msg, "does not exist"):
+		return ErrPreparedStatementMissing
+	case strings.Contains(msg, "prepared statement") && strings.Contains(msg, "already exists"):
+		return ErrPreparedStatementExists
+	case strings.Contains(msg, "cached plan must not change result type"):
+		return ErrCachedPlanChanged
+	case strings.Contains(msg, "no more connections allowed"), strings.Contains(msg, "too many clients"):
+		return ErrTooManyConnections
+	case pgconn.SafeToRetry(err), strings.Contains(msg, "failed to connect"), strings.Contains(msg, "connection refused"):
+		return ErrConnection
+	case pgErr != nil && strings.HasPrefix(pgErr.Code, "08"):
+		return ErrConnection
+	}
+
+	return ErrOther
+}
+
+// categorizedError carries an ErrorCategory decided where the error happened,
+// for failures classifyError can't tell apart from the error alone
+type categorizedError struct {
+	category ErrorCategory
+	err      error
+}
+
+func (e *categorizedError) Error() string { return e.err.Error() }
+func (e *categorizedError) Unwrap() error { return e.err }
+
+// errorCategory returns the category attached to err, or classifies it
+func errorCategory(err error) ErrorCategory {
+	var ce *categorizedError
+	if errors.As(err, &ce) {
+		return ce.category
+	}
+	return classifyError(err)
+}
+
+// mergeErrorCounts sums per-worker error tallies into a single map
+func mergeErrorCounts(perWorker []map[ErrorCategory]int) map[ErrorCategory]int {
+	merged := make(map[ErrorCategory]int)
+	for _, counts := range perWorker {
+		for category, n := range counts {
+			merged[category] += n
+		}
+	}
+	return merged
+}
+
+// formatErrorCounts renders error tallies as "category=n" pairs, most frequent first
+func formatErrorCounts(counts map[ErrorCategory]int) string {
+	categories := make([]ErrorCategory, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if counts[categories[i]] != counts[categories[j]] {
+			return counts[categories[i]] > counts[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	parts := make([]string, len(categories))
+	for i, category := range categories {
+		parts[i] = fmt.Sprintf("%s=%d", category, counts[category])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCategory
+	}{
+		{&pgconn.PgError{Code: "26000", Message: `prepared statement "stmtcache_1" does not exist`}, ErrPreparedStatementMissing},
+		{fmt.Errorf("query: %w", &pgconn.PgError{Code: "42P05", Message: "prepared statement already exists"}), ErrPreparedStatementExists},
+		{&pgconn.PgError{Code: "0A000", Message: "cached plan must not change result type"}, ErrCachedPlanChanged},
+		{&pgconn.PgError{Code: "53300", Message: "too many clients already"}, ErrTooManyConnections},
+		{errors.New("ERROR: prepared statement \"x\" does not exist (SQLSTATE 08P01)"), ErrPreparedStatementMissing},
+		{&pgconn.PgError{Code: "08P01", Message: `prepared statement "stmtcache_2" does not exist`}, ErrPreparedStatementMissing},
+		{&pgconn.PgError{Code: "08006", Message: "server conn crashed?"}, ErrConnection},
+		{&pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}, ErrAdminShutdown},
+		{errors.New("no more connections allowed (max_client_conn)"), ErrTooManyConnections},
+		{fmt.Errorf("acquire: %w", context.DeadlineExceeded), ErrTimeout},
+		{fmt.Errorf("idle gap interrupted: %w", context.Canceled), ErrQueryCanceled},
+		{errors.New("something unexpected"), ErrOther},
+	}
+
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestMergeAndFormatErrorCounts(t *testing.T) {
+	merged := mergeErrorCounts([]map[ErrorCategory]int{
+		{ErrPreparedStatementMissing: 2, ErrTimeout: 1},
+		nil,
+		{ErrPreparedStatementMissing: 1, ErrOther: 1},
+	})
+
+	want := "prepared_statement_missing=3, other=1, timeout=1"
+	if got := formatErrorCounts(merged); got != want {
+		t.Errorf("formatErrorCounts = %q, want %q", got, want)
+	}
+}
+
+func TestErrorCategoryPrefersAttachedCategory(t *testing.T) {
+	timedOut := fmt.Errorf("worker: %w", &categorizedError{category: ErrAcquireTimedOut, err: context.DeadlineExceeded})
+	if got := errorCategory(timedOut); got != ErrAcquireTimedOut {
+		t.Errorf("errorCategory = %s, want %s", got, ErrAcquireTimedOut)
+	}
+	if !errors.Is(timedOut, context.DeadlineExceeded) {
+		t.Error("categorizedError should unwrap to the underlying error")
+	}
+	if got := errorCategory(context.DeadlineExceeded); got != ErrTimeout {
+		t.Errorf("errorCategory = %s, want %s", got, ErrTimeout)
+	}
+}
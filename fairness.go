@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// jainIndex returns Jain's fairness index of the per-worker completion counts:
+// 1 when every worker completed the same number of queries, down to 1/n when a
+// single worker completed them all. It returns 0 without any completions.
+func jainIndex(counts []int) float64 {
+	var sum, sumSquares float64
+	for _, c := range counts {
+		sum += float64(c)
+		sumSquares += float64(c) * float64(c)
+	}
+	if sumSquares == 0 {
+		return 0
+	}
+	return sum * sum / (float64(len(counts)) * sumSquares)
+}
+
+// maxMinRatio returns how many times more queries the busiest worker completed
+// than the least busy one, or +Inf when some worker completed none
+func maxMinRatio(counts []int) float64 {
+	if len(counts) == 0 {
+		return 0
+	}
+	lo, hi := counts[0], counts[0]
+	for _, c := range counts {
+		lo, hi = min(lo, c), max(hi, c)
+	}
+	if lo == 0 {
+		return math.Inf(1)
+	}
+	return float64(hi) / float64(lo)
+}
+
+// completionCounts counts each worker's successful (non-zero) queries
+func completionCounts(workerTimes [][]time.Duration) []int {
+	counts := make([]int, len(workerTimes))
+	for i, times := range workerTimes {
+		counts[i] = len(successfulTimes(times))
+	}
+	return counts
+}
+
+// completionSpread is the time between the first and last worker finishing,
+// a fairness proxy when each worker runs a single query. Zero offsets (workers
+// that never completed) are ignored.
+func completionSpread(offsets []time.Duration) time.Duration {
+	done := successfulTimes(offsets)
+	if len(done) == 0 {
+		return 0
+	}
+	return done[len(done)-1] - done[0]
+}
+
+// formatFairness describes a run's fairness metrics, or returns "" when the
+// run recorded none
+func formatFairness(r BenchmarkResult) string {
+	switch {
+	case r.JainFairness > 0:
+		ratio := "∞ (some workers completed nothing)"
+		if !math.IsInf(r.MaxMinCompletion, 1) {
+			ratio = fmt.Sprintf("%.2f", r.MaxMinCompletion)
+		}
+		return fmt.Sprintf("Jain index %.3f, max/min completions %s", r.JainFairness, ratio)
+	case r.CompletionSpread > 0:
+		return fmt.Sprintf("completions spread over %v", r.CompletionSpread)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJainIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		want   float64
+	}{
+		{"even", []int{5, 5, 5, 5}, 1},
+		{"one worker did everything", []int{8, 0, 0, 0}, 0.25},
+		{"skewed", []int{1, 3}, 0.8},
+		{"no completions", []int{0, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := jainIndex(tt.counts); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: jainIndex(%v) = %v, want %v", tt.name, tt.counts, got, tt.want)
+		}
+	}
+}
+
+func TestMaxMinRatio(t *testing.T) {
+	if got := maxMinRatio([]int{2, 6, 4}); got != 3 {
+		t.Errorf("maxMinRatio = %v, want 3", got)
+	}
+	if got := maxMinRatio([]int{2, 0}); !math.IsInf(got, 1) {
+		t.Errorf("maxMinRatio with a starved worker = %v, want +Inf", got)
+	}
+}
+
+func TestCompletionCountsSkipsFailures(t *testing.T) {
+	ms := time.Millisecond
+	counts := completionCounts([][]time.Duration{{ms, 0, ms}, {0}, {ms}})
+	want := []int{2, 0, 1}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Fatalf("completionCounts = %v, want %v", counts, want)
+		}
+	}
+}
+
+func TestCompletionSpreadIgnoresUnfinishedWorkers(t *testing.T) {
+	ms := time.Millisecond
+	offsets := []time.Duration{30 * ms, 0, 10 * ms, 25 * ms}
+	if got := completionSpread(offsets); got != 20*ms {
+		t.Errorf("completionSpread = %v, want 20ms", got)
+	}
+}
+
+func TestFormatFairness(t *testing.T) {
+	got := formatFairness(BenchmarkResult{JainFairness: 0.5, MaxMinCompletion: math.Inf(1)})
+	if !strings.Contains(got, "0.500") || !strings.Contains(got, "∞") {
+		t.Errorf("formatFairness = %q", got)
+	}
+	if got := formatFairness(BenchmarkResult{CompletionSpread: 5 * time.Millisecond}); !strings.Contains(got, "5ms") {
+		t.Errorf("formatFairness = %q", got)
+	}
+	if got := formatFairness(BenchmarkResult{}); got != "" {
+		t.Errorf("formatFairness of empty result = %q, want empty", got)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// Type OIDs the fake server describes its columns and parameters with
+const (
+	int4OID = 23
+	int8OID = 20
+	textOID = 25
+)
+
+// fakePGServer speaks just enough of the PostgreSQL wire protocol for pgx pools
+// to run the benchmark against it: trust authentication, the simple and extended
+// query protocols, and canned benchmark_data rows after a configurable latency.
+// Each connection reports its own backend PID.
+type fakePGServer struct {
+	ln      net.Listener
+	latency time.Duration
+	nextPID atomic.Uint32
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{} // Open client connections, closed when the test ends
+}
+
+// startFakePGServer listens on a loopback port until the test ends
+func startFakePGServer(t *testing.T, latency time.Duration) *fakePGServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakePGServer{ln: ln, latency: latency, conns: make(map[net.Conn]struct{})}
+	s.nextPID.Store(1000)
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(func() {
+		// Pools a test left open still hold connections
+		ln.Close()
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+	})
+	return s
+}
+
+// DSN connects to the fake server
+func (s *fakePGServer) DSN() string {
+	return fmt.Sprintf("postgres://benchuser@%s/benchdb?sslmode=disable", s.ln.Addr())
+}
+
+func (s *fakePGServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.handle(conn, s.nextPID.Add(1))
+		}()
+	}
+}
+
+// fakePortal is a bound statement waiting to be executed
+type fakePortal struct {
+	sql           string
+	params        []int64
+	resultFormats []int16
+}
+
+// handle serves one client connection until it terminates or disconnects
+func (s *fakePGServer) handle(conn net.Conn, pid uint32) {
+	backend := pgproto3.NewBackend(conn, conn)
+
+	startup, err := backend.ReceiveStartupMessage()
+	if err != nil {
+		return
+	}
+	if _, ok := startup.(*pgproto3.SSLRequest); ok {
+		if _, err := conn.Write([]byte("N")); err != nil {
+			return
+		}
+		if startup, err = backend.ReceiveStartupMessage(); err != nil {
+			return
+		}
+	}
+	if _, ok := startup.(*pgproto3.StartupMessage); !ok {
+		return
+	}
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	for name, value := range map[string]string{
+		"server_version":              "16.0 (fake)",
+		"client_encoding":             "UTF8",
+		"standard_conforming_strings": "on",
+		"DateStyle":                   "ISO, MDY",
+	} {
+		backend.Send(&pgproto3.ParameterStatus{Name: name, Value: value})
+	}
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: pid})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	statements := make(map[string]string)
+	portals := make(map[string]fakePortal)
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			s.simpleQuery(backend, msg.String, pid)
+		case *pgproto3.Parse:
+			statements[msg.Name] = msg.Query
+			backend.Send(&pgproto3.ParseComplete{})
+		case *pgproto3.Describe:
+			// A portal's columns come in the formats its Bind asked for
+			portal := portals[msg.Name]
+			if msg.ObjectType == 'S' {
+				portal = fakePortal{sql: statements[msg.Name]}
+				oids := make([]uint32, fakeParamCount(portal.sql))
+				for i := range oids {
+					oids[i] = int8OID
+				}
+				backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: oids})
+			}
+			fields := fakeFields(portal.sql)
+			if fields == nil {
+				backend.Send(&pgproto3.NoData{})
+				continue
+			}
+			for i := range fields {
+				fields[i].Format = fakeFormat(portal.resultFormats, i)
+			}
+			backend.Send(&pgproto3.RowDescription{Fields: fields})
+		case *pgproto3.Bind:
+			params, err := fakeParams(msg)
+			if err != nil {
+				backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "22P02", Message: err.Error()})
+				continue
+			}
+			portals[msg.DestinationPortal] = fakePortal{sql: statements[msg.PreparedStatement], params: params, resultFormats: msg.ResultFormatCodes}
+			backend.Send(&pgproto3.BindComplete{})
+		case *pgproto3.Execute:
+			portal := portals[msg.Portal]
+			time.Sleep(s.latency)
+			s.sendRows(backend, portal.sql, portal.params, portal.resultFormats, pid)
+		case *pgproto3.Close:
+			backend.Send(&pgproto3.CloseComplete{})
+		case *pgproto3.Sync:
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if err := backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Flush:
+			if err := backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+// simpleQuery answers a simple-protocol query: pings and transaction control
+// complete without rows, queries on benchmark_data return rows in text format
+func (s *fakePGServer) simpleQuery(backend *pgproto3.Backend, sql string, pid uint32) {
+	trimmed := strings.TrimSpace(sql)
+	switch {
+	case trimmed == "" || strings.HasPrefix(trimmed, "--"):
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+	case fakeFields(sql) != nil:
+		time.Sleep(s.latency)
+		backend.Send(&pgproto3.RowDescription{Fields: fakeFields(sql)})
+		s.sendRows(backend, sql, fakeInlineParams(sql), nil, pid)
+	default:
+		time.Sleep(s.latency)
+		tag, _, _ := strings.Cut(strings.ToUpper(trimmed), " ")
+		backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(strings.TrimRight(tag, ";"))})
+	}
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	backend.Flush()
+}
+
+// sendRows sends the canned result of sql: one benchmark_data row per id
+// starting at params[0] (up to params[1] of them for a LIMIT query), or a
+// single count
+func (s *fakePGServer) sendRows(backend *pgproto3.Backend, sql string, params []int64, formats []int16, pid uint32) {
+	fields := fakeFields(sql)
+	if fields == nil {
+		backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 0")})
+		return
+	}
+
+	if len(fields) == 1 {
+		backend.Send(&pgproto3.DataRow{Values: [][]byte{encodeFakeValue(int8OID, int64(NumBenchmarkRows), fakeFormat(formats, 0))}})
+		backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")})
+		return
+	}
+
+	start, n := int64(1), int64(1)
+	if len(params) > 0 {
+		start = params[0]
+	}
+	if len(params) > 1 {
+		n = params[1]
+	}
+	for id := start; id < start+n; id++ {
+		backend.Send(&pgproto3.DataRow{Values: [][]byte{
+			encodeFakeValue(int4OID, id, fakeFormat(formats, 0)),
+			[]byte(fmt.Sprintf("row %d", id)),
+			encodeFakeValue(int4OID, int64(pid), fakeFormat(formats, 2)),
+		}})
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(fmt.Sprintf("SELECT %d", n))})
+}
+
+// fakeFields describes the columns sql returns, or nil when it returns none
+func fakeFields(sql string) []pgproto3.FieldDescription {
+	switch {
+	case strings.Contains(sql, "COUNT(*)"):
+		return []pgproto3.FieldDescription{{Name: []byte("count"), DataTypeOID: int8OID, DataTypeSize: 8}}
+	case strings.Contains(sql, "FROM benchmark_data"):
+		return []pgproto3.FieldDescription{
+			{Name: []byte("id"), DataTypeOID: int4OID, DataTypeSize: 4},
+			{Name: []byte("name"), DataTypeOID: textOID, DataTypeSize: -1},
+			{Name: []byte("pg_backend_pid"), DataTypeOID: int4OID, DataTypeSize: 4},
+		}
+	}
+	return nil
+}
+
+var fakePlaceholder = regexp.MustCompile(`\$\d+`)
+
+// fakeParamCount counts the distinct $n placeholders in sql
+func fakeParamCount(sql string) int {
+	seen := make(map[string]bool)
+	for _, p := range fakePlaceholder.FindAllString(sql, -1) {
+		seen[p] = true
+	}
+	return len(seen)
+}
+
+var fakeInlineNumber = regexp.MustCompile(`(?:=|>=|LIMIT)\s*(\d+)`)
+
+// fakeInlineParams reads the id and limit that the simple protocol inlined into sql
+func fakeInlineParams(sql string) []int64 {
+	var params []int64
+	for _, m := range fakeInlineNumber.FindAllStringSubmatch(sql, -1) {
+		v, _ := strconv.ParseInt(m[1], 10, 64)
+		params = append(params, v)
+	}
+	return params
+}
+
+// fakeParams decodes a Bind's integer parameters in text or binary format
+func fakeParams(bind *pgproto3.Bind) ([]int64, error) {
+	params := make([]int64, len(bind.Parameters))
+	for i, raw := range bind.Parameters {
+		if fakeFormat(bind.ParameterFormatCodes, i) == 0 {
+			v, err := strconv.ParseInt(string(raw), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			params[i] = v
+			continue
+		}
+		switch len(raw) {
+		case 8:
+			params[i] = int64(binary.BigEndian.Uint64(raw))
+		case 4:
+			params[i] = int64(int32(binary.BigEndian.Uint32(raw)))
+		default:
+			return nil, errors.New("unsupported binary parameter")
+		}
+	}
+	return params, nil
+}
+
+// fakeFormat returns the format code of column i: one code applies to every column
+func fakeFormat(codes []int16, i int) int16 {
+	switch {
+	case len(codes) == 0:
+		return 0
+	case len(codes) == 1:
+		return codes[0]
+	case i < len(codes):
+		return codes[i]
+	}
+	return 0
+}
+
+// encodeFakeValue encodes an integer of type oid in text (0) or binary (1) format
+func encodeFakeValue(oid uint32, v int64, format int16) []byte {
+	if format == 0 {
+		return []byte(strconv.FormatInt(v, 10))
+	}
+	if oid == int8OID {
+		return binary.BigEndian.AppendUint64(nil, uint64(v))
+	}
+	return binary.BigEndian.AppendUint32(nil, uint32(v))
+}
+
+func TestRunBenchmarkAgainstFakeServer(t *testing.T) {
+	const latency = 2 * time.Millisecond
+	server := startFakePGServer(t, latency)
+
+	tests := []struct {
+		name  string
+		flags []string
+	}{
+		{"single query", nil},
+		{"several rows", []string{"-rows-per-query", "5"}},
+		{"transactions", []string{"-transactions"}},
+		{"batches", []string{"-batch-size", "3"}},
+		{"simple protocol", []string{"-exec-mode", "simple_protocol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseOptions(append([]string{"-quiet", "-log-level", "warn"}, tt.flags...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := Config{ConnType: PgBouncerTransaction, DSN: server.DSN(), ExecMode: opts.ExecMode}
+
+			pools, err := NewPoolSet(config, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pools.Close()
+
+			const concurrency = 20
+			r := runBenchmark(config, pools.Poolers(), pools.ConnectTimer(), concurrency, false, NewTraceCollector(), opts)
+
+			if r.TotalQueries != concurrency || len(r.ErrorCategories) != 0 {
+				t.Fatalf("ran %d queries with errors %v, want %d without errors", r.TotalQueries, r.ErrorCategories, concurrency)
+			}
+			if r.QueriesPerSecond <= 0 {
+				t.Errorf("QPS = %v, want > 0", r.QueriesPerSecond)
+			}
+			if !(latency <= r.MinAcquisitionTime && r.MinAcquisitionTime <= r.AvgAcquisitionTime &&
+				r.AvgAcquisitionTime <= r.P99AcquisitionTime && r.P99AcquisitionTime <= r.MaxAcquisitionTime) {
+				t.Errorf("want %v <= min %v <= avg %v <= p99 %v <= max %v", latency,
+					r.MinAcquisitionTime, r.AvgAcquisitionTime, r.P99AcquisitionTime, r.MaxAcquisitionTime)
+			}
+			if r.TotalDuration < r.MaxAcquisitionTime {
+				t.Errorf("total duration %v shorter than the slowest query %v", r.TotalDuration, r.MaxAcquisitionTime)
+			}
+			if r.DistinctBackendPIDs < 1 || r.DistinctBackendPIDs > concurrency {
+				t.Errorf("distinct backends = %d, want 1..%d", r.DistinctBackendPIDs, concurrency)
+			}
+			if len(r.PerPool) != 2 || r.PerPool[0].Queries+r.PerPool[1].Queries != concurrency {
+				t.Errorf("per pool = %+v, want %d queries across 2 pools", r.PerPool, concurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bounds of the -find-max search
+const (
+	DefaultFindMaxStart = 10
+	DefaultFindMaxLimit = 10000
+)
+
+// findMaxResolution is how close the search brackets the breaking point
+// before it stops: within 5% of the highest passing concurrency, or exactly
+// below 20
+const findMaxResolution = 0.05
+
+// MaxProbe is one run of the max-concurrency search
+type MaxProbe struct {
+	Concurrency int
+	FailureRate float64
+	Latency     time.Duration // Acquisition time at the SLO's percentile, or p99 without an SLO
+	QPS         float64
+	Passed      bool
+}
+
+// newMaxProbe judges a run: it passes when no more than maxFailureRate of its
+// queries failed and, with an SLO, it met the SLO
+func newMaxProbe(r BenchmarkResult, maxFailureRate float64, slo *SLO) MaxProbe {
+	probe := MaxProbe{Concurrency: r.Concurrency, QPS: r.QueriesPerSecond}
+	if total := len(r.AcquisitionTimes); total > 0 {
+		probe.FailureRate = float64(failedQueries(r)) / float64(total)
+	}
+
+	pct := 99.0
+	if slo != nil {
+		pct = slo.Percentile
+	}
+	probe.Latency = percentile(r.AcquisitionTimes, pct)
+	probe.Passed = probe.FailureRate <= maxFailureRate && (slo == nil || probe.Latency <= slo.Threshold)
+	return probe
+}
+
+// MaxConcurrencySearch is the outcome of -find-max for one connection type
+type MaxConcurrencySearch struct {
+	MaxFailureRate float64
+	SLO            *SLO
+	Probes         []MaxProbe // In the order they ran
+	Max            int        // Highest concurrency that passed, 0 if none did
+	Limit          int
+	Stopped        bool // The watchdog deadline passed before the search finished
+}
+
+// HitLimit reports whether even the search limit passed, so the breaking
+// point lies somewhere beyond it
+func (s MaxConcurrencySearch) HitLimit() bool {
+	return s.Max > 0 && s.Max == s.Limit
+}
+
+// criteria describes what a probe had to meet, e.g. "failures <= 1.0%, p99<50ms"
+func (s MaxConcurrencySearch) criteria() string {
+	c := fmt.Sprintf("failures <= %.1f%%", s.MaxFailureRate*100)
+	if s.SLO != nil {
+		c += ", " + s.SLO.String()
+	}
+	return c
+}
+
+// searchMaxConcurrency finds the highest concurrency up to limit for which
+// passes holds. It doubles concurrency from start until a level fails, then
+// bisects between the highest passing and the lowest failing level. Levels
+// are assumed to fail from some point on, so each is probed at most once.
+// Once stop returns true no further level is probed, and the highest level
+// that passed so far is returned.
+func searchMaxConcurrency(start, limit int, stop func() bool, passes func(concurrency int) bool) int {
+	lo, hi := 0, 0 // Highest passing and lowest failing level so far
+	for c := min(start, limit); ; c = min(c*2, limit) {
+		if stop() {
+			return lo
+		}
+		if !passes(c) {
+			hi = c
+			break
+		}
+		lo = c
+		if c == limit {
+			return lo
+		}
+	}
+
+	for hi-lo > max(1, int(float64(lo)*findMaxResolution)) && !stop() {
+		mid := lo + (hi-lo)/2
+		if passes(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// runFindMax searches for the highest concurrency config sustains, running
+// each probe through runBenchmark on the same pools. It returns the search
+// and the run to report for the connection type: the one at the maximum, or
+// the lowest failing one when no level passed.
+func runFindMax(config Config, pools *PoolSet, collector *TraceCollector, opts Options) (MaxConcurrencySearch, BenchmarkResult) {
+	search := MaxConcurrencySearch{MaxFailureRate: opts.MaxFailureRate, SLO: opts.SLO, Limit: opts.FindMaxLimit}
+	var best, lowestFailed BenchmarkResult
+	// Past the watchdog deadline the search stops where it is
+	stop := func() bool {
+		search.Stopped = search.Stopped || opts.Watchdog.Expired()
+		return search.Stopped
+	}
+	passes := func(concurrency int) bool {
+		if len(search.Probes) > 0 {
+			time.Sleep(opts.RunPause)
+		}
+		fmt.Printf("🔎 Find-Max Probe %d - Concurrency: %d\n", len(search.Probes)+1, concurrency)
+		endMeasure := startPhase(PhaseMeasure, config.ConnType, "concurrency", concurrency, "probe", len(search.Probes)+1)
+		r := runBenchmark(config, pools.Poolers(), pools.ConnectTimer(), concurrency, false, collector, opts)
+		probe := newMaxProbe(r, opts.MaxFailureRate, opts.SLO)
+		endMeasure("queries", r.TotalQueries, "qps", r.QueriesPerSecond, "passed", probe.Passed)
+		search.Probes = append(search.Probes, probe)
+
+		switch {
+		case probe.Passed && concurrency > best.Concurrency:
+			best = r
+		case !probe.Passed && (lowestFailed.Concurrency == 0 || concurrency < lowestFailed.Concurrency):
+			lowestFailed = r
+		}
+		return probe.Passed
+	}
+
+	search.Max = searchMaxConcurrency(opts.FindMaxStart, opts.FindMaxLimit, stop, passes)
+	fmt.Printf("\n%s\n\n", formatMaxConcurrency(search))
+	if search.Max == 0 {
+		return search, lowestFailed
+	}
+	return search, best
+}
+
+// formatMaxConcurrency states a search's verdict in one line
+func formatMaxConcurrency(s MaxConcurrencySearch) string {
+	switch {
+	case len(s.Probes) == 0:
+		return fmt.Sprintf("Max sustainable concurrency: not probed (watchdog) (%s)", s.criteria())
+	case s.Max == 0:
+		return fmt.Sprintf("Max sustainable concurrency: none, even %d failed (%s)", s.Probes[len(s.Probes)-1].Concurrency, s.criteria())
+	case s.HitLimit():
+		return fmt.Sprintf("Max sustainable concurrency: at least %d, the search limit (%s)", s.Max, s.criteria())
+	case s.Stopped:
+		return fmt.Sprintf("Max sustainable concurrency: at least %d, search stopped by the watchdog (%s)", s.Max, s.criteria())
+	}
+	return fmt.Sprintf("Max sustainable concurrency: %d (%s)", s.Max, s.criteria())
+}
+
+// renderMaxConcurrency lists each connection type's probes and verdict, or
+// returns "" without -find-max
+func renderMaxConcurrency(results []BenchmarkResult) string {
+	var sb strings.Builder
+	for _, r := range results {
+		s := r.MaxSearch
+		if s == nil {
+			continue
+		}
+		latency := "P99"
+		if s.SLO != nil {
+			latency = "P" + strconv.FormatFloat(s.SLO.Percentile, 'f', -1, 64)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s:\n", r.ConnectionType))
+		sb.WriteString(fmt.Sprintf("  %-11s %9s %14s %10s  %s\n", "Concurrency", "Failures", latency, "QPS", "Verdict"))
+		for _, p := range s.Probes {
+			verdict := "fail"
+			if p.Passed {
+				verdict = "pass"
+			}
+			sb.WriteString(fmt.Sprintf("  %-11d %8.2f%% %14v %10.2f  %s\n", p.Concurrency, p.FailureRate*100, p.Latency, p.QPS, verdict))
+		}
+		sb.WriteString(fmt.Sprintf("  %s\n\n", formatMaxConcurrency(*s)))
+	}
+	return sb.String()
+}
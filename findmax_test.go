@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchMaxConcurrencyFindsBreakingPoint(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		start, limit int
+		breaksAt     int // Lowest failing level; 0 never fails
+		want         int
+		maxProbes    int
+	}{
+		{"bisects below the first failure", 10, 10000, 700, 699, 13},
+		{"exact at small levels", 1, 100, 6, 5, 6},
+		{"start already fails", 10, 10000, 4, 3, 5},
+		{"nothing passes", 1, 100, 1, 0, 1},
+		{"limit passes", 10, 1000, 0, 1000, 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			probed := make(map[int]bool)
+			got := searchMaxConcurrency(tc.start, tc.limit, func() bool { return false }, func(c int) bool {
+				if probed[c] {
+					t.Errorf("probed %d twice", c)
+				}
+				probed[c] = true
+				if c > tc.limit {
+					t.Errorf("probed %d beyond the limit %d", c, tc.limit)
+				}
+				return tc.breaksAt == 0 || c < tc.breaksAt
+			})
+
+			// Within the search's resolution below the breaking point
+			if got > tc.want || float64(tc.want-got) > max(1, float64(tc.want)*findMaxResolution) {
+				t.Errorf("max = %d, want about %d", got, tc.want)
+			}
+			if len(probed) > tc.maxProbes {
+				t.Errorf("%d probes, want at most %d", len(probed), tc.maxProbes)
+			}
+		})
+	}
+}
+
+func TestSearchMaxConcurrencyStops(t *testing.T) {
+	// Stopping after three probes ends the search at the highest level that
+	// passed, without probing the rest
+	var probed []int
+	stop := func() bool { return len(probed) == 3 }
+	got := searchMaxConcurrency(10, 10000, stop, func(c int) bool {
+		probed = append(probed, c)
+		return c < 700
+	})
+	if got != 40 || len(probed) != 3 {
+		t.Errorf("max = %d after probing %v, want 40 after 10, 20, 40", got, probed)
+	}
+
+	// Stopping before any probe leaves nothing passed
+	probed = nil
+	if got := searchMaxConcurrency(10, 10000, func() bool { return true }, func(c int) bool {
+		probed = append(probed, c)
+		return true
+	}); got != 0 || len(probed) != 0 {
+		t.Errorf("max = %d after probing %v, want 0 with no probes", got, probed)
+	}
+}
+
+func TestNewMaxProbe(t *testing.T) {
+	const ms = time.Millisecond
+	times := make([]time.Duration, 100)
+	for i := range times {
+		times[i] = time.Duration(i+1) * ms
+	}
+	r := BenchmarkResult{Concurrency: 100, AcquisitionTimes: times, QueriesPerSecond: 500}
+
+	if p := newMaxProbe(r, 0.01, nil); !p.Passed || p.Latency != 99*ms || p.FailureRate != 0 {
+		t.Errorf("without SLO = %+v, want a pass at p99 99ms", p)
+	}
+	if p := newMaxProbe(r, 0.01, &SLO{Percentile: 90, Threshold: 50 * ms}); p.Passed || p.Latency != 90*ms {
+		t.Errorf("with p90<50ms = %+v, want a fail at 90ms", p)
+	}
+
+	times[0], times[1] = 0, 0
+	if p := newMaxProbe(r, 0.01, nil); p.Passed || p.FailureRate != 0.02 {
+		t.Errorf("with 2%% failures = %+v, want a fail", p)
+	}
+}
+
+func TestRenderMaxConcurrency(t *testing.T) {
+	if got := renderMaxConcurrency([]BenchmarkResult{{ConnectionType: PgBouncerSession}}); got != "" {
+		t.Errorf("expected no section without -find-max, got %q", got)
+	}
+
+	search := &MaxConcurrencySearch{
+		MaxFailureRate: 0.01,
+		SLO:            &SLO{Percentile: 99, Threshold: 50 * time.Millisecond},
+		Limit:          10000,
+		Max:            40,
+		Probes: []MaxProbe{
+			{Concurrency: 10, Latency: 5 * time.Millisecond, QPS: 900, Passed: true},
+			{Concurrency: 20, Latency: 9 * time.Millisecond, QPS: 1500, Passed: true},
+			{Concurrency: 40, Latency: 30 * time.Millisecond, QPS: 1700, Passed: true},
+			{Concurrency: 80, Latency: 120 * time.Millisecond, QPS: 1650},
+		},
+	}
+	got := renderMaxConcurrency([]BenchmarkResult{{ConnectionType: PgBouncerTransaction, MaxSearch: search}})
+	for _, want := range []string{
+		"pgbouncer-transaction:",
+		"P99",
+		"120ms",
+		"Max sustainable concurrency: 40 (failures <= 1.0%, p99<50ms)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	search.Stopped = true
+	if got := formatMaxConcurrency(*search); !strings.Contains(got, "at least 40, search stopped by the watchdog") {
+		t.Errorf("stopped by the watchdog got %q", got)
+	}
+
+	search.Max, search.Probes = 10000, search.Probes[:1]
+	if got := formatMaxConcurrency(*search); !strings.Contains(got, "at least 10000") {
+		t.Errorf("at the limit got %q", got)
+	}
+
+	// The watchdog can stop the search before its first probe
+	search.Max, search.Probes = 0, nil
+	got = renderMaxConcurrency([]BenchmarkResult{{ConnectionType: PgBouncerTransaction, MaxSearch: search}})
+	if !strings.Contains(got, "Max sustainable concurrency: not probed (watchdog)") {
+		t.Errorf("without probes got:\n%s", got)
+	}
+}
+
+func TestRunConfigSkipsFindMaxPastWatchdog(t *testing.T) {
+	server := startFakePGServer(t, 0)
+	opts, err := parseOptions([]string{"-quiet", "-log-level", "warn", "-find-max", "-outdir", t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Watchdog = Watchdog{Deadline: time.Now().Add(-time.Second)}
+	config := Config{ConnType: PgBouncerTransaction, DSN: server.DSN(), PoolInstances: 1, ExecMode: opts.ExecMode}
+
+	results, _ := runConfig(context.Background(), config, []int{opts.FindMaxLimit}, NewTraceCollector(), opts)
+	if len(results) != 0 {
+		t.Errorf("results = %+v, want none past the watchdog deadline", results)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// foldTraceStacks walks each trace's span tree and sums the self time of every
+// root-to-span path in microseconds, keyed by the folded stack
+// ("worker.request;pool.acquire"). Self time is a span's duration minus the
+// time covered by its children, so the values of a trace add up to its root's duration.
+func foldTraceStacks(traces []TraceInfo) map[string]int64 {
+	stacks := make(map[string]int64)
+
+	for _, traceInfo := range traces {
+		inTrace := make(map[trace.SpanID]bool, len(traceInfo.Spans))
+		for _, span := range traceInfo.Spans {
+			inTrace[span.SpanContext().SpanID()] = true
+		}
+
+		// Spans whose parent wasn't collected are treated as roots
+		children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+		var roots []sdktrace.ReadOnlySpan
+		for _, span := range traceInfo.Spans {
+			parent := span.Parent()
+			if parent.IsValid() && inTrace[parent.SpanID()] {
+				children[parent.SpanID()] = append(children[parent.SpanID()], span)
+			} else {
+				roots = append(roots, span)
+			}
+		}
+
+		var walk func(span sdktrace.ReadOnlySpan, prefix string)
+		walk = func(span sdktrace.ReadOnlySpan, prefix string) {
+			frame := strings.ReplaceAll(span.Name(), ";", ":")
+			stack := frame
+			if prefix != "" {
+				stack = prefix + ";" + frame
+			}
+
+			self := span.EndTime().Sub(span.StartTime())
+			for _, child := range children[span.SpanContext().SpanID()] {
+				self -= child.EndTime().Sub(child.StartTime())
+				walk(child, stack)
+			}
+			stacks[stack] += max(self.Microseconds(), 0)
+		}
+		for _, root := range roots {
+			walk(root, "")
+		}
+	}
+
+	return stacks
+}
+
+// writeFoldedStacks writes one "stack value" line per stack, sorted by stack
+func writeFoldedStacks(w io.Writer, stacks map[string]int64) error {
+	keys := make([]string, 0, len(stacks))
+	for stack := range stacks {
+		keys = append(keys, stack)
+	}
+	sort.Strings(keys)
+
+	bw := bufio.NewWriter(w)
+	for _, stack := range keys {
+		if _, err := fmt.Fprintf(bw, "%s %d\n", stack, stacks[stack]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ExportFoldedStacks writes the traces in folded-stack format, as consumed by
+// flamegraph.pl, speedscope and inferno
+func ExportFoldedStacks(traces []TraceInfo, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create folded stacks file: %w", err)
+	}
+	defer file.Close()
+
+	if err := writeFoldedStacks(file, foldTraceStacks(traces)); err != nil {
+		return fmt.Errorf("failed to write folded stacks: %w", err)
+	}
+	return nil
+}
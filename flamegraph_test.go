@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestFoldTraceStacks(t *testing.T) {
+	collector, cleanup, err := InitTracer("test-service")
+	if err != nil {
+		t.Fatalf("Failed to initialize tracer: %v", err)
+	}
+	defer cleanup()
+
+	tracer := GetTracer("test")
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	// worker.request (10ms) → pool.acquire (3ms) and db.query (5ms)
+	ctx, root := tracer.Start(context.Background(), "worker.request", trace.WithTimestamp(at(0)))
+	_, acquire := tracer.Start(ctx, "pool.acquire", trace.WithTimestamp(at(0)))
+	acquire.End(trace.WithTimestamp(at(3)))
+	_, query := tracer.Start(ctx, "db.query", trace.WithTimestamp(at(4)))
+	query.End(trace.WithTimestamp(at(9)))
+	root.End(trace.WithTimestamp(at(10)))
+
+	traces := FindSlowestTraces(collector, 1)
+	stacks := foldTraceStacks(append(traces, traces...))
+
+	want := map[string]int64{
+		"worker.request":              4000, // 2ms self time, twice
+		"worker.request;pool.acquire": 6000,
+		"worker.request;db.query":     10000,
+	}
+	if len(stacks) != len(want) {
+		t.Fatalf("got stacks %v, want %v", stacks, want)
+	}
+	for stack, value := range want {
+		if stacks[stack] != value {
+			t.Errorf("%s = %d, want %d", stack, stacks[stack], value)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeFoldedStacks(&buf, stacks); err != nil {
+		t.Fatal(err)
+	}
+	wantOutput := "worker.request 4000\nworker.request;db.query 10000\nworker.request;pool.acquire 6000\n"
+	if buf.String() != wantOutput {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), wantOutput)
+	}
+}
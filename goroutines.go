@@ -0,0 +1,46 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// GoroutineLeakTolerance is how many goroutines above the pre-run baseline still
+// count as settled, to allow for background goroutines started by pgx or the runtime
+const GoroutineLeakTolerance = 5
+
+// goroutinePollInterval is how often waitForGoroutines rechecks the count
+const goroutinePollInterval = 50 * time.Millisecond
+
+// GoroutineCheck records the goroutine count around a run
+type GoroutineCheck struct {
+	Before int
+	After  int
+	Leaked bool // After stayed above Before+GoroutineLeakTolerance for the whole grace period
+}
+
+// Delta is how many more goroutines were running after the run than before it
+func (c GoroutineCheck) Delta() int {
+	return c.After - c.Before
+}
+
+// waitForGoroutines polls count until it is within tolerance of baseline or the
+// grace period ends, and reports the last count seen
+func waitForGoroutines(count func() int, baseline, tolerance int, grace time.Duration) GoroutineCheck {
+	deadline := time.Now().Add(grace)
+	for {
+		current := count()
+		if current <= baseline+tolerance {
+			return GoroutineCheck{Before: baseline, After: current}
+		}
+		if !time.Now().Before(deadline) {
+			return GoroutineCheck{Before: baseline, After: current, Leaked: true}
+		}
+		time.Sleep(goroutinePollInterval)
+	}
+}
+
+// checkGoroutines waits for the process's goroutine count to return near baseline
+func checkGoroutines(baseline int, grace time.Duration) GoroutineCheck {
+	return waitForGoroutines(runtime.NumGoroutine, baseline, GoroutineLeakTolerance, grace)
+}
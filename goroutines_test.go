@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitForGoroutines(t *testing.T) {
+	// Settles to the baseline on the third poll
+	counts := []int{40, 25, 10}
+	next := func() int {
+		c := counts[0]
+		if len(counts) > 1 {
+			counts = counts[1:]
+		}
+		return c
+	}
+	check := waitForGoroutines(next, 10, 2, time.Second)
+	if check.Leaked || check.After != 10 || check.Delta() != 0 {
+		t.Errorf("settling run = %+v, want no leak", check)
+	}
+
+	// Never settles within the grace period
+	check = waitForGoroutines(func() int { return 30 }, 10, 2, 10*time.Millisecond)
+	if !check.Leaked || check.Delta() != 20 {
+		t.Errorf("leaking run = %+v, want a leak of 20", check)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"time"
+)
+
+// Defaults of the HdrHistogram export (-hdr-digits, -hdr-max)
+const (
+	DefaultHdrDigits = 3
+	DefaultHdrMax    = time.Minute
+)
+
+// hdrUnit is the resolution latencies are recorded at, and hdrOutputScale how
+// many of them make up the milliseconds the percentile distribution is written in
+const (
+	hdrUnit        = time.Microsecond
+	hdrOutputScale = 1000.0
+)
+
+// HdrHistogram is a High Dynamic Range histogram, laid out like the reference
+// implementation (lowest discernible value 1) so its percentile distribution
+// output matches what HdrHistogram tools such as hdr-plot read. Values keep
+// the configured number of significant digits anywhere up to the maximum.
+type HdrHistogram struct {
+	highestTrackable     int64
+	significantDigits    int
+	subBucketHalfCount   int
+	subBucketHalfMag     int
+	subBucketCount       int
+	subBucketMask        int64
+	bucketCount          int
+	leadingZeroCountBase int
+	counts               []int64
+	totalCount           int64
+	maxValue             int64
+	clamped              int64 // Values above highestTrackable, recorded as highestTrackable
+}
+
+// NewHdrHistogram creates a histogram tracking values from 1 to highestTrackable
+// with significantDigits (0-5) significant decimal digits
+func NewHdrHistogram(highestTrackable int64, significantDigits int) (*HdrHistogram, error) {
+	if significantDigits < 0 || significantDigits > 5 {
+		return nil, fmt.Errorf("significant digits must be between 0 and 5, got %d", significantDigits)
+	}
+	if highestTrackable < 2 {
+		return nil, fmt.Errorf("highest trackable value must be at least 2, got %d", highestTrackable)
+	}
+
+	h := &HdrHistogram{highestTrackable: highestTrackable, significantDigits: significantDigits}
+	largestSingleUnitValue := 2 * int64(math.Pow10(significantDigits))
+	subBucketCountMag := int(math.Ceil(math.Log2(float64(largestSingleUnitValue))))
+	h.subBucketHalfMag = max(subBucketCountMag, 1) - 1
+	h.subBucketCount = 1 << (h.subBucketHalfMag + 1)
+	h.subBucketHalfCount = h.subBucketCount / 2
+	h.subBucketMask = int64(h.subBucketCount - 1)
+
+	// Each bucket doubles the range of the one before it
+	smallestUntrackable := int64(h.subBucketCount)
+	h.bucketCount = 1
+	for smallestUntrackable <= highestTrackable {
+		if smallestUntrackable > math.MaxInt64/2 {
+			h.bucketCount++
+			break
+		}
+		smallestUntrackable <<= 1
+		h.bucketCount++
+	}
+	h.leadingZeroCountBase = 64 - h.subBucketHalfMag - 1
+	h.counts = make([]int64, (h.bucketCount+1)*h.subBucketHalfCount)
+	return h, nil
+}
+
+func (h *HdrHistogram) bucketIndex(v int64) int {
+	return h.leadingZeroCountBase - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+}
+
+func (h *HdrHistogram) countsIndex(v int64) int {
+	bucket := h.bucketIndex(v)
+	subBucket := int(v >> bucket)
+	return (bucket+1)<<h.subBucketHalfMag + subBucket - h.subBucketHalfCount
+}
+
+// valueFromIndex is the lowest value counted at counts index i
+func (h *HdrHistogram) valueFromIndex(i int) int64 {
+	bucket := i>>h.subBucketHalfMag - 1
+	subBucket := i&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= h.subBucketHalfCount
+		bucket = 0
+	}
+	return int64(subBucket) << bucket
+}
+
+// equivalentRange is how many values share v's count slot
+func (h *HdrHistogram) equivalentRange(v int64) int64 {
+	bucket := h.bucketIndex(v)
+	if int(v>>bucket) >= h.subBucketCount {
+		bucket++
+	}
+	return 1 << bucket
+}
+
+func (h *HdrHistogram) lowestEquivalent(v int64) int64 {
+	bucket := h.bucketIndex(v)
+	return v >> bucket << bucket
+}
+
+func (h *HdrHistogram) highestEquivalent(v int64) int64 {
+	return h.lowestEquivalent(v) + h.equivalentRange(v) - 1
+}
+
+func (h *HdrHistogram) medianEquivalent(v int64) int64 {
+	return h.lowestEquivalent(v) + h.equivalentRange(v)>>1
+}
+
+// Record counts v. Negative values count as 0 and values past the highest
+// trackable value as that value, tallied in Clamped.
+func (h *HdrHistogram) Record(v int64) {
+	if v > h.highestTrackable {
+		v = h.highestTrackable
+		h.clamped++
+	}
+	v = max(v, 0)
+	h.counts[h.countsIndex(v)]++
+	h.totalCount++
+	h.maxValue = max(h.maxValue, v)
+}
+
+// TotalCount is the number of recorded values
+func (h *HdrHistogram) TotalCount() int64 { return h.totalCount }
+
+// Clamped is the number of values recorded as the highest trackable value
+// because they exceeded it
+func (h *HdrHistogram) Clamped() int64 { return h.clamped }
+
+// Max is the highest recorded value, rounded up to its count slot's range
+func (h *HdrHistogram) Max() int64 {
+	if h.maxValue == 0 {
+		return 0
+	}
+	return h.highestEquivalent(h.maxValue)
+}
+
+// Mean is the mean of the recorded values, each taken at the middle of its slot
+func (h *HdrHistogram) Mean() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	var sum float64
+	for i, n := range h.counts {
+		if n > 0 {
+			sum += float64(n) * float64(h.medianEquivalent(h.valueFromIndex(i)))
+		}
+	}
+	return sum / float64(h.totalCount)
+}
+
+// StdDev is the standard deviation of the recorded values around Mean
+func (h *HdrHistogram) StdDev() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	mean := h.Mean()
+	var sum float64
+	for i, n := range h.counts {
+		if n > 0 {
+			d := float64(h.medianEquivalent(h.valueFromIndex(i))) - mean
+			sum += float64(n) * d * d
+		}
+	}
+	return math.Sqrt(sum / float64(h.totalCount))
+}
+
+// HdrPercentile is one line of the percentile distribution
+type HdrPercentile struct {
+	Value      int64
+	Percentile float64 // 0-100
+	TotalCount int64   // Values at or below Value
+}
+
+// Percentiles walks the distribution the way the reference implementation's
+// percentile iterator does: ticksPerHalfDistance steps between each percentile
+// level and the halfway point to 100%, ending with the 100% line.
+func (h *HdrHistogram) Percentiles(ticksPerHalfDistance int) []HdrPercentile {
+	if h.totalCount == 0 {
+		return nil
+	}
+
+	var lines []HdrPercentile
+	level := 0.0
+	var cumulative int64
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		cumulative += n
+		value := h.highestEquivalent(h.valueFromIndex(i))
+		for 100*float64(cumulative)/float64(h.totalCount) >= level {
+			lines = append(lines, HdrPercentile{Value: value, Percentile: level, TotalCount: cumulative})
+			ticks := float64(ticksPerHalfDistance) * math.Pow(2, math.Floor(math.Log2(100/(100-level)))+1)
+			level += 100 / ticks
+			if cumulative == h.totalCount {
+				// The last value gets one line at its level and closes with the 100% line
+				lines = append(lines, HdrPercentile{Value: value, Percentile: 100, TotalCount: cumulative})
+				return lines
+			}
+		}
+	}
+	return lines
+}
+
+// WritePercentileDistribution writes the histogram in HdrHistogram's
+// percentile distribution text format, values divided by scale, with five
+// ticks per half distance like the reference implementation's default
+func (h *HdrHistogram) WritePercentileDistribution(w io.Writer, scale float64) error {
+	bw := bufio.NewWriter(w)
+	digits := h.significantDigits
+	fmt.Fprintf(bw, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+	for _, p := range h.Percentiles(5) {
+		value := float64(p.Value) / scale
+		if p.Percentile == 100 {
+			fmt.Fprintf(bw, "%12.*f %2.12f %10d\n", digits, value, p.Percentile/100, p.TotalCount)
+			continue
+		}
+		fmt.Fprintf(bw, "%12.*f %2.12f %10d %14.2f\n", digits, value, p.Percentile/100, p.TotalCount, 1/(1-p.Percentile/100))
+	}
+	fmt.Fprintf(bw, "#[Mean    = %12.*f, StdDeviation   = %12.*f]\n", digits, h.Mean()/scale, digits, h.StdDev()/scale)
+	fmt.Fprintf(bw, "#[Max     = %12.*f, Total count    = %12d]\n", digits, float64(h.Max())/scale, h.totalCount)
+	fmt.Fprintf(bw, "#[Buckets = %12d, SubBuckets     = %12d]\n", h.bucketCount, h.subBucketCount)
+	return bw.Flush()
+}
+
+// acquisitionHdrHistogram records a result's successful acquisition times in
+// microseconds, tracking up to maxValue with digits significant digits
+func acquisitionHdrHistogram(result BenchmarkResult, maxValue time.Duration, digits int) (*HdrHistogram, error) {
+	h, err := NewHdrHistogram(int64(maxValue/hdrUnit), digits)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range result.AcquisitionTimes {
+		if t > 0 {
+			h.Record(int64(t / hdrUnit))
+		}
+	}
+	return h, nil
+}
+
+// ExportAcquisitionHdrHistogram writes a result's successful acquisition times
+// as an HdrHistogram percentile distribution in milliseconds, the .hgrm format
+// hdr-plot and the HdrHistogram plotter read. Failed queries are left out.
+func ExportAcquisitionHdrHistogram(result BenchmarkResult, filename string, maxValue time.Duration, digits int) (*HdrHistogram, error) {
+	h, err := acquisitionHdrHistogram(result, maxValue, digits)
+	if err != nil {
+		return nil, err
+	}
+	if h.TotalCount() == 0 {
+		return nil, fmt.Errorf("no successful queries to export")
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create histogram file: %w", err)
+	}
+	defer f.Close()
+	if err := h.WritePercentileDistribution(f, hdrOutputScale); err != nil {
+		return nil, fmt.Errorf("failed to write histogram file: %w", err)
+	}
+	return h, f.Close()
+}
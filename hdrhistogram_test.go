@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHdrHistogramLayoutMatchesReference(t *testing.T) {
+	// The reference implementation's documented example: one hour in
+	// microseconds at three significant digits
+	h, err := NewHdrHistogram(3600*1000*1000, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.bucketCount != 22 || h.subBucketCount != 2048 {
+		t.Errorf("buckets = %d, sub-buckets = %d, want 22 and 2048", h.bucketCount, h.subBucketCount)
+	}
+
+	if _, err := NewHdrHistogram(1000, 6); err == nil {
+		t.Error("expected an error for 6 significant digits")
+	}
+}
+
+func TestHdrHistogramKeepsSignificantDigits(t *testing.T) {
+	h, err := NewHdrHistogram(3600*1000*1000, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v := int64(1); v <= 10000; v++ {
+		h.Record(v)
+	}
+	h.Record(3600 * 1000 * 1000 * 2)
+
+	if h.TotalCount() != 10001 || h.Clamped() != 1 {
+		t.Fatalf("total %d, clamped %d, want 10001 and 1", h.TotalCount(), h.Clamped())
+	}
+
+	lines := h.Percentiles(5)
+	if first := lines[0]; first.Value != 1 || first.Percentile != 0 || first.TotalCount != 1 {
+		t.Errorf("first line = %+v, want value 1 at 0%%", first)
+	}
+	if last := lines[len(lines)-1]; last.Percentile != 100 || last.TotalCount != 10001 {
+		t.Errorf("last line = %+v, want the 100%% line over every value", last)
+	}
+	for _, p := range lines {
+		if p.Percentile != 50 {
+			continue
+		}
+		// 5000 or so, within the 0.1% that three significant digits allow
+		if math.Abs(float64(p.Value)-5000) > 5000*0.001+1 {
+			t.Errorf("median = %d, want about 5000", p.Value)
+		}
+	}
+
+	// Values below 2048 get a slot of their own
+	exact, _ := NewHdrHistogram(1000*1000, 3)
+	exact.Record(1234)
+	if exact.Max() != 1234 || exact.Mean() != 1234 {
+		t.Errorf("max %d, mean %v, want 1234", exact.Max(), exact.Mean())
+	}
+}
+
+func TestHdrHistogramPercentileDistributionFormat(t *testing.T) {
+	h, _ := NewHdrHistogram(1000*1000, 2)
+	for _, v := range []int64{500, 1000, 1500, 2000} {
+		h.Record(v)
+	}
+
+	var buf bytes.Buffer
+	if err := h.WritePercentileDistribution(&buf, 1000); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+
+	if fields := strings.Fields(lines[0]); len(fields) != 4 || fields[0] != "Value" || fields[3] != "1/(1-Percentile)" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if lines[1] != "" {
+		t.Errorf("expected a blank line after the header, got %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); len(fields) != 4 || fields[0] != "0.50" || fields[1] != "0.000000000000" {
+		t.Errorf("first line = %q, want 0.50 ms at percentile 0", lines[2])
+	}
+	hundred := lines[len(lines)-4]
+	if fields := strings.Fields(hundred); len(fields) != 3 || fields[1] != "1.000000000000" || fields[2] != "4" {
+		t.Errorf("100%% line = %q, want three columns over 4 values", hundred)
+	}
+	for i, prefix := range []string{"#[Mean    = ", "#[Max     = ", "#[Buckets = "} {
+		if line := lines[len(lines)-3+i]; !strings.HasPrefix(line, prefix) {
+			t.Errorf("footer line %d = %q, want prefix %q", i, line, prefix)
+		}
+	}
+}
+
+func TestExportAcquisitionHdrHistogramSkipsFailures(t *testing.T) {
+	result := BenchmarkResult{AcquisitionTimes: []time.Duration{time.Millisecond, 0, 3 * time.Millisecond}}
+	filename := filepath.Join(t.TempDir(), "acquisition.hgrm")
+
+	h, err := ExportAcquisitionHdrHistogram(result, filename, DefaultHdrMax, DefaultHdrDigits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.TotalCount() != 2 {
+		t.Errorf("recorded %d values, want the 2 successful ones", h.TotalCount())
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3000µs shares a two-value slot, reported by its highest value like the reference does
+	if !strings.Contains(string(data), "#[Max     =        3.001,") {
+		t.Errorf("expected a 3.001 ms max in:\n%s", data)
+	}
+
+	if _, err := ExportAcquisitionHdrHistogram(BenchmarkResult{AcquisitionTimes: []time.Duration{0}}, filename, DefaultHdrMax, DefaultHdrDigits); err == nil {
+		t.Error("expected an error without successful queries")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// histogramBarWidth is the width in characters of the longest histogram bar
+const histogramBarWidth = 40
+
+// HistogramBucket counts durations in the half-open range [Lower, Upper)
+type HistogramBucket struct {
+	Lower time.Duration
+	Upper time.Duration
+	Count int
+}
+
+// buildHistogram buckets the successful (non-zero) durations into numBuckets
+// log-scaled buckets spanning the observed min to max
+func buildHistogram(times []time.Duration, numBuckets int) []HistogramBucket {
+	var successful []time.Duration
+	for _, t := range times {
+		if t > 0 {
+			successful = append(successful, t)
+		}
+	}
+	if len(successful) == 0 || numBuckets <= 0 {
+		return nil
+	}
+
+	minTime, maxTime := successful[0], successful[0]
+	for _, t := range successful {
+		minTime = min(minTime, t)
+		maxTime = max(maxTime, t)
+	}
+
+	// Log-scaled edges: edge[i] = min * (max/min)^(i/n)
+	ratio := float64(maxTime) / float64(minTime)
+	buckets := make([]HistogramBucket, numBuckets)
+	for i := range buckets {
+		buckets[i].Lower = time.Duration(float64(minTime) * math.Pow(ratio, float64(i)/float64(numBuckets)))
+		buckets[i].Upper = time.Duration(float64(minTime) * math.Pow(ratio, float64(i+1)/float64(numBuckets)))
+	}
+
+	for _, t := range successful {
+		idx := 0
+		if ratio > 1 {
+			idx = int(math.Log(float64(t)/float64(minTime)) / math.Log(ratio) * float64(numBuckets))
+		}
+		// The maximum lands exactly on the last upper edge
+		idx = min(max(idx, 0), numBuckets-1)
+		buckets[idx].Count++
+	}
+
+	return buckets
+}
+
+// renderHistogram renders the acquisition-time distribution as an ASCII bar chart
+func renderHistogram(times []time.Duration, numBuckets int) string {
+	return renderHistogramBuckets(buildHistogram(times, numBuckets))
+}
+
+// renderHistogramBuckets renders already-built buckets as an ASCII bar chart
+func renderHistogramBuckets(buckets []HistogramBucket) string {
+	if len(buckets) == 0 {
+		return ""
+	}
+
+	maxCount := 0
+	for _, b := range buckets {
+		maxCount = max(maxCount, b.Count)
+	}
+
+	var sb strings.Builder
+	for _, b := range buckets {
+		barLen := 0
+		if maxCount > 0 {
+			barLen = b.Count * histogramBarWidth / maxCount
+		}
+		if b.Count > 0 && barLen == 0 {
+			barLen = 1 // Keep non-empty buckets visible
+		}
+		sb.WriteString(fmt.Sprintf("  %12v - %-12v | %-*s %d\n",
+			b.Lower.Round(time.Microsecond), b.Upper.Round(time.Microsecond),
+			histogramBarWidth, strings.Repeat("█", barLen), b.Count))
+	}
+
+	return sb.String()
+}
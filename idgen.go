@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DeterministicIDGenerator derives trace and span IDs from a seed, so the same
+// seed and workload export the same IDs and trace files can be diffed between
+// code revisions. A trace started from a context carrying a trace key (see
+// withTraceKey) gets its IDs from the key alone, whatever order goroutines run
+// in; its child spans are numbered in creation order, which is deterministic
+// because a worker creates them one after another. Spans without a key draw
+// from a seeded sequence shared by the whole process.
+type DeterministicIDGenerator struct {
+	seed int64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewDeterministicIDGenerator returns an ID generator seeded with seed
+func NewDeterministicIDGenerator(seed int64) *DeterministicIDGenerator {
+	return &DeterministicIDGenerator{seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+// traceKey names a trace for DeterministicIDGenerator and numbers its spans
+type traceKey struct {
+	name  string
+	spans atomic.Uint64
+}
+
+type traceKeyContextKey struct{}
+
+// withTraceKey returns a context whose new trace takes its IDs from name, which
+// must be unique among the traces of a run
+func withTraceKey(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, traceKeyContextKey{}, &traceKey{name: name})
+}
+
+// NewIDs returns the trace ID and root span ID of a new trace
+func (g *DeterministicIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	key, ok := ctx.Value(traceKeyContextKey{}).(*traceKey)
+	if !ok {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		var traceID trace.TraceID
+		var spanID trace.SpanID
+		for !traceID.IsValid() {
+			g.rng.Read(traceID[:])
+		}
+		for !spanID.IsValid() {
+			g.rng.Read(spanID[:])
+		}
+		return traceID, spanID
+	}
+
+	sum := g.hash(key.name, 0)
+	var traceID trace.TraceID
+	copy(traceID[:], sum[:16])
+	if !traceID.IsValid() {
+		traceID[15] = 1
+	}
+	return traceID, g.spanID(key)
+}
+
+// NewSpanID returns the ID of a new span within traceID
+func (g *DeterministicIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	if key, ok := ctx.Value(traceKeyContextKey{}).(*traceKey); ok {
+		return g.spanID(key)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var spanID trace.SpanID
+	for !spanID.IsValid() {
+		g.rng.Read(spanID[:])
+	}
+	return spanID
+}
+
+// spanID returns the ID of the next span of the keyed trace
+func (g *DeterministicIDGenerator) spanID(key *traceKey) trace.SpanID {
+	sum := g.hash(key.name, key.spans.Add(1))
+	var spanID trace.SpanID
+	copy(spanID[:], sum[:8])
+	if !spanID.IsValid() {
+		spanID[7] = 1
+	}
+	return spanID
+}
+
+// hash mixes the seed, a trace key and a span number
+func (g *DeterministicIDGenerator) hash(name string, n uint64) [sha256.Size]byte {
+	buf := make([]byte, 16, 16+len(name))
+	binary.BigEndian.PutUint64(buf[:8], uint64(g.seed))
+	binary.BigEndian.PutUint64(buf[8:], n)
+	return sha256.Sum256(append(buf, name...))
+}
+
+// traceRuns numbers the runs of each connection type, so trace keys stay unique
+// across warmups, iterations and concurrency levels. Each connection type's runs
+// happen one after another, even with -parallel, so the numbering is stable.
+var traceRuns = struct {
+	sync.Mutex
+	next map[ConnectionType]int
+}{next: make(map[ConnectionType]int)}
+
+// nextTraceRun returns the next run number of connType, starting at 1
+func nextTraceRun(connType ConnectionType) int {
+	traceRuns.Lock()
+	defer traceRuns.Unlock()
+	traceRuns.next[connType]++
+	return traceRuns.next[connType]
+}
+
+// workerTraceKey names the trace of one worker query for deterministic IDs
+func workerTraceKey(connType ConnectionType, run, workerID, query int) string {
+	return fmt.Sprintf("%s/run%d/worker%d/query%d", connType, run, workerID, query)
+}
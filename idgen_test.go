@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// keyedTraceIDs starts a keyed trace with two child spans on a fresh provider
+// and returns the IDs of all three spans
+func keyedTraceIDs(seed int64, key string) []trace.SpanContext {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithIDGenerator(NewDeterministicIDGenerator(seed)))
+	tracer := tp.Tracer("test")
+
+	ctx, root := tracer.Start(withTraceKey(context.Background(), key), "worker.request")
+	_, acquire := tracer.Start(ctx, "pool.acquire_connection")
+	acquire.End()
+	_, query := tracer.Start(ctx, "db.query")
+	query.End()
+	root.End()
+
+	return []trace.SpanContext{root.SpanContext(), acquire.SpanContext(), query.SpanContext()}
+}
+
+func TestDeterministicIDsAreReproducible(t *testing.T) {
+	first := keyedTraceIDs(7, workerTraceKey(PgBouncerSession, 1, 3, 1))
+	second := keyedTraceIDs(7, workerTraceKey(PgBouncerSession, 1, 3, 1))
+
+	for i := range first {
+		if !first[i].IsValid() {
+			t.Fatalf("span %d has invalid IDs: %v", i, first[i])
+		}
+		if first[i].TraceID() != second[i].TraceID() || first[i].SpanID() != second[i].SpanID() {
+			t.Errorf("span %d IDs differ between runs: %v vs %v", i, first[i], second[i])
+		}
+	}
+	if first[1].SpanID() == first[2].SpanID() || first[0].SpanID() == first[1].SpanID() {
+		t.Error("spans of one trace share an ID")
+	}
+}
+
+func TestDeterministicIDsDependOnKeyAndSeed(t *testing.T) {
+	base := keyedTraceIDs(7, workerTraceKey(PgBouncerSession, 1, 3, 1))
+	otherWorker := keyedTraceIDs(7, workerTraceKey(PgBouncerSession, 1, 4, 1))
+	otherSeed := keyedTraceIDs(8, workerTraceKey(PgBouncerSession, 1, 3, 1))
+
+	if base[0].TraceID() == otherWorker[0].TraceID() {
+		t.Error("different workers got the same trace ID")
+	}
+	if base[0].TraceID() == otherSeed[0].TraceID() {
+		t.Error("different seeds gave the same trace ID")
+	}
+}
+
+func TestDeterministicIDsWithoutKeyFollowSeed(t *testing.T) {
+	a := NewDeterministicIDGenerator(1)
+	b := NewDeterministicIDGenerator(1)
+	for i := 0; i < 3; i++ {
+		traceA, spanA := a.NewIDs(context.Background())
+		traceB, spanB := b.NewIDs(context.Background())
+		if traceA != traceB || spanA != spanB {
+			t.Fatalf("unkeyed IDs %d differ: %v/%v vs %v/%v", i, traceA, spanA, traceB, spanB)
+		}
+	}
+}
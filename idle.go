@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IdleCycleResult is the outcome of one idle/reacquire cycle of the idle test
+type IdleCycleResult struct {
+	IdleGap           time.Duration
+	ReacquireDuration time.Duration
+
+	// Pool statistics captured around the idle gap
+	IdleConnsAtRelease    int32
+	IdleConnsAtReacquire  int32
+	TotalConnsAtRelease   int32
+	TotalConnsAtReacquire int32 // Right before reacquiring: what MinConns should have kept alive
+	NewConns              int64 // Connections opened during the gap and reacquire
+	IdleDestroyed         int64 // Connections closed for exceeding MaxConnIdleTime
+	FreshConnection       bool  // Reacquire had to wait for a new connection instead of reusing an idle one
+}
+
+// IdleTestResult is the outcome of a whole idle test for one configuration
+type IdleTestResult struct {
+	ConnType ConnectionType
+	MinConns int32 // The idle test pool's MinConns
+	Cycles   []IdleCycleResult
+}
+
+// BelowMinConns reports whether the pool held fewer than minConns connections
+// when the gap ended, breaking the floor MinConns is meant to guarantee
+func (c IdleCycleResult) BelowMinConns(minConns int32) bool {
+	return c.TotalConnsAtReacquire < minConns
+}
+
+// MinConnsBreaches counts the cycles whose pool fell below MinConns during the
+// gap and returns the lowest connection count any cycle ended its gap with
+func (r IdleTestResult) MinConnsBreaches() (breaches int, lowest int32) {
+	lowest = -1
+	for _, cycle := range r.Cycles {
+		if cycle.BelowMinConns(r.MinConns) {
+			breaches++
+		}
+		if lowest < 0 || cycle.TotalConnsAtReacquire < lowest {
+			lowest = cycle.TotalConnsAtReacquire
+		}
+	}
+	return breaches, max(lowest, 0)
+}
+
+// formatMinConnsCheck states whether MinConns held through every idle gap, or
+// returns "" without cycles
+func formatMinConnsCheck(r IdleTestResult) string {
+	if len(r.Cycles) == 0 {
+		return ""
+	}
+	breaches, lowest := r.MinConnsBreaches()
+	if breaches == 0 {
+		return fmt.Sprintf("MinConns %d kept through every idle gap (lowest %d connections)", r.MinConns, lowest)
+	}
+	return fmt.Sprintf("⚠ MinConns %d NOT kept in %d of %d cycles (down to %d connections)", r.MinConns, breaches, len(r.Cycles), lowest)
+}
+
+// IdleGapStats summarizes the reacquisition times of every cycle with one idle gap
+type IdleGapStats struct {
+	IdleGap   time.Duration
+	Cycles    int
+	Fresh     int // Cycles that waited for a fresh connection
+	BelowMin  int // Cycles that ended the gap with fewer than MinConns connections
+	Avg       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+	Histogram []HistogramBucket
+}
+
+// ByGap groups the cycles by idle gap, shortest gap first, with a
+// reacquisition-time histogram of numBuckets buckets per gap (0 omits it)
+func (r IdleTestResult) ByGap(numBuckets int) []IdleGapStats {
+	times := make(map[time.Duration][]time.Duration)
+	fresh := make(map[time.Duration]int)
+	belowMin := make(map[time.Duration]int)
+	for _, cycle := range r.Cycles {
+		times[cycle.IdleGap] = append(times[cycle.IdleGap], cycle.ReacquireDuration)
+		if cycle.FreshConnection {
+			fresh[cycle.IdleGap]++
+		}
+		if cycle.BelowMinConns(r.MinConns) {
+			belowMin[cycle.IdleGap]++
+		}
+	}
+
+	stats := make([]IdleGapStats, 0, len(times))
+	for gap, gapTimes := range times {
+		stats = append(stats, IdleGapStats{
+			IdleGap:   gap,
+			Cycles:    len(gapTimes),
+			Fresh:     fresh[gap],
+			BelowMin:  belowMin[gap],
+			Avg:       averageDuration(gapTimes),
+			P99:       percentile(gapTimes, 99),
+			Max:       slices.Max(gapTimes),
+			Histogram: buildHistogram(gapTimes, numBuckets),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].IdleGap < stats[j].IdleGap })
+	return stats
+}
+
+// runIdleTest tests connection reacquisition after each of the given idle gaps,
+// running cycles acquire/release/idle/reacquire rounds over all gaps on the same
+// pool. Gaps alternate within a round so drift over the test spreads evenly.
+// It returns the cycles completed so far along with the first error, including
+// cancellation of ctx during an idle gap.
+func runIdleTest(ctx context.Context, config Config, gaps []time.Duration, cycles int) (IdleTestResult, error) {
+	result := IdleTestResult{ConnType: config.ConnType}
+
+	poolConfig, err := newPoolConfig(config)
+	if err != nil {
+		return result, fmt.Errorf("unable to parse config: %w", err)
+	}
+	result.MinConns = poolConfig.MinConns
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return result, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+	defer pool.Close()
+
+	// pgxpool opens MinConns in the background; let it finish before timing anything
+	timeToReady, ready := waitPoolReady(ctx, pool, PoolReadyTimeout)
+	if !ready {
+		slog.Warn("Idle test pool did not reach MinConns", "conn_type", config.ConnType, "min_conns", poolConfig.MinConns, "timeout", PoolReadyTimeout)
+	}
+	slog.Info("idle test pool ready", "conn_type", config.ConnType, "time_to_ready", timeToReady)
+
+	// First acquisition
+	slog.Info("idle test first acquisition", "conn_type", config.ConnType)
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	// Execute query
+	var count int
+	err = conn.QueryRow(ctx, "SELECT COUNT(*) FROM benchmark_data").Scan(&count)
+	if err != nil {
+		conn.Release()
+		return result, fmt.Errorf("query failed: %w", err)
+	}
+	slog.Info("idle test first query executed", "count", count)
+
+	result.Cycles = make([]IdleCycleResult, 0, len(gaps)*cycles)
+	for round := 0; round < cycles; round++ {
+		for _, gap := range gaps {
+			// Release connection
+			conn.Release()
+			before := pool.Stat()
+			slog.Info("idle test connection released", "idle_gap", gap, "round", round+1)
+
+			// Idle period, cut short on cancellation
+			select {
+			case <-time.After(gap):
+			case <-ctx.Done():
+				return result, fmt.Errorf("idle gap %v interrupted: %w", gap, ctx.Err())
+			}
+
+			// Reacquire connection, noting first what survived the gap
+			slog.Info("idle test reacquiring connection", "idle_gap", gap)
+			beforeAcquire := pool.Stat()
+			reacquireStart := time.Now()
+			conn, err = pool.Acquire(ctx)
+			if err != nil {
+				return result, fmt.Errorf("failed to reacquire connection after %v idle: %w", gap, err)
+			}
+			reacquireDuration := time.Since(reacquireStart)
+			after := pool.Stat()
+
+			cycle := IdleCycleResult{
+				IdleGap:               gap,
+				ReacquireDuration:     reacquireDuration,
+				IdleConnsAtRelease:    before.IdleConns(),
+				IdleConnsAtReacquire:  beforeAcquire.IdleConns(),
+				TotalConnsAtRelease:   before.TotalConns(),
+				TotalConnsAtReacquire: beforeAcquire.TotalConns(),
+				NewConns:              after.NewConnsCount() - before.NewConnsCount(),
+				IdleDestroyed:         after.MaxIdleDestroyCount() - before.MaxIdleDestroyCount(),
+				FreshConnection:       after.EmptyAcquireCount() > before.EmptyAcquireCount(),
+			}
+			result.Cycles = append(result.Cycles, cycle)
+			if cycle.BelowMinConns(result.MinConns) {
+				slog.Warn("Pool fell below MinConns during idle gap", "conn_type", config.ConnType, "idle_gap", gap,
+					"min_conns", result.MinConns, "total_conns", cycle.TotalConnsAtReacquire)
+			}
+
+			slog.Info("idle test reacquisition completed",
+				"duration", reacquireDuration, "new_conns", cycle.NewConns, "idle_destroyed", cycle.IdleDestroyed)
+
+			// Execute query again
+			err = conn.QueryRow(ctx, "SELECT COUNT(*) FROM benchmark_data").Scan(&count)
+			if err != nil {
+				conn.Release()
+				return result, fmt.Errorf("query after %v idle failed: %w", gap, err)
+			}
+			slog.Info("idle test query executed", "idle_gap", gap, "count", count)
+		}
+	}
+
+	conn.Release()
+
+	return result, nil
+}
+
+// printIdleResults prints one line per cycle, followed by a per-gap summary
+// when any gap ran more than once
+func printIdleResults(result IdleTestResult) {
+	fmt.Printf("Idle Test Results:\n")
+	for _, r := range result.Cycles {
+		source := "reused idle connection"
+		if r.FreshConnection {
+			source = "waited for a fresh connection"
+		}
+		fmt.Printf("   Gap %-8v reacquired in %-12v %s (idle conns %d → %d, total conns %d → %d, new conns %d, idle-reaped %d)\n",
+			r.IdleGap, r.ReacquireDuration, source, r.IdleConnsAtRelease, r.IdleConnsAtReacquire,
+			r.TotalConnsAtRelease, r.TotalConnsAtReacquire, r.NewConns, r.IdleDestroyed)
+	}
+	if check := formatMinConnsCheck(result); check != "" {
+		fmt.Printf("   %s\n", check)
+	}
+
+	byGap := result.ByGap(0)
+	if len(byGap) < len(result.Cycles) {
+		fmt.Printf("By Idle Gap:\n")
+		for _, stats := range byGap {
+			fmt.Printf("   %s\n", formatIdleGapStats(stats))
+		}
+	}
+	fmt.Println()
+}
+
+// formatIdleGapStats summarizes one idle gap's reacquisitions on a single line
+func formatIdleGapStats(stats IdleGapStats) string {
+	return fmt.Sprintf("Gap %-8v cycles=%d avg=%v p99=%v max=%v fresh=%d/%d below-min=%d/%d",
+		stats.IdleGap, stats.Cycles, stats.Avg, stats.P99, stats.Max, stats.Fresh, stats.Cycles, stats.BelowMin, stats.Cycles)
+}
+
+// renderIdleResults renders every configuration's idle test for the report: a
+// summary line per idle gap, each followed by its reacquisition-time histogram
+func renderIdleResults(results []IdleTestResult, numBuckets int) string {
+	var sb strings.Builder
+	for _, result := range results {
+		if len(result.Cycles) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s:\n", result.ConnType))
+		sb.WriteString(fmt.Sprintf("  %s\n", formatMinConnsCheck(result)))
+		for _, stats := range result.ByGap(numBuckets) {
+			sb.WriteString(fmt.Sprintf("  %s\n", formatIdleGapStats(stats)))
+			if stats.Cycles > 1 {
+				sb.WriteString(renderHistogramBuckets(stats.Histogram))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
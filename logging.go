@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Log formats accepted by -log-format
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// newLogger builds a slog logger writing to w in the given format at the given level
+func newLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case LogFormatText:
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case LogFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want %s or %s)", format, LogFormatText, LogFormatJSON)
+	}
+}
+
+// fatal logs msg at error level and exits the process
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Benchmark phases, logged as "phase" events so a run's timeline can be lined
+// up with external metrics. Phases that span time log <phase>-start and
+// <phase>-end, the end carrying the phase's duration.
+const (
+	PhasePoolCreated   = "pool-created"
+	PhaseWarmup        = "warmup"
+	PhaseMeasure       = "measure"
+	PhaseIdleTest      = "idle-test"
+	PhaseReapTest      = "reap-test"
+	PhaseReportWritten = "report-written"
+)
+
+// logPhase logs a phase event for connType, which is left out when empty
+func logPhase(phase string, connType ConnectionType, attrs ...any) {
+	if connType != "" {
+		attrs = append([]any{"conn_type", connType}, attrs...)
+	}
+	slog.Info("phase", append([]any{"phase", phase}, attrs...)...)
+}
+
+// startPhase logs <phase>-start and returns a function that logs <phase>-end
+// with the time since, plus any attributes the end adds
+func startPhase(phase string, connType ConnectionType, attrs ...any) func(endAttrs ...any) {
+	start := time.Now()
+	logPhase(phase+"-start", connType, attrs...)
+	return func(endAttrs ...any) {
+		end := append(append([]any{}, attrs...), endAttrs...)
+		logPhase(phase+"-end", connType, append(end, "duration", time.Since(start))...)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestStartPhaseLogsStartAndEnd(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, LogFormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	end := startPhase(PhaseMeasure, PgBouncerTransaction, "concurrency", 100)
+	end("queries", 100)
+	logPhase(PhaseReportWritten, "", "path", "benchmark_results.txt")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 events, got %d:\n%s", len(lines), buf.String())
+	}
+	events := make([]map[string]any, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &events[i]); err != nil {
+			t.Fatalf("event %d is not JSON: %v", i, err)
+		}
+		if events[i]["msg"] != "phase" || events[i]["time"] == nil {
+			t.Errorf("event %d = %v, want a timestamped phase message", i, events[i])
+		}
+	}
+
+	start, stop, report := events[0], events[1], events[2]
+	if start["phase"] != "measure-start" || start["conn_type"] != "pgbouncer-transaction" || start["concurrency"] != float64(100) {
+		t.Errorf("start event = %v", start)
+	}
+	if _, ok := start["duration"]; ok {
+		t.Errorf("start event carries a duration: %v", start)
+	}
+	if stop["phase"] != "measure-end" || stop["concurrency"] != float64(100) || stop["queries"] != float64(100) {
+		t.Errorf("end event = %v", stop)
+	}
+	if _, ok := stop["duration"]; !ok {
+		t.Errorf("end event lacks its duration: %v", stop)
+	}
+	if _, ok := report["conn_type"]; ok || report["phase"] != PhaseReportWritten {
+		t.Errorf("report event = %v, want no conn_type", report)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckParallelCapacity(t *testing.T) {
+	direct := Config{ConnType: DirectPostgres, PoolInstances: 2, MaxConns: 10}
+	session := Config{ConnType: PgBouncerSession}
+	transaction := Config{ConnType: PgBouncerTransaction}
+
+	tests := []struct {
+		name    string
+		configs []Config
+		ceiling int
+		wantErr bool
+	}{
+		{"PgBouncer types count its server pool", []Config{session, transaction}, 2 * PgBouncerMaxDBConnections, false},
+		{"direct counts its pools", []Config{direct, session}, 20 + PgBouncerMaxDBConnections, false},
+		{"above the ceiling", []Config{direct, session, transaction}, 2 * PgBouncerMaxDBConnections, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkParallelCapacity(tt.configs, tt.ceiling); (err != nil) != tt.wantErr {
+				t.Errorf("checkParallelCapacity() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConnectionDemand(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   int
+	}{
+		{"defaults", Config{ConnType: PgBouncerSession}, NumberOfPoolInstances * DefaultMaxConnections},
+		{"single pool", Config{ConnType: PgBouncerSession, PoolInstances: 1, MaxConns: 80}, 80},
+		{"direct", Config{ConnType: DirectPostgres, PoolInstances: 3, MaxConns: 10}, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := connectionDemand(tt.config); got != tt.want {
+				t.Errorf("connectionDemand() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckServerCapacity(t *testing.T) {
+	defaults := Config{ConnType: PgBouncerTransaction}
+	grown := Config{ConnType: PgBouncerSession, PoolInstances: 1, MaxConns: DefaultServerCapacity + 1}
+
+	tests := []struct {
+		name     string
+		configs  []Config
+		capacity int
+		want     int // Warnings
+	}{
+		{"defaults fit the default capacity", []Config{defaults}, DefaultServerCapacity, 0},
+		{"demand equal to capacity", []Config{defaults}, connectionDemand(defaults), 0},
+		{"pool grown past the default", []Config{defaults, grown}, DefaultServerCapacity, 1},
+		{"PgBouncer server pool", []Config{defaults, grown}, PgBouncerMaxDBConnections, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkServerCapacity(tt.configs, tt.capacity); len(got) != tt.want {
+				t.Errorf("checkServerCapacity() = %q, want %d warnings", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTextReportDedupesConnectionDemand(t *testing.T) {
+	opts, err := parseOptions([]string{"-quiet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []BenchmarkResult
+	for iteration := 1; iteration <= 3; iteration++ {
+		for _, c := range []int{10, 20} {
+			results = append(results, BenchmarkResult{ConnectionType: PgBouncerSession, Concurrency: c,
+				PoolInstances: 2, MaxConns: 5, Iteration: iteration, TotalQueries: c})
+		}
+	}
+
+	report := renderTextReport(results, nil, opts, time.Now())
+	for _, line := range []string{
+		"Connection Demand (pgbouncer-session, c=10): 10 (2 pools x 5 MaxConns)\n",
+		"Connection Demand (pgbouncer-session, c=20): 10 (2 pools x 5 MaxConns)\n",
+	} {
+		if n := strings.Count(report, line); n != 1 {
+			t.Errorf("%q appears %d times, want once", line, n)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergedResults are the saved results of several invocations, combined into one
+type MergedResults struct {
+	Sources  []string // Files merged, in order
+	Metadata []RunMetadata
+	Results  []ResultSummary
+	Source   []int // Index into Sources of each entry of Results
+}
+
+// mergeSavedResults concatenates the results of each saved file
+func mergeSavedResults(names []string, files []SavedResults) MergedResults {
+	merged := MergedResults{Sources: names}
+	for i, file := range files {
+		merged.Metadata = append(merged.Metadata, file.Metadata)
+		merged.Results = append(merged.Results, file.Results...)
+		for range file.Results {
+			merged.Source = append(merged.Source, i)
+		}
+	}
+	return merged
+}
+
+// mergeMetadataFields are the metadata fields that should agree across merged
+// files for their results to be comparable
+var mergeMetadataFields = []struct {
+	Name  string
+	Value func(RunMetadata) string
+}{
+	{"host", func(m RunMetadata) string { return m.Hostname }},
+	{"platform", func(m RunMetadata) string {
+		if m.OS == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s/%s, %d CPUs", m.OS, m.Arch, m.NumCPU)
+	}},
+	{"Go version", func(m RunMetadata) string { return m.GoVersion }},
+	{"build", func(m RunMetadata) string { return m.Revision }},
+	{"pool tuning", func(m RunMetadata) string { return m.PoolTuning }},
+}
+
+// Conflicts returns a warning for everything that makes the merged results less
+// comparable: metadata the files disagree on, results measured with different
+// pool configurations, and the same measurement appearing in several files.
+// Fields a file didn't record, such as in files saved before metadata was, are
+// not compared.
+func (m MergedResults) Conflicts() []string {
+	var warnings []string
+	for _, field := range mergeMetadataFields {
+		values := make(map[string][]string)
+		for i, meta := range m.Metadata {
+			if value := field.Value(meta); value != "" {
+				values[value] = append(values[value], filepath.Base(m.Sources[i]))
+			}
+		}
+		if len(values) > 1 {
+			warnings = append(warnings, fmt.Sprintf("files differ in %s: %s", field.Name, describeValues(values)))
+		}
+	}
+
+	// The same server should answer for a connection type in every file
+	for _, connType := range m.connTypes() {
+		values := make(map[string][]string)
+		for i, meta := range m.Metadata {
+			if version := meta.ServerVersions[connType]; version != "" {
+				values[version] = append(values[version], filepath.Base(m.Sources[i]))
+			}
+		}
+		if len(values) > 1 {
+			warnings = append(warnings, fmt.Sprintf("files differ in %s server version: %s", connType, describeValues(values)))
+		}
+	}
+
+	// Comparing modes is only fair with the same pools on both sides
+	pools := make(map[string][]string)
+	for i, r := range m.Results {
+		if r.PoolInstances == 0 {
+			continue
+		}
+		config := fmt.Sprintf("%d pools x %d MaxConns", r.PoolInstances, r.MaxConns)
+		entry := fmt.Sprintf("%s c=%d (%s)", r.ConnectionType, r.Concurrency, filepath.Base(m.Sources[m.Source[i]]))
+		if !slices.Contains(pools[config], entry) {
+			pools[config] = append(pools[config], entry)
+		}
+	}
+	if len(pools) > 1 {
+		warnings = append(warnings, fmt.Sprintf("results use different pool configurations: %s", describeValues(pools)))
+	}
+
+	// Measurements repeated across files are averaged as iterations
+	type key struct {
+		connType    ConnectionType
+		concurrency int
+	}
+	seenIn := make(map[key]int)
+	reported := make(map[key]bool)
+	for i, r := range m.Results {
+		k := key{r.ConnectionType, r.Concurrency}
+		first, ok := seenIn[k]
+		if !ok {
+			seenIn[k] = m.Source[i]
+			continue
+		}
+		if first != m.Source[i] && !reported[k] {
+			reported[k] = true
+			warnings = append(warnings, fmt.Sprintf("%s c=%d appears in several files; they are combined as iterations", r.ConnectionType, r.Concurrency))
+		}
+	}
+
+	return warnings
+}
+
+// connTypes returns the connection types with a recorded server version, in report order
+func (m MergedResults) connTypes() []ConnectionType {
+	seen := make(map[ConnectionType]bool)
+	for _, meta := range m.Metadata {
+		for connType := range meta.ServerVersions {
+			seen[connType] = true
+		}
+	}
+	return sortedConnTypes(seen)
+}
+
+// describeValues renders each differing value with the files that have it
+func describeValues(values map[string][]string) string {
+	keys := make([]string, 0, len(values))
+	for value := range values {
+		keys = append(keys, value)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, value := range keys {
+		parts[i] = fmt.Sprintf("%q in %s", value, strings.Join(values[value], ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// BenchmarkResults turns the merged summaries back into actual-run results, with
+// just the metrics the comparison sections look at filled in
+func (m MergedResults) BenchmarkResults() []BenchmarkResult {
+	results := make([]BenchmarkResult, len(m.Results))
+	for i, s := range m.Results {
+		results[i] = BenchmarkResult{
+			ConnectionType:     s.ConnectionType,
+			Concurrency:        s.Concurrency,
+			Iteration:          s.Iteration,
+			PoolInstances:      s.PoolInstances,
+			MaxConns:           s.MaxConns,
+			AvgAcquisitionTime: s.AvgAcquisitionTime,
+			P99AcquisitionTime: s.P99AcquisitionTime,
+			QueriesPerSecond:   s.QueriesPerSecond,
+			TotalQueries:       s.TotalQueries,
+			AvgReleaseTime:     s.AvgReleaseTime,
+
+			ConcurrencyOverridden: s.ConcurrencyOverridden,
+		}
+	}
+	return results
+}
+
+// renderMergedReport renders the merged results: where they came from, any
+// conflicts, every result, and the same comparison sections as a live report
+func renderMergedReport(m MergedResults, warnings []string, generated time.Time) string {
+	var sb strings.Builder
+	sb.WriteString("PGX Connection Pool Benchmark Results (merged)\n")
+	sb.WriteString(fmt.Sprintf("Generated: %s\n", generated.Format(time.RFC3339)))
+	for i, source := range m.Sources {
+		sb.WriteString(fmt.Sprintf("\nSource %d: %s\n", i+1, source))
+		if m.Metadata[i].Hostname != "" {
+			for _, line := range m.Metadata[i].reportLines() {
+				sb.WriteString("  " + line + "\n")
+			}
+		}
+		if m.Metadata[i].PoolTuning != "" {
+			sb.WriteString(fmt.Sprintf("  Pool Tuning: %s\n", m.Metadata[i].PoolTuning))
+		}
+	}
+	if len(warnings) > 0 {
+		sb.WriteString("\nWarnings:\n")
+		for _, warning := range warnings {
+			sb.WriteString(fmt.Sprintf("  ⚠ %s\n", warning))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%s\n", strings.Repeat("=", 80)))
+	sb.WriteString("RESULTS\n")
+	sb.WriteString(fmt.Sprintf("%s\n\n", strings.Repeat("=", 80)))
+	sb.WriteString(fmt.Sprintf("  %-22s %11s %4s %14s %14s %12s %8s  %s\n", "Connection Type", "Concurrency", "Run", "Avg", "P99", "QPS", "Queries", "Source"))
+	for i, r := range m.Results {
+		sb.WriteString(fmt.Sprintf("  %-22s %11d %4d %14v %14v %12.2f %8d  %d\n",
+			r.ConnectionType, r.Concurrency, max(r.Iteration, 1), r.AvgAcquisitionTime, r.P99AcquisitionTime,
+			r.QueriesPerSecond, r.TotalQueries, m.Source[i]+1))
+	}
+
+	results := m.BenchmarkResults()
+	if lines := concurrencyOverrideLines(results); len(lines) > 0 {
+		sb.WriteString("\n")
+		for _, line := range lines {
+			sb.WriteString(line + "\n")
+		}
+	}
+
+	sections := []struct {
+		title   string
+		content string
+	}{
+		{"CONCURRENCY CURVE (averaged over iterations)", renderConcurrencyCurves(results)},
+		{"ITERATIONS (mean ± 95% CI)", renderIterationSummary(results)},
+		{"HEAD-TO-HEAD", renderHeadToHead(results)},
+	}
+	for _, section := range sections {
+		if section.content == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s\n", strings.Repeat("=", 80)))
+		sb.WriteString(section.title + "\n")
+		sb.WriteString(fmt.Sprintf("%s\n\n", strings.Repeat("=", 80)))
+		sb.WriteString(section.content)
+	}
+
+	return sb.String()
+}
+
+// runMerge loads the saved results files, warns about anything that makes them
+// less comparable, and prints and saves the merged report
+func runMerge(filenames []string, outdir string) error {
+	files := make([]SavedResults, 0, len(filenames))
+	for _, filename := range filenames {
+		saved, err := LoadSavedResults(filename)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		files = append(files, saved)
+	}
+
+	merged := mergeSavedResults(filenames, files)
+	if len(merged.Results) == 0 {
+		return fmt.Errorf("no results in %s", strings.Join(filenames, ", "))
+	}
+	warnings := merged.Conflicts()
+	for _, warning := range warnings {
+		slog.Warn("Merged results may not be comparable", "reason", warning)
+	}
+	report := renderMergedReport(merged, warnings, time.Now())
+
+	reportFilename, err := outputPath(outdir, "", "benchmark_results_merged.txt")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(reportFilename, []byte(report), 0644); err != nil {
+		return fmt.Errorf("failed to write merged report: %w", err)
+	}
+
+	fmt.Println(report)
+	fmt.Printf("Merged %d results from %d files into %s\n", len(merged.Results), len(filenames), reportFilename)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// saveTestResults saves results of one connection type as a separate invocation would
+func saveTestResults(t *testing.T, name string, connType ConnectionType, serverVersion string, poolInstances int) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	results := []BenchmarkResult{
+		{ConnectionType: connType, Concurrency: 100, PoolInstances: poolInstances, MaxConns: 20,
+			P99AcquisitionTime: 5 * time.Millisecond, QueriesPerSecond: 1000, ServerVersion: serverVersion},
+	}
+	if err := SaveResults(results, DefaultPoolTuning(), filename); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func loadTestMerge(t *testing.T, filenames ...string) MergedResults {
+	t.Helper()
+	var files []SavedResults
+	for _, filename := range filenames {
+		saved, err := LoadSavedResults(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, saved)
+	}
+	return mergeSavedResults(filenames, files)
+}
+
+func TestMergeSavedResultsCombinesInvocations(t *testing.T) {
+	session := saveTestResults(t, "session.json", PgBouncerSession, "PostgreSQL 16", 3)
+	transaction := saveTestResults(t, "transaction.json", PgBouncerTransaction, "PostgreSQL 16", 3)
+
+	merged := loadTestMerge(t, session, transaction)
+	if len(merged.Results) != 2 || merged.Source[0] != 0 || merged.Source[1] != 1 {
+		t.Fatalf("merged = %+v", merged)
+	}
+	if warnings := merged.Conflicts(); len(warnings) != 0 {
+		t.Errorf("unexpected conflicts: %q", warnings)
+	}
+
+	report := renderMergedReport(merged, nil, time.Now())
+	if !strings.Contains(report, "HEAD-TO-HEAD") || !strings.Contains(report, "Concurrency 100: pgbouncer-transaction vs pgbouncer-session") {
+		t.Errorf("merged report lacks the head-to-head comparison:\n%s", report)
+	}
+}
+
+func TestMergedResultsConflicts(t *testing.T) {
+	first := saveTestResults(t, "a.json", PgBouncerTransaction, "PostgreSQL 16", 3)
+	second := saveTestResults(t, "b.json", PgBouncerTransaction, "PostgreSQL 17", 1)
+
+	warnings := loadTestMerge(t, first, second).Conflicts()
+	want := []string{"server version", "different pool configurations", "appears in several files"}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings = %q, want %d", warnings, len(want))
+	}
+	for i, substr := range want {
+		if !strings.Contains(warnings[i], substr) {
+			t.Errorf("warning %d = %q, want it to mention %q", i, warnings[i], substr)
+		}
+	}
+}
+
+func TestMergedResultsIgnoreMissingMetadata(t *testing.T) {
+	legacy := filepath.Join(t.TempDir(), "legacy.json")
+	data := `[{"conn_type": "pgbouncer-session", "concurrency": 100, "p99_acquisition_ns": 3000000, "qps": 42}]`
+	if err := os.WriteFile(legacy, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	current := saveTestResults(t, "current.json", PgBouncerTransaction, "PostgreSQL 16", 3)
+
+	if warnings := loadTestMerge(t, legacy, current).Conflicts(); len(warnings) != 0 {
+		t.Errorf("legacy file without metadata caused conflicts: %q", warnings)
+	}
+}
+
+func TestMergedResultsKeepConcurrencyOverrides(t *testing.T) {
+	dir := t.TempDir()
+	save := func(name string, results []BenchmarkResult) string {
+		filename := filepath.Join(dir, name)
+		if err := SaveResults(results, DefaultPoolTuning(), filename); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	session := save("session.json", []BenchmarkResult{
+		{ConnectionType: PgBouncerSession, Concurrency: 100, QueriesPerSecond: 50, ConcurrencyOverridden: true},
+	})
+	transaction := save("transaction.json", []BenchmarkResult{
+		{ConnectionType: PgBouncerTransaction, Concurrency: 500, QueriesPerSecond: 150},
+	})
+
+	report := renderMergedReport(loadTestMerge(t, session, transaction), nil, time.Now())
+	for _, want := range []string{
+		"Concurrency (pgbouncer-session): [100]",
+		"Concurrency 500 vs 100: pgbouncer-transaction vs pgbouncer-session",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("merged report lacks %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RunMetadata describes the environment that produced a set of results, so
+// reports and saved results collected over time stay comparable
+type RunMetadata struct {
+	Hostname       string                    `json:"hostname"`
+	OS             string                    `json:"os"`
+	Arch           string                    `json:"arch"`
+	NumCPU         int                       `json:"num_cpu"`
+	GoVersion      string                    `json:"go_version"`
+	Version        string                    `json:"version"`
+	Revision       string                    `json:"vcs_revision,omitempty"`
+	Modified       bool                      `json:"vcs_modified,omitempty"`
+	ServerVersions map[ConnectionType]string `json:"server_versions,omitempty"`
+	PoolTuning     string                    `json:"pool_tuning,omitempty"` // Set by SaveResults
+}
+
+// collectRunMetadata describes this process and build, plus the server version
+// recorded on the results of each connection type
+func collectRunMetadata(results []BenchmarkResult) RunMetadata {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	meta := RunMetadata{
+		Hostname:       hostname,
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		NumCPU:         runtime.NumCPU(),
+		GoVersion:      runtime.Version(),
+		ServerVersions: make(map[ConnectionType]string),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		meta.Version, meta.Revision, meta.Modified = buildVersion(info)
+	}
+	for _, r := range results {
+		if r.ServerVersion != "" {
+			meta.ServerVersions[r.ConnectionType] = r.ServerVersion
+		}
+	}
+	return meta
+}
+
+// buildVersion returns the main module version and the VCS revision the binary
+// was built from, and whether the working tree had local modifications
+func buildVersion(info *debug.BuildInfo) (version, revision string, modified bool) {
+	version = info.Main.Version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	return version, revision, modified
+}
+
+// reportLines renders the metadata as report header lines
+func (m RunMetadata) reportLines() []string {
+	build := m.Version
+	if m.Revision != "" {
+		build += " " + m.Revision
+		if m.Modified {
+			build += " (modified)"
+		}
+	}
+	lines := []string{
+		fmt.Sprintf("Host: %s (%s/%s, %d CPUs)", m.Hostname, m.OS, m.Arch, m.NumCPU),
+		fmt.Sprintf("Build: %s, %s", build, m.GoVersion),
+	}
+	for _, connType := range sortedConnTypes(m.ServerVersions) {
+		lines = append(lines, fmt.Sprintf("Server (%s): %s", connType, m.ServerVersions[connType]))
+	}
+	return lines
+}
+
+// serverVersion asks the server behind pool for its SELECT version() string
+func serverVersion(ctx context.Context, pool Pooler) (string, error) {
+	rows, err := pool.Query(ctx, "SELECT version()")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var version string
+	if _, err := drainRows(rows, func(rows pgx.Rows) error { return rows.Scan(&version) }); err != nil {
+		return "", err
+	}
+	return version, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestBuildVersion(t *testing.T) {
+	info := &debug.BuildInfo{
+		Main: debug.Module{Path: "pgx-benchmark", Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	version, revision, modified := buildVersion(info)
+	if version != "(devel)" || revision != "abc123" || !modified {
+		t.Errorf("buildVersion = %q, %q, %v", version, revision, modified)
+	}
+}
+
+func TestCollectRunMetadataRecordsServerVersions(t *testing.T) {
+	meta := collectRunMetadata([]BenchmarkResult{
+		{ConnectionType: DirectPostgres, ServerVersion: "PostgreSQL 16.4"},
+		{ConnectionType: PgBouncerSession},
+	})
+	if meta.NumCPU < 1 || meta.OS == "" || meta.GoVersion == "" {
+		t.Errorf("missing host details: %+v", meta)
+	}
+	if len(meta.ServerVersions) != 1 || meta.ServerVersions[DirectPostgres] != "PostgreSQL 16.4" {
+		t.Errorf("server versions = %v", meta.ServerVersions)
+	}
+
+	header := strings.Join(meta.reportLines(), "\n")
+	if !strings.Contains(header, "Server (direct-postgres): PostgreSQL 16.4") {
+		t.Errorf("report header missing server version:\n%s", header)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics updated by the benchmark workers. They are always
+// recorded and only exposed when a metrics server is started.
+var (
+	acquisitionSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pgx_benchmark_acquisition_seconds",
+		Help:    "Time from query start until the pool returned rows, in seconds.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16), // 0.5ms .. ~16s
+	}, []string{"conn_type"})
+
+	queriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pgx_benchmark_queries_total",
+		Help: "Number of queries issued by benchmark workers.",
+	}, []string{"conn_type"})
+
+	queryFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pgx_benchmark_query_failures_total",
+		Help: "Number of queries that failed.",
+	}, []string{"conn_type"})
+
+	inflightWorkers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pgx_benchmark_inflight_workers",
+		Help: "Number of benchmark workers currently running.",
+	}, []string{"conn_type"})
+)
+
+// StartMetricsServer serves Prometheus metrics on addr in the background
+func StartMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("Metrics server stopped", "error", err)
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// QueryRecord is one completed query as written to the NDJSON event log
+type QueryRecord struct {
+	WorkerID   int    `json:"worker_id"`
+	PoolIndex  int    `json:"pool_index"`
+	ConnType   string `json:"conn_type"`
+	DurationNs int64  `json:"duration_ns"`
+	Error      string `json:"error,omitempty"`
+}
+
+// QueryRecordSink streams QueryRecords to a file as newline-delimited JSON.
+// It is safe for concurrent use by workers.
+type QueryRecordSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error
+}
+
+// NewQueryRecordSink creates (or truncates) filename and returns a sink writing to it
+func NewQueryRecordSink(filename string) (*QueryRecordSink, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NDJSON file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	return &QueryRecordSink{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Write appends one record. The first write error is kept and returned by Close
+// so a failing disk doesn't abort the benchmark mid-run.
+func (s *QueryRecordSink) Write(rec QueryRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = s.enc.Encode(rec)
+}
+
+// Close flushes buffered records and closes the file
+func (s *QueryRecordSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.err
+	if flushErr := s.w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := s.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write NDJSON file: %w", err)
+	}
+	return nil
+}
+
+// queryRecordsFilename names the NDJSON event log for a run, mirroring the CSV naming
+func queryRecordsFilename(connType ConnectionType, concurrency int, isWarmup bool, iteration int, variant string) string {
+	return exportFilename("query_records", connType, runDetail(concurrency, isWarmup, iteration, variant), time.Now(), ".ndjson")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestQueryRecordSinkConcurrentWrites(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "records.ndjson")
+	sink, err := NewQueryRecordSink(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			rec := QueryRecord{WorkerID: workerID, PoolIndex: workerID % 6, ConnType: "direct-postgres", DurationNs: int64(workerID)}
+			if workerID%2 == 1 {
+				rec.Error = "boom"
+			}
+			sink.Write(rec)
+		}(i)
+	}
+	wg.Wait()
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	seen := make(map[int]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec QueryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		if (rec.Error != "") != (rec.WorkerID%2 == 1) {
+			t.Errorf("worker %d has unexpected error %q", rec.WorkerID, rec.Error)
+		}
+		seen[rec.WorkerID] = true
+	}
+	if len(seen) != workers {
+		t.Errorf("got records for %d workers, want %d", len(seen), workers)
+	}
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+// Options holds the command line options for a benchmark invocation
+type Options struct {
+	LogLevel  slog.Level
+	LogFormat string
+
+	PgxTraceLevel tracelog.LogLevel
+	Quiet         bool
+
+	MetricsAddr  string
+	ExportCSV    bool
+	ExportHdr    bool
+	HdrDigits    int
+	HdrMax       time.Duration
+	ExportNDJSON bool
+	OTLPEndpoint string
+	OTLPProtocol string
+	MaxSpans     int
+
+	HistogramBuckets int
+	ReportFormat     string
+	ReportMetrics    []string
+	TraceSort        string
+	TracePerFile     bool
+	TraceFormat      string
+
+	DeterministicTraceIDs bool
+	TraceSampleRatio      float64
+	SpanAttributeKeys     []string
+	TraceKeepSlowest      int
+	OutDir                string
+	ReportFile            string
+	NoClobber             bool
+
+	AcquireTimeout time.Duration
+	QueryTimeout   time.Duration
+
+	// Deadline for the whole benchmark (-watchdog), and the watchdog main
+	// starts from it once the runs begin
+	WatchdogTimeout time.Duration
+	Watchdog        Watchdog
+
+	PoolTuning PoolTuning
+
+	Warmups    int
+	Iterations int
+
+	// Which run of a level runBenchmark is making, for its NDJSON file name;
+	// runConfig sets them per run
+	RunIteration int
+	RunVariant   string
+
+	QueriesPerConn int
+	RowsPerQuery   int
+	Transactions   bool
+	BatchSize      int
+	SessionState   string
+	CacheTest      bool
+	CacheSettle    time.Duration
+
+	GoroutineCheck bool
+	CPUProfile     string
+
+	ActivityInterval time.Duration
+	MemProfile       string
+	GoroutineGrace   time.Duration
+	RollbackRatio    float64
+	RunPause         time.Duration
+	LevelPause       time.Duration
+	Fast             bool
+
+	RampUp    time.Duration
+	Duration  time.Duration
+	TargetQPS float64
+
+	Merge []string
+
+	SessionDSNs     []string
+	TransactionDSNs []string
+
+	// Concurrency levels of one PgBouncer mode, in place of ConcurrencyLevels
+	SessionConcurrency     []int
+	TransactionConcurrency []int
+
+	IdleGaps   []time.Duration
+	IdleCycles int
+
+	ReapTest     bool
+	ReapIdleTime time.Duration
+
+	ConcurrencyLevels []int
+
+	Seed    int64
+	ArgDist ArgDistribution
+
+	// Workload trace files (-record, -replay), and what main opens from them
+	Record      string
+	Replay      string
+	Recorder    *WorkloadRecorder
+	ReplayTrace *WorkloadTrace
+
+	ExecMode pgx.QueryExecMode
+	TLS      TLSOptions
+
+	Check bool
+
+	Setup     bool
+	SetupRows int
+	Teardown  bool
+
+	SaveResults         string
+	Baseline            string
+	RegressionThreshold float64
+	MaxFailureRate      float64
+
+	SinglePool     bool
+	DatabaseSQL    bool
+	SinglePoolSize int
+
+	ServerCapacity int
+	Strict         bool
+	SLO            *SLO
+
+	FindMax      bool
+	FindMaxStart int
+	FindMaxLimit int
+
+	Parallel               bool
+	ParallelMaxServerConns int
+}
+
+// parseOptions parses command line flags into Options
+func parseOptions(args []string) (Options, error) {
+	opts := Options{
+		IdleGaps:   []time.Duration{DefaultIdleGap},
+		IdleCycles: 1,
+		ArgDist: ArgDistribution{
+			Kind:         ArgDistUniform,
+			ZipfSkew:     DefaultZipfSkew,
+			HotspotRows:  DefaultHotspotRows,
+			HotspotShare: DefaultHotspotShare,
+		},
+		ConcurrencyLevels:   []int{DefaultConcurrency},
+		ExecMode:            pgx.QueryExecModeCacheStatement,
+		RegressionThreshold: DefaultRegressionThreshold,
+		MaxFailureRate:      DefaultMaxFailureRate,
+	}
+	opts.PoolTuning = DefaultPoolTuning()
+
+	fs := flag.NewFlagSet("pgx-benchmark", flag.ExitOnError)
+	fs.TextVar(&opts.LogLevel, "log-level", slog.LevelInfo, "Minimum log level: debug, info, warn or error (warn silences per-query logs)")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "Don't print the once-a-second progress line during runs")
+	fs.Func("pgx-trace-level", "Log pgx driver events (queries with driver-observed durations, connects, acquires) at this tracelog level: trace, debug, info, warn, error or none", func(value string) error {
+		level, err := tracelog.LogLevelFromString(value)
+		if err != nil {
+			return err
+		}
+		opts.PgxTraceLevel = level
+		return nil
+	})
+	fs.StringVar(&opts.LogFormat, "log-format", LogFormatText, "Log output format: text or json")
+	fs.BoolVar(&opts.Check, "check", false, "Check that every configuration is reachable and benchmark_data exists, then exit without benchmarking")
+	fs.StringVar(&opts.SaveResults, "save-results", "", "Save the actual runs' summary metrics to this JSON file (usable later as a -baseline)")
+	fs.Var((*stringList)(&opts.Merge), "merge", "Comma-separated files written by -save-results to combine into one comparison report, without benchmarking")
+	fs.StringVar(&opts.Record, "record", "", "Record the dispatch times, queries and arguments of the first measured run at each concurrency level to this workload trace file")
+	fs.StringVar(&opts.Replay, "replay", "", "Drive runs from a workload trace written by -record instead of generating the workload")
+	fs.StringVar(&opts.Baseline, "baseline", "", "Compare p99 acquisition and QPS against this saved results file and exit with status 4 on regression")
+	fs.Var((*percentFlag)(&opts.RegressionThreshold), "regression-threshold", "How much worse than -baseline a metric may get before it counts as a regression (e.g. 10%)")
+	fs.Var((*percentFlag)(&opts.MaxFailureRate), "max-failure-rate", "Share of the actual runs' queries that may fail before the exit status is 2 (e.g. 1%)")
+	fs.BoolVar(&opts.ExportCSV, "csv", false, "Export per-worker acquisition times of every run to CSV")
+	fs.BoolVar(&opts.ExportHdr, "hdr-histogram", false, "Export the successful acquisition times of every run as an HdrHistogram percentile distribution (.hgrm, in milliseconds)")
+	fs.IntVar(&opts.HdrDigits, "hdr-digits", DefaultHdrDigits, "Significant decimal digits the -hdr-histogram keeps (0-5)")
+	fs.DurationVar(&opts.HdrMax, "hdr-max", DefaultHdrMax, "Highest acquisition time the -hdr-histogram tracks; longer ones are recorded as this")
+	fs.BoolVar(&opts.ExportNDJSON, "ndjson", false, "Stream a JSON line per completed query of every run to an NDJSON file")
+	fs.DurationVar(&opts.PoolTuning.MaxConnLifetime, "max-conn-lifetime", DefaultMaxConnLifetime, "Close pooled connections older than this")
+	fs.DurationVar(&opts.PoolTuning.MaxConnLifetimeJitter, "max-conn-lifetime-jitter", DefaultMaxConnLifetimeJitter, "Random extra lifetime per connection, spreading out reconnects")
+	fs.DurationVar(&opts.PoolTuning.MaxConnIdleTime, "max-conn-idle-time", DefaultMaxConnIdleTime, "Close pooled connections idle for longer than this")
+	fs.DurationVar(&opts.PoolTuning.HealthCheckPeriod, "health-check-period", DefaultHealthCheckPeriod, "How often pools check idle connections' lifetime and idle time")
+	fs.DurationVar(&opts.AcquireTimeout, "acquire-timeout", 0, "Give up on a connection acquisition after this long and count it as a timeout (0 = wait forever)")
+	fs.DurationVar(&opts.QueryTimeout, "query-timeout", 0, "Cancel the queries on an acquired connection after this long and count them as cancelled (0 = no deadline)")
+	fs.DurationVar(&opts.WatchdogTimeout, "watchdog", 0, "Deadline for the whole benchmark: past it, stuck workers are abandoned, no further runs start and the report covers what completed (0 = no deadline)")
+	fs.DurationVar(&opts.Duration, "duration", 0, "Run sustained load: each worker loops issuing queries for this long (0 = one query per worker)")
+	fs.BoolVar(&opts.DatabaseSQL, "database-sql", false, "Also run both PgBouncer modes through database/sql with pgx's stdlib driver, sized like the pgxpool instances")
+	fs.BoolVar(&opts.SinglePool, "single-pool", false, "Share one pool between all workers instead of spreading them across pool instances")
+	fs.IntVar(&opts.SinglePoolSize, "single-pool-size", DefaultMaxConnections, "MaxConns of the shared pool with -single-pool")
+	fs.IntVar(&opts.ServerCapacity, "server-capacity", DefaultServerCapacity, "Connections each connection type's server accepts from the pools; larger pool demand is warned about")
+	fs.BoolVar(&opts.Strict, "strict", false, "Refuse to run when connection demand exceeds -server-capacity instead of warning")
+	fs.Func("slo", "Acquisition-latency objective checked per connection type, e.g. p99<50ms; a breach sets exit status 3", func(value string) error {
+		slo, err := parseSLO(value)
+		if err != nil {
+			return err
+		}
+		opts.SLO = &slo
+		return nil
+	})
+	fs.BoolVar(&opts.FindMax, "find-max", false, "Instead of the -concurrency levels, search for the highest concurrency whose failure rate stays within -max-failure-rate and that meets -slo, if set")
+	fs.IntVar(&opts.FindMaxStart, "find-max-start", DefaultFindMaxStart, "Concurrency -find-max starts from, doubling it until a level fails")
+	fs.IntVar(&opts.FindMaxLimit, "find-max-limit", DefaultFindMaxLimit, "Highest concurrency -find-max probes")
+	fs.BoolVar(&opts.Parallel, "parallel", false, "Benchmark all connection types at the same time instead of one after another")
+	fs.IntVar(&opts.ParallelMaxServerConns, "parallel-max-server-conns", DefaultParallelMaxServerConns, "Refuse -parallel when the connection types could open more server connections than this combined")
+	fs.BoolVar(&opts.Setup, "setup", false, "Create and seed benchmark_data through the direct PostgreSQL DSN before benchmarking")
+	fs.IntVar(&opts.SetupRows, "setup-rows", NumBenchmarkRows, "Number of rows to seed with -setup")
+	fs.BoolVar(&opts.Teardown, "teardown", false, "Drop benchmark_data through the direct PostgreSQL DSN after benchmarking")
+	fs.Var((*execModeFlag)(&opts.ExecMode), "exec-mode", "pgx query exec mode: cache_statement, cache_describe, describe_exec, exec or simple_protocol")
+	fs.StringVar(&opts.TLS.SSLMode, "sslmode", "", "Override the DSNs' sslmode: disable, require, verify-ca or verify-full")
+	fs.StringVar(&opts.TLS.CAFile, "sslrootcert", "", "PEM CA bundle to verify the server certificate against (default: system roots)")
+	fs.StringVar(&opts.TLS.ServerName, "ssl-server-name", "", "Server name to verify with -sslmode verify-full (default: the DSN host)")
+	fs.Int64Var(&opts.Seed, "seed", 1, "Seed for the workload's random choices; the same seed reproduces the same workload")
+	fs.StringVar(&opts.ArgDist.Kind, "arg-dist", ArgDistUniform, "How queries pick their benchmark_data id: uniform, zipfian or hotspot")
+	fs.Float64Var(&opts.ArgDist.ZipfSkew, "zipf-skew", DefaultZipfSkew, "Exponent of -arg-dist zipfian, greater than 1; higher concentrates more queries on the hottest ids")
+	fs.Var((*percentFlag)(&opts.ArgDist.HotspotRows), "hotspot-rows", "Share of rows that are hot with -arg-dist hotspot (e.g. 10%)")
+	fs.Var((*percentFlag)(&opts.ArgDist.HotspotShare), "hotspot-share", "Share of queries hitting the hot rows with -arg-dist hotspot (e.g. 90%)")
+	fs.Var((*intList)(&opts.ConcurrencyLevels), "concurrency", "Comma-separated concurrency levels to sweep, e.g. 100,500,1000,5000")
+	fs.Var((*stringList)(&opts.SessionDSNs), "session-dsns", "Comma-separated PgBouncer session-mode DSNs of a sharded setup; pool instance i connects to DSN i % count")
+	fs.Var((*intList)(&opts.SessionConcurrency), "session-concurrency", "Comma-separated concurrency levels for PgBouncer session mode, in place of -concurrency")
+	fs.Var((*intList)(&opts.TransactionConcurrency), "transaction-concurrency", "Comma-separated concurrency levels for PgBouncer transaction mode, in place of -concurrency")
+	fs.Var((*stringList)(&opts.TransactionDSNs), "transaction-dsns", "Comma-separated PgBouncer transaction-mode DSNs of a sharded setup; pool instance i connects to DSN i % count")
+	fs.Var((*durationList)(&opts.IdleGaps), "idle-gaps", "Comma-separated idle periods for the idle test, one cycle each (e.g. 5s,29s,31s,60s)")
+	fs.IntVar(&opts.IdleCycles, "idle-cycles", opts.IdleCycles, "Rounds of the idle test; each round runs one cycle per idle gap, and the report gets a reacquisition-time histogram per gap")
+	fs.BoolVar(&opts.ReapTest, "reap-test", false, "After the idle test, compare a warm burst of MaxConns acquisitions with one right after idle reaping")
+	fs.DurationVar(&opts.ReapIdleTime, "reap-idle-time", DefaultReapIdleTime, "MaxConnIdleTime the -reap-test pool uses and idles past")
+	fs.Float64Var(&opts.TargetQPS, "target-qps", 0, "Hold a fixed offered load of this many queries per second (requires -duration)")
+	fs.IntVar(&opts.Warmups, "warmups", DefaultWarmups, "Warmup runs before each measured run (0 skips warmup); only the last is reported")
+	fs.IntVar(&opts.QueriesPerConn, "queries-per-conn", 1, "Queries each worker runs in sequence on one acquired connection before releasing it")
+	fs.IntVar(&opts.RowsPerQuery, "rows-per-query", 1, "Rows each query returns and the worker streams through, from consecutive ids (at most the benchmark_data rows)")
+	fs.DurationVar(&opts.ActivityInterval, "activity-interval", 0, "Sample pg_stat_activity wait events at this interval during each run (0 disables)")
+	fs.StringVar(&opts.CPUProfile, "cpuprofile", "", "Write a CPU profile of the whole benchmark to this file")
+	fs.StringVar(&opts.MemProfile, "memprofile", "", "Write a heap profile to this file once the benchmark finishes")
+	fs.BoolVar(&opts.GoroutineCheck, "goroutine-check", false, "After each run, warn when the goroutine count doesn't return to its pre-run level")
+	fs.DurationVar(&opts.GoroutineGrace, "goroutine-grace", DefaultGoroutineGrace, "How long -goroutine-check waits for goroutines to exit")
+	fs.BoolVar(&opts.Transactions, "transactions", false, "Run each query as an explicit BEGIN; SELECT; UPDATE; COMMIT transaction")
+	fs.StringVar(&opts.SessionState, "session-state", "", "After each level's measured runs, rerun it with every worker holding session state (set or advisory-lock) and report whether connections got pinned")
+	fs.BoolVar(&opts.CacheTest, "cache-test", false, "Before each connection type's first level, reset pg_stat counters and compare a cold run with a warm one, with benchmark_data buffer hit ratios")
+	fs.DurationVar(&opts.CacheSettle, "cache-settle", DefaultCacheSettle, "How long -cache-test waits after each run for backends to flush their I/O counters")
+	fs.IntVar(&opts.BatchSize, "batch-size", 0, "Run each query as a pipelined pgx batch of this many queries sent in one round trip (0 or 1 disables)")
+	fs.Float64Var(&opts.RollbackRatio, "rollback-ratio", 0.1, "Fraction of -transactions transactions deliberately rolled back instead of committed")
+	fs.IntVar(&opts.Iterations, "iterations", 1, "Measured runs per concurrency level; the report aggregates them with 95% confidence intervals")
+	fs.DurationVar(&opts.RunPause, "run-pause", DefaultRunPause, "Pause after each warmup run")
+	fs.DurationVar(&opts.LevelPause, "level-pause", DefaultLevelPause, "Pause between concurrency levels")
+	fs.BoolVar(&opts.Fast, "fast", false, "Skip the pauses between runs and levels and the idle test, for quick CI runs; -run-pause, -level-pause and -idle-gaps given explicitly still apply")
+	fs.DurationVar(&opts.RampUp, "rampup", 0, "Stagger worker launches linearly over this period instead of starting them all at once")
+	fs.StringVar(&opts.ReportFormat, "report-format", ReportFormatText, "Also write the report as markdown (benchmark_results.md) or html (benchmark_results.html); text writes only benchmark_results.txt")
+	opts.ReportMetrics = append([]string(nil), DefaultReportMetrics...)
+	fs.Var((*stringList)(&opts.ReportMetrics), "report-metrics", "Comma-separated metrics the text report shows per run, or all: "+strings.Join(reportMetricNames(), ", "))
+	fs.IntVar(&opts.HistogramBuckets, "histogram-buckets", 10, "Number of log-scaled buckets in the acquisition-time histogram (0 disables it)")
+	fs.StringVar(&opts.ReportFile, "report-file", DefaultReportFile, "Path of the text report; relative paths go under -outdir, and -report-format writes its report next to it")
+	fs.BoolVar(&opts.NoClobber, "no-clobber", false, "Never overwrite an existing report; write it under a timestamped name instead")
+	fs.StringVar(&opts.OutDir, "outdir", "", "Write reports here, and CSV, NDJSON and trace files to a subdirectory per connection type (default: working directory)")
+	fs.Float64Var(&opts.TraceSampleRatio, "trace-sample-ratio", 0, "Fraction of worker traces to keep, between 0 and 1 (default: all of them up to 1000 concurrency, about 1000 traces per run above that)")
+	fs.IntVar(&opts.TraceKeepSlowest, "trace-keep-slowest", NumSlowestToExport, "When sampling, also keep the N slowest traces of each connection type regardless of -trace-sample-ratio (0 samples by ratio alone)")
+	fs.Var((*stringList)(&opts.SpanAttributeKeys), "span-attributes", "Comma-separated span attribute keys to export besides conn_type, pgbouncer.pool_mode, server.address, server.port, rows, batch.size and the pool.* state of acquisition spans")
+	fs.BoolVar(&opts.DeterministicTraceIDs, "deterministic-trace-ids", false, "Derive trace and span IDs from -seed and each query's run, worker and position, so exported traces can be diffed between runs")
+	fs.BoolVar(&opts.TracePerFile, "trace-per-file", false, "Write each slowest trace to its own trace_<id>_<duration>.json instead of one combined file")
+	fs.StringVar(&opts.TraceFormat, "trace-format", TraceFormatLegacy, "Schema of exported trace files: legacy (batches/instrumentationLibrarySpans) or otlp (resourceSpans/scopeSpans)")
+	fs.StringVar(&opts.TraceSort, "trace-sort", TraceSortWall, "Rank slowest traces by wall-clock duration (wall) or by critical path through the span tree (critical-path)")
+	fs.IntVar(&opts.MaxSpans, "max-spans", DefaultMaxCollectedSpans, "Maximum spans kept in memory per connection type (0 = unlimited)")
+	fs.StringVar(&opts.OTLPEndpoint, "otlp-endpoint", "", "Also stream spans to this OTLP collector URL (e.g. http://localhost:4317)")
+	fs.StringVar(&opts.OTLPProtocol, "otlp-protocol", "grpc", "OTLP transport for -otlp-endpoint: grpc or http")
+	fs.StringVar(&opts.MetricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address (e.g. :2112); disabled when empty")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	// -fast zeroes the non-essential waits the user didn't set explicitly
+	if opts.Fast {
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["run-pause"] {
+			opts.RunPause = 0
+		}
+		if !set["level-pause"] {
+			opts.LevelPause = 0
+		}
+		if !set["idle-gaps"] {
+			opts.IdleGaps = nil
+		}
+	}
+
+	switch opts.ReportFormat {
+	case ReportFormatText, ReportFormatMarkdown, ReportFormatHTML:
+	default:
+		return opts, fmt.Errorf("-report-format must be %s, %s or %s", ReportFormatText, ReportFormatMarkdown, ReportFormatHTML)
+	}
+
+	if err := validateReportMetrics(opts.ReportMetrics); err != nil {
+		return opts, fmt.Errorf("-report-metrics: %w", err)
+	}
+
+	if opts.TraceSort != TraceSortWall && opts.TraceSort != TraceSortCriticalPath {
+		return opts, fmt.Errorf("-trace-sort must be %s or %s", TraceSortWall, TraceSortCriticalPath)
+	}
+
+	if opts.HdrDigits < 0 || opts.HdrDigits > 5 {
+		return opts, fmt.Errorf("-hdr-digits must be between 0 and 5")
+	}
+	if opts.HdrMax < 2*hdrUnit {
+		return opts, fmt.Errorf("-hdr-max must be at least %v", 2*hdrUnit)
+	}
+
+	if opts.TraceFormat != TraceFormatLegacy && opts.TraceFormat != TraceFormatOTLP {
+		return opts, fmt.Errorf("-trace-format must be %s or %s", TraceFormatLegacy, TraceFormatOTLP)
+	}
+
+	if opts.LogFormat != LogFormatText && opts.LogFormat != LogFormatJSON {
+		return opts, fmt.Errorf("-log-format must be %s or %s", LogFormatText, LogFormatJSON)
+	}
+
+	switch opts.TLS.SSLMode {
+	case "", "disable", "require", "verify-ca", "verify-full":
+	default:
+		return opts, fmt.Errorf("-sslmode must be disable, require, verify-ca or verify-full")
+	}
+
+	for _, levels := range []struct {
+		flag   string
+		levels []int
+	}{
+		{"-concurrency", opts.ConcurrencyLevels},
+		{"-session-concurrency", opts.SessionConcurrency},
+		{"-transaction-concurrency", opts.TransactionConcurrency},
+	} {
+		for _, level := range levels.levels {
+			if level < 1 {
+				return opts, fmt.Errorf("%s levels must be at least 1", levels.flag)
+			}
+		}
+	}
+
+	if err := opts.ArgDist.validate(); err != nil {
+		return opts, err
+	}
+
+	if opts.IdleCycles < 1 {
+		return opts, fmt.Errorf("-idle-cycles must be at least 1")
+	}
+
+	if opts.QueryTimeout < 0 {
+		return opts, fmt.Errorf("-query-timeout must not be negative")
+	}
+
+	if opts.WatchdogTimeout < 0 {
+		return opts, fmt.Errorf("-watchdog must not be negative")
+	}
+
+	if opts.Record != "" && opts.Replay != "" {
+		return opts, fmt.Errorf("-record and -replay cannot be combined")
+	}
+
+	if opts.QueriesPerConn < 1 {
+		return opts, fmt.Errorf("-queries-per-conn must be at least 1")
+	}
+
+	if opts.ReapIdleTime <= 0 {
+		return opts, fmt.Errorf("-reap-idle-time must be positive")
+	}
+
+	if opts.TraceSampleRatio < 0 || opts.TraceSampleRatio > 1 {
+		return opts, fmt.Errorf("-trace-sample-ratio must be between 0 and 1")
+	}
+
+	if opts.BatchSize < 0 {
+		return opts, fmt.Errorf("-batch-size must not be negative")
+	}
+
+	if opts.BatchSize > 1 && opts.Transactions {
+		return opts, fmt.Errorf("-batch-size and -transactions are separate workloads and can't be combined")
+	}
+
+	if opts.FindMax {
+		if opts.FindMaxStart < 1 || opts.FindMaxLimit < opts.FindMaxStart {
+			return opts, fmt.Errorf("-find-max-start must be at least 1 and no higher than -find-max-limit")
+		}
+		if opts.CacheTest || opts.SessionState != "" {
+			return opts, fmt.Errorf("-find-max replaces the concurrency sweep, so it can't be combined with -cache-test or -session-state")
+		}
+	}
+
+	if opts.CacheTest && opts.Parallel {
+		return opts, fmt.Errorf("-cache-test resets database-wide counters, so it can't be combined with -parallel")
+	}
+
+	if opts.ReportFile == "" {
+		return opts, fmt.Errorf("-report-file must not be empty")
+	}
+
+	switch opts.SessionState {
+	case "", SessionStateSet, SessionStateAdvisoryLock:
+	default:
+		return opts, fmt.Errorf("-session-state must be %s or %s, got %q", SessionStateSet, SessionStateAdvisoryLock, opts.SessionState)
+	}
+
+	if opts.RowsPerQuery < 1 {
+		return opts, fmt.Errorf("-rows-per-query must be at least 1")
+	}
+
+	if opts.GoroutineCheck && opts.Parallel {
+		return opts, fmt.Errorf("-goroutine-check counts every goroutine in the process, so it can't be combined with -parallel")
+	}
+
+	if opts.RollbackRatio < 0 || opts.RollbackRatio > 1 {
+		return opts, fmt.Errorf("-rollback-ratio must be between 0 and 1")
+	}
+
+	if opts.Iterations < 1 {
+		return opts, fmt.Errorf("-iterations must be at least 1")
+	}
+
+	if opts.Warmups < 0 {
+		return opts, fmt.Errorf("-warmups must not be negative")
+	}
+
+	if opts.SinglePool && opts.SinglePoolSize <= 0 {
+		return opts, fmt.Errorf("-single-pool-size must be positive")
+	}
+
+	if opts.TargetQPS > 0 && opts.Duration <= 0 {
+		return opts, fmt.Errorf("-target-qps requires -duration")
+	}
+
+	return opts, nil
+}
+
+// durationList is a flag.Value holding a comma-separated list of durations
+type durationList []time.Duration
+
+func (d *durationList) String() string {
+	if d == nil {
+		return ""
+	}
+	parts := make([]string, len(*d))
+	for i, v := range *d {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func (d *durationList) Set(value string) error {
+	var list durationList
+	for _, part := range strings.Split(value, ",") {
+		v, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+		list = append(list, v)
+	}
+	*d = list
+	return nil
+}
+
+// stringList is a flag.Value holding a comma-separated list of strings
+type stringList []string
+
+func (s *stringList) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	var list stringList
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("empty list")
+	}
+	*s = list
+	return nil
+}
+
+// execModeNames maps -exec-mode values to pgx query exec modes
+var execModeNames = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// execModeName returns the -exec-mode spelling of a pgx query exec mode
+func execModeName(mode pgx.QueryExecMode) string {
+	if mode == 0 {
+		mode = pgx.QueryExecModeCacheStatement
+	}
+	for name, m := range execModeNames {
+		if m == mode {
+			return name
+		}
+	}
+	return mode.String()
+}
+
+// execModeFlag is a flag.Value selecting a pgx query exec mode by name
+type execModeFlag pgx.QueryExecMode
+
+func (m *execModeFlag) String() string {
+	if m == nil {
+		return ""
+	}
+	return execModeName(pgx.QueryExecMode(*m))
+}
+
+func (m *execModeFlag) Set(value string) error {
+	mode, ok := execModeNames[strings.TrimSpace(value)]
+	if !ok {
+		return fmt.Errorf("unknown exec mode %q", value)
+	}
+	*m = execModeFlag(mode)
+	return nil
+}
+
+// intList is a flag.Value holding comma-separated integers
+type intList []int
+
+func (l *intList) String() string {
+	if l == nil {
+		return ""
+	}
+	parts := make([]string, len(*l))
+	for i, v := range *l {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *intList) Set(value string) error {
+	var list intList
+	for _, part := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+		list = append(list, v)
+	}
+	*l = list
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFastZeroesPausesAndSkipsIdleTest(t *testing.T) {
+	opts, err := parseOptions([]string{"-fast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.RunPause != 0 || opts.LevelPause != 0 || len(opts.IdleGaps) != 0 {
+		t.Errorf("-fast left run pause %v, level pause %v, idle gaps %v", opts.RunPause, opts.LevelPause, opts.IdleGaps)
+	}
+}
+
+func TestFastKeepsExplicitWaits(t *testing.T) {
+	opts, err := parseOptions([]string{"-fast", "-level-pause", "3s", "-idle-gaps", "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.RunPause != 0 {
+		t.Errorf("run pause = %v, want 0", opts.RunPause)
+	}
+	if opts.LevelPause != 3*time.Second {
+		t.Errorf("level pause = %v, want 3s", opts.LevelPause)
+	}
+	if len(opts.IdleGaps) != 1 || opts.IdleGaps[0] != time.Second {
+		t.Errorf("idle gaps = %v, want [1s]", opts.IdleGaps)
+	}
+}
+
+func TestParseOptionsShardDSNs(t *testing.T) {
+	opts, err := parseOptions([]string{"-transaction-dsns", "postgres://a:6433/db, postgres://b:6433/db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.TransactionDSNs) != 2 || opts.TransactionDSNs[1] != "postgres://b:6433/db" {
+		t.Errorf("TransactionDSNs = %q", opts.TransactionDSNs)
+	}
+	if opts.SessionDSNs != nil {
+		t.Errorf("SessionDSNs = %q, want none", opts.SessionDSNs)
+	}
+}
+
+func TestParseOptionsSessionState(t *testing.T) {
+	opts, err := parseOptions([]string{"-session-state", "advisory-lock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.SessionState != SessionStateAdvisoryLock {
+		t.Errorf("SessionState = %q, want %q", opts.SessionState, SessionStateAdvisoryLock)
+	}
+
+	if _, err := parseOptions([]string{"-session-state", "prepare"}); err == nil {
+		t.Error("expected an error for an unknown -session-state")
+	}
+}
+
+func TestParseOptionsReportFile(t *testing.T) {
+	opts, err := parseOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ReportFile != DefaultReportFile || opts.NoClobber {
+		t.Errorf("defaults = %q, no-clobber %v", opts.ReportFile, opts.NoClobber)
+	}
+
+	if _, err := parseOptions([]string{"-report-file", ""}); err == nil {
+		t.Error("expected an error for an empty -report-file")
+	}
+}
+
+func TestParseOptionsReportMetrics(t *testing.T) {
+	opts, err := parseOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(opts.ReportMetrics, ",") != strings.Join(DefaultReportMetrics, ",") {
+		t.Errorf("default = %v, want %v", opts.ReportMetrics, DefaultReportMetrics)
+	}
+
+	opts, err = parseOptions([]string{"-report-metrics", "acquisition, pool-stats"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.ReportMetrics) != 2 || opts.ReportMetrics[1] != "pool-stats" {
+		t.Errorf("ReportMetrics = %v", opts.ReportMetrics)
+	}
+
+	if _, err := parseOptions([]string{"-report-metrics", "latency"}); err == nil {
+		t.Error("expected an error for an unknown metric")
+	}
+}
+
+func TestParseOptionsQueryTimeout(t *testing.T) {
+	opts, err := parseOptions([]string{"-query-timeout", "250ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.QueryTimeout != 250*time.Millisecond {
+		t.Errorf("QueryTimeout = %v, want 250ms", opts.QueryTimeout)
+	}
+	if _, err := parseOptions([]string{"-query-timeout", "-1s"}); err == nil {
+		t.Error("expected an error for a negative -query-timeout")
+	}
+}
+
+func TestParseOptionsWatchdog(t *testing.T) {
+	opts, err := parseOptions([]string{"-watchdog", "10m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.WatchdogTimeout != 10*time.Minute || opts.Watchdog.Expired() {
+		t.Errorf("WatchdogTimeout = %v, want 10m and the watchdog not yet started", opts.WatchdogTimeout)
+	}
+	if _, err := parseOptions([]string{"-watchdog", "-1s"}); err == nil {
+		t.Error("expected an error for a negative -watchdog")
+	}
+}
+
+func TestParseOptionsRecordNotWithReplay(t *testing.T) {
+	if _, err := parseOptions([]string{"-record", "a.ndjson", "-replay", "b.ndjson"}); err == nil {
+		t.Error("expected an error combining -record with -replay")
+	}
+}
+
+func TestParseOptionsCacheTestNotParallel(t *testing.T) {
+	if _, err := parseOptions([]string{"-cache-test", "-parallel"}); err == nil {
+		t.Error("expected an error combining -cache-test with -parallel")
+	}
+}
+
+func TestParseOptionsTraceFormat(t *testing.T) {
+	opts, err := parseOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.TraceFormat != TraceFormatLegacy {
+		t.Errorf("TraceFormat = %q, want %q", opts.TraceFormat, TraceFormatLegacy)
+	}
+
+	if _, err := parseOptions([]string{"-trace-format", "jaeger"}); err == nil {
+		t.Error("expected an error for an unknown -trace-format")
+	}
+}
+
+func TestParseOptionsHdrHistogram(t *testing.T) {
+	if _, err := parseOptions([]string{"-hdr-digits", "6"}); err == nil {
+		t.Error("expected an error for -hdr-digits above 5")
+	}
+	if _, err := parseOptions([]string{"-hdr-max", "1us"}); err == nil {
+		t.Error("expected an error for a -hdr-max below two microseconds")
+	}
+}
+
+func TestParseOptionsFindMax(t *testing.T) {
+	opts, err := parseOptions([]string{"-find-max", "-find-max-start", "5", "-find-max-limit", "500"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.FindMax || opts.FindMaxStart != 5 || opts.FindMaxLimit != 500 {
+		t.Errorf("got find-max %v from %d to %d", opts.FindMax, opts.FindMaxStart, opts.FindMaxLimit)
+	}
+
+	for _, args := range [][]string{
+		{"-find-max", "-find-max-start", "0"},
+		{"-find-max", "-find-max-start", "100", "-find-max-limit", "50"},
+		{"-find-max", "-cache-test"},
+	} {
+		if _, err := parseOptions(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestParseOptionsConcurrencyOverrides(t *testing.T) {
+	opts, err := parseOptions([]string{"-concurrency", "100", "-session-concurrency", "100,1000", "-transaction-concurrency", "500,5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(opts.SessionConcurrency, []int{100, 1000}) || !slices.Equal(opts.TransactionConcurrency, []int{500, 5000}) {
+		t.Errorf("got session %v, transaction %v", opts.SessionConcurrency, opts.TransactionConcurrency)
+	}
+
+	for _, args := range [][]string{
+		{"-session-concurrency", "0"},
+		{"-transaction-concurrency", "10,-1"},
+	} {
+		if _, err := parseOptions(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+
+	config := Config{ConcurrencyLevels: []int{500}}
+	if got := config.levels([]int{100}); !slices.Equal(got, []int{500}) {
+		t.Errorf("overridden levels = %v", got)
+	}
+	if got := (Config{}).levels([]int{100}); !slices.Equal(got, []int{100}) {
+		t.Errorf("default levels = %v", got)
+	}
+}
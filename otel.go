@@ -4,13 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
@@ -19,15 +24,29 @@ import (
 
 // TraceCollector collects spans in memory for later export
 type TraceCollector struct {
-	mu     sync.Mutex
-	spans  []sdktrace.ReadOnlySpan
-	tracer trace.Tracer
+	mu       sync.Mutex
+	spans    []sdktrace.ReadOnlySpan
+	maxSpans int   // 0 means unlimited
+	dropped  int64 // spans discarded because maxSpans was reached
+	tracer   trace.Tracer
+
+	// flush hands over spans still queued in the batch span processor
+	flush func(context.Context) error
+	// guard filters the attributes of exported traces; nil allows all
+	guard *AttributeGuard
 }
 
 // NewTraceCollector creates a new in-memory trace collector
 func NewTraceCollector() *TraceCollector {
+	return NewTraceCollectorWithLimit(0)
+}
+
+// NewTraceCollectorWithLimit creates an in-memory trace collector that retains at
+// most maxSpans spans; further spans are counted as dropped. 0 means unlimited.
+func NewTraceCollectorWithLimit(maxSpans int) *TraceCollector {
 	return &TraceCollector{
-		spans: make([]sdktrace.ReadOnlySpan, 0),
+		spans:    make([]sdktrace.ReadOnlySpan, 0),
+		maxSpans: maxSpans,
 	}
 }
 
@@ -35,6 +54,18 @@ func NewTraceCollector() *TraceCollector {
 func (tc *TraceCollector) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
+
+	if tc.maxSpans > 0 {
+		room := tc.maxSpans - len(tc.spans)
+		if room < 0 {
+			room = 0
+		}
+		if len(spans) > room {
+			tc.dropped += int64(len(spans) - room)
+			spans = spans[:room]
+		}
+	}
+
 	tc.spans = append(tc.spans, spans...)
 	return nil
 }
@@ -44,23 +75,166 @@ func (tc *TraceCollector) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-// GetSpans returns all collected spans
+// MaxSpans returns the configured span cap (0 means unlimited)
+func (tc *TraceCollector) MaxSpans() int {
+	return tc.maxSpans
+}
+
+// DroppedSpans returns how many spans were discarded since the last ClearSpans
+func (tc *TraceCollector) DroppedSpans() int64 {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.dropped
+}
+
+// GetSpans returns all collected spans, including any still queued for export
 func (tc *TraceCollector) GetSpans() []sdktrace.ReadOnlySpan {
+	tc.flushQueued()
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
 	return tc.spans
 }
 
-// ClearSpans clears all collected spans
+// ClearSpans clears all collected spans, including any still queued for export
 func (tc *TraceCollector) ClearSpans() {
+	tc.flushQueued()
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
 	tc.spans = make([]sdktrace.ReadOnlySpan, 0)
+	tc.dropped = 0
+}
+
+// flushQueued waits for spans that have ended but are still queued in the
+// batch span processor to reach the collector
+func (tc *TraceCollector) flushQueued() {
+	if tc.flush == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tc.flush(ctx); err != nil {
+		slog.Warn("Failed to flush spans", "error", err)
+	}
+}
+
+// TracerConfig configures the tracer provider set up by InitTracerWithConfig
+type TracerConfig struct {
+	ServiceName string
+
+	// MaxSpans caps how many spans the in-memory collector retains (0 means unlimited)
+	MaxSpans int
+
+	// OTLPEndpoint, when set, additionally streams spans to an OTLP collector
+	// (e.g. http://localhost:4317). The in-memory collector is always registered.
+	OTLPEndpoint string
+	// OTLPProtocol selects the OTLP transport: "grpc" (default) or "http"
+	OTLPProtocol string
+
+	// IDGenerator, when set, replaces the SDK's random trace and span IDs
+	IDGenerator sdktrace.IDGenerator
+
+	// SampleRatio is the fraction of traces kept; 0 or 1 and above keep them all.
+	// With KeepSlowest, the KeepSlowest slowest traces of each connection type are
+	// kept as well, which requires recording every trace until its root ends.
+	SampleRatio float64
+	KeepSlowest int
+
+	// SpanAttributeKeys are allowed on exported spans besides DefaultSpanAttributeKeys
+	SpanAttributeKeys []attribute.Key
+}
+
+// DefaultSpanAttributeKeys are the span attribute keys exported by default: the
+// target a request hit and low-cardinality counts, including the pool state on
+// acquisition spans. Anything else, such as a worker ID or timestamp, would
+// give Tempo a new series per span.
+var DefaultSpanAttributeKeys = []attribute.Key{
+	AttrConnType,
+	AttrPoolMode,
+	semconv.ServerAddressKey,
+	semconv.ServerPortKey,
+	"rows",
+	"batch.size",
+	AttrPoolAcquireState,
+	AttrPoolIdleConns,
+	AttrPoolAcquiredConns,
+	AttrPoolTotalConns,
+	AttrPoolMaxConns,
+}
+
+// AttributeGuard keeps exported span attributes to an allow-list of keys, so
+// high-cardinality attributes can't blow up OTLP exports. A nil guard allows all.
+type AttributeGuard struct {
+	allowed map[attribute.Key]bool
+}
+
+// NewAttributeGuard allows the given keys
+func NewAttributeGuard(keys ...attribute.Key) *AttributeGuard {
+	allowed := make(map[attribute.Key]bool, len(keys))
+	for _, key := range keys {
+		allowed[key] = true
+	}
+	return &AttributeGuard{allowed: allowed}
+}
+
+// Filter returns the allowed attributes and how many were stripped
+func (g *AttributeGuard) Filter(attrs []attribute.KeyValue) ([]attribute.KeyValue, int) {
+	if g == nil {
+		return attrs, 0
+	}
+	kept := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		if g.allowed[attr.Key] {
+			kept = append(kept, attr)
+		}
+	}
+	return kept, len(attrs) - len(kept)
+}
+
+// Span returns span with its attributes filtered, counting the stripped ones
+// among its dropped attributes
+func (g *AttributeGuard) Span(span sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	attrs, stripped := g.Filter(span.Attributes())
+	if stripped == 0 {
+		return span
+	}
+	return guardedSpan{ReadOnlySpan: span, attrs: attrs, dropped: span.DroppedAttributes() + stripped}
+}
+
+// guardedSpan is a span seen through an AttributeGuard
+type guardedSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs   []attribute.KeyValue
+	dropped int
+}
+
+func (s guardedSpan) Attributes() []attribute.KeyValue { return s.attrs }
+func (s guardedSpan) DroppedAttributes() int           { return s.dropped }
+
+// guardedExporter filters span attributes through guard before exporting
+type guardedExporter struct {
+	sdktrace.SpanExporter
+	guard *AttributeGuard
+}
+
+func (e guardedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	guarded := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		guarded[i] = e.guard.Span(span)
+	}
+	return e.SpanExporter.ExportSpans(ctx, guarded)
 }
 
 // InitTracer initializes OpenTelemetry tracer with in-memory collector
 func InitTracer(serviceName string) (*TraceCollector, func(), error) {
-	collector := NewTraceCollector()
+	return InitTracerWithConfig(TracerConfig{ServiceName: serviceName})
+}
+
+// InitTracerWithConfig initializes OpenTelemetry tracer with in-memory collector
+// and any additional exporters enabled in cfg
+func InitTracerWithConfig(cfg TracerConfig) (*TraceCollector, func(), error) {
+	serviceName := cfg.ServiceName
+	collector := NewTraceCollectorWithLimit(cfg.MaxSpans)
+	collector.guard = NewAttributeGuard(append(slices.Clone(DefaultSpanAttributeKeys), cfg.SpanAttributeKeys...)...)
 
 	// Create resource with service information
 	res, err := resource.New(
@@ -76,11 +250,41 @@ func InitTracer(serviceName string) (*TraceCollector, func(), error) {
 	}
 
 	// Create trace provider with our collector
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(collector),
+	processors := []sdktrace.SpanProcessor{sdktrace.NewBatchSpanProcessor(collector)}
+
+	// Optionally stream spans to an OTLP collector as well
+	if cfg.OTLPEndpoint != "" {
+		exporter, err := newOTLPExporter(cfg.OTLPEndpoint, cfg.OTLPProtocol)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		processors = append(processors, sdktrace.NewBatchSpanProcessor(guardedExporter{SpanExporter: exporter, guard: collector.guard}))
+	}
+
+	// Sample whole traces: by trace ID up front, or after the fact when the
+	// slowest traces have to be kept regardless
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		if cfg.KeepSlowest > 0 {
+			processors = []sdktrace.SpanProcessor{newTailSampler(cfg.SampleRatio, cfg.KeepSlowest, processors...)}
+		} else {
+			sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))
+		}
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-	)
+		sdktrace.WithSampler(sampler),
+	}
+	for _, processor := range processors {
+		providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(processor))
+	}
+	if cfg.IDGenerator != nil {
+		providerOpts = append(providerOpts, sdktrace.WithIDGenerator(cfg.IDGenerator))
+	}
+
+	tp := sdktrace.NewTracerProvider(providerOpts...)
+	collector.flush = tp.ForceFlush
 
 	// Set global tracer provider
 	otel.SetTracerProvider(tp)
@@ -90,13 +294,27 @@ func InitTracer(serviceName string) (*TraceCollector, func(), error) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := tp.Shutdown(ctx); err != nil {
-			fmt.Printf("Error shutting down tracer provider: %v\n", err)
+			slog.Warn("Failed to shut down tracer provider", "error", err)
 		}
 	}
 
 	return collector, cleanup, nil
 }
 
+// newOTLPExporter creates an OTLP span exporter for the given endpoint URL and protocol
+func newOTLPExporter(endpoint, protocol string) (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+
+	switch protocol {
+	case "", "grpc":
+		return otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	case "http":
+		return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q (want grpc or http)", protocol)
+	}
+}
+
 // GetTracer returns a tracer instance
 func GetTracer(name string) trace.Tracer {
 	return otel.Tracer(name)
@@ -110,15 +328,34 @@ type OTLPSpan struct {
 	TraceState             string          `json:"traceState"`
 	Name                   string          `json:"name"`
 	Kind                   string          `json:"kind"`
-	StartTimeUnixNano      int64           `json:"startTimeUnixNano"`
-	EndTimeUnixNano        int64           `json:"endTimeUnixNano"`
+	StartTimeUnixNano      string          `json:"startTimeUnixNano"` // uint64 encoded as a string per OTLP/JSON
+	EndTimeUnixNano        string          `json:"endTimeUnixNano"`
 	Attributes             []OTLPAttribute `json:"attributes,omitempty"`
 	DroppedAttributesCount int             `json:"droppedAttributesCount"`
+	Events                 []OTLPEvent     `json:"events,omitempty"`
 	DroppedEventsCount     int             `json:"droppedEventsCount"`
+	Links                  []OTLPLink      `json:"links,omitempty"`
 	DroppedLinksCount      int             `json:"droppedLinksCount"`
 	Status                 OTLPStatus      `json:"status"`
 }
 
+// OTLPEvent represents a timestamped event recorded on a span
+type OTLPEvent struct {
+	TimeUnixNano           string          `json:"timeUnixNano"`
+	Name                   string          `json:"name"`
+	Attributes             []OTLPAttribute `json:"attributes,omitempty"`
+	DroppedAttributesCount int             `json:"droppedAttributesCount"`
+}
+
+// OTLPLink represents a link from a span to a span in the same or another trace
+type OTLPLink struct {
+	TraceID                string          `json:"traceId"`
+	SpanID                 string          `json:"spanId"`
+	TraceState             string          `json:"traceState"`
+	Attributes             []OTLPAttribute `json:"attributes,omitempty"`
+	DroppedAttributesCount int             `json:"droppedAttributesCount"`
+}
+
 // OTLPAttribute represents a span attribute
 type OTLPAttribute struct {
 	Key   string    `json:"key"`
@@ -127,10 +364,16 @@ type OTLPAttribute struct {
 
 // OTLPValue represents an attribute value in OTLP format
 type OTLPValue struct {
-	StringValue *string  `json:"stringValue,omitempty"`
-	IntValue    *int64   `json:"intValue,omitempty"`
-	DoubleValue *float64 `json:"doubleValue,omitempty"`
-	BoolValue   *bool    `json:"boolValue,omitempty"`
+	StringValue *string         `json:"stringValue,omitempty"`
+	IntValue    *int64          `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	ArrayValue  *OTLPArrayValue `json:"arrayValue,omitempty"`
+}
+
+// OTLPArrayValue holds the elements of a slice-typed attribute
+type OTLPArrayValue struct {
+	Values []OTLPValue `json:"values"`
 }
 
 // OTLPStatus represents span status
@@ -168,7 +411,42 @@ type OTLPInstrumentationLibrary struct {
 	Version string `json:"version,omitempty"`
 }
 
-// ConvertSpanToOTLP converts a ReadOnlySpan to OTLP format
+// Shapes of exported trace files
+const (
+	TraceFormatLegacy = "legacy" // batches/instrumentationLibrarySpans, the pre-1.0 OTLP/JSON shape older Tempo versions import
+	TraceFormatOTLP   = "otlp"   // resourceSpans/scopeSpans, the OTLP 1.0 TracesData shape current collectors expect
+)
+
+// OTLPTracesData represents a trace in the current OTLP/JSON schema
+type OTLPTracesData struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+// OTLPResourceSpans groups the spans recorded with one resource
+type OTLPResourceSpans struct {
+	Resource   OTLPResource     `json:"resource"`
+	ScopeSpans []OTLPScopeSpans `json:"scopeSpans"`
+}
+
+// OTLPScopeSpans groups spans by instrumentation scope
+type OTLPScopeSpans struct {
+	Scope OTLPInstrumentationScope `json:"scope"`
+	Spans []OTLPScopeSpan          `json:"spans"`
+}
+
+// OTLPInstrumentationScope is the OTLP 1.0 successor of OTLPInstrumentationLibrary
+type OTLPInstrumentationScope = OTLPInstrumentationLibrary
+
+// OTLPScopeSpan is a span in the current schema, which encodes enums such as
+// the span kind as integers rather than names
+type OTLPScopeSpan struct {
+	OTLPSpan
+	Kind int `json:"kind"`
+}
+
+// ConvertSpanToOTLP converts a ReadOnlySpan to OTLP format. Attributes the
+// SDK dropped for exceeding span limits, or an AttributeGuard stripped (see
+// AttributeGuard.Span), are counted as dropped.
 func ConvertSpanToOTLP(span sdktrace.ReadOnlySpan) OTLPSpan {
 	otlpSpan := OTLPSpan{
 		TraceID:                span.SpanContext().TraceID().String(),
@@ -176,12 +454,9 @@ func ConvertSpanToOTLP(span sdktrace.ReadOnlySpan) OTLPSpan {
 		TraceState:             "",
 		Name:                   span.Name(),
 		Kind:                   convertSpanKind(span.SpanKind()),
-		StartTimeUnixNano:      span.StartTime().UnixNano(),
-		EndTimeUnixNano:        span.EndTime().UnixNano(),
-		Attributes:             make([]OTLPAttribute, 0),
-		DroppedAttributesCount: 0,
-		DroppedEventsCount:     0,
-		DroppedLinksCount:      0,
+		StartTimeUnixNano:      strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:        strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		DroppedAttributesCount: span.DroppedAttributes(),
 		Status: OTLPStatus{
 			Code:    convertStatusCode(span.Status().Code),
 			Message: span.Status().Description,
@@ -194,35 +469,93 @@ func ConvertSpanToOTLP(span sdktrace.ReadOnlySpan) OTLPSpan {
 	}
 
 	// Convert attributes
-	for _, attr := range span.Attributes() {
-		otlpAttr := OTLPAttribute{
-			Key: string(attr.Key),
-		}
+	otlpSpan.Attributes = convertAttributes(span.Attributes())
+
+	// Convert events, keeping the SDK's count of events dropped by span limits
+	for _, event := range span.Events() {
+		otlpSpan.Events = append(otlpSpan.Events, OTLPEvent{
+			TimeUnixNano:           strconv.FormatInt(event.Time.UnixNano(), 10),
+			Name:                   event.Name,
+			Attributes:             convertAttributes(event.Attributes),
+			DroppedAttributesCount: event.DroppedAttributeCount,
+		})
+	}
+	otlpSpan.DroppedEventsCount = span.DroppedEvents()
+
+	// Convert links, keeping the SDK's count of links dropped by span limits
+	for _, link := range span.Links() {
+		otlpSpan.Links = append(otlpSpan.Links, OTLPLink{
+			TraceID:                link.SpanContext.TraceID().String(),
+			SpanID:                 link.SpanContext.SpanID().String(),
+			TraceState:             link.SpanContext.TraceState().String(),
+			Attributes:             convertAttributes(link.Attributes),
+			DroppedAttributesCount: link.DroppedAttributeCount,
+		})
+	}
+	otlpSpan.DroppedLinksCount = span.DroppedLinks()
 
-		// Convert value to proper OTLP format based on type
-		switch attr.Value.Type() {
-		case attribute.STRING:
-			strVal := attr.Value.AsString()
-			otlpAttr.Value.StringValue = &strVal
-		case attribute.INT64:
-			intVal := attr.Value.AsInt64()
-			otlpAttr.Value.IntValue = &intVal
-		case attribute.FLOAT64:
-			floatVal := attr.Value.AsFloat64()
-			otlpAttr.Value.DoubleValue = &floatVal
-		case attribute.BOOL:
-			boolVal := attr.Value.AsBool()
-			otlpAttr.Value.BoolValue = &boolVal
-		default:
-			// Fallback to string representation
-			strVal := attr.Value.AsString()
-			otlpAttr.Value.StringValue = &strVal
-		}
+	return otlpSpan
+}
 
-		otlpSpan.Attributes = append(otlpSpan.Attributes, otlpAttr)
+// convertAttributes converts attribute key-values to OTLP format
+func convertAttributes(attrs []attribute.KeyValue) []OTLPAttribute {
+	otlpAttrs := make([]OTLPAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		otlpAttrs = append(otlpAttrs, OTLPAttribute{
+			Key:   string(attr.Key),
+			Value: convertValue(attr.Value),
+		})
 	}
 
-	return otlpSpan
+	return otlpAttrs
+}
+
+// convertValue converts an attribute value to OTLP format based on its type.
+// Slices become an arrayValue of scalar values.
+func convertValue(v attribute.Value) OTLPValue {
+	switch v.Type() {
+	case attribute.STRING:
+		strVal := v.AsString()
+		return OTLPValue{StringValue: &strVal}
+	case attribute.INT64:
+		intVal := v.AsInt64()
+		return OTLPValue{IntValue: &intVal}
+	case attribute.FLOAT64:
+		floatVal := v.AsFloat64()
+		return OTLPValue{DoubleValue: &floatVal}
+	case attribute.BOOL:
+		boolVal := v.AsBool()
+		return OTLPValue{BoolValue: &boolVal}
+	case attribute.STRINGSLICE:
+		return arrayValue(v.AsStringSlice(), attribute.StringValue)
+	case attribute.INT64SLICE:
+		return arrayValue(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return arrayValue(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.BOOLSLICE:
+		return arrayValue(v.AsBoolSlice(), attribute.BoolValue)
+	default:
+		// Fallback to string representation
+		strVal := v.Emit()
+		return OTLPValue{StringValue: &strVal}
+	}
+}
+
+// arrayValue converts the elements of a slice attribute into an OTLP arrayValue
+func arrayValue[T any](elems []T, toValue func(T) attribute.Value) OTLPValue {
+	values := make([]OTLPValue, len(elems))
+	for i, elem := range elems {
+		values[i] = convertValue(toValue(elem))
+	}
+	return OTLPValue{ArrayValue: &OTLPArrayValue{Values: values}}
+}
+
+// ConvertResourceToOTLP converts an SDK resource to OTLP format
+func ConvertResourceToOTLP(res *resource.Resource) OTLPResource {
+	return OTLPResource{
+		Attributes:             convertAttributes(res.Attributes()),
+		DroppedAttributesCount: 0,
+	}
 }
 
 // convertSpanKind converts trace.SpanKind to OTLP string format
@@ -261,45 +594,122 @@ func ExportTraceToJSON(spans []sdktrace.ReadOnlySpan, filename string) error {
 		return fmt.Errorf("no spans to export")
 	}
 
+	trace := OTLPTrace{
+		Batches: []OTLPBatch{newOTLPBatch(spans)},
+	}
+
+	return writeOTLPTrace(trace, filename)
+}
+
+// ExportTracesToJSON exports several traces to one JSON file, one batch per trace,
+// so each trace can be reconstructed on its own by a viewer
+func ExportTracesToJSON(traces [][]sdktrace.ReadOnlySpan, filename string) error {
+	trace := OTLPTrace{
+		Batches: make([]OTLPBatch, 0, len(traces)),
+	}
+	for _, spans := range traces {
+		if len(spans) == 0 {
+			continue
+		}
+		trace.Batches = append(trace.Batches, newOTLPBatch(spans))
+	}
+
+	if len(trace.Batches) == 0 {
+		return fmt.Errorf("no spans to export")
+	}
+
+	return writeOTLPTrace(trace, filename)
+}
+
+// ExportTracesToOTLPJSON exports several traces to one JSON file in the current
+// OTLP/JSON schema, one resourceSpans entry per trace
+func ExportTracesToOTLPJSON(traces [][]sdktrace.ReadOnlySpan, filename string) error {
+	data := OTLPTracesData{
+		ResourceSpans: make([]OTLPResourceSpans, 0, len(traces)),
+	}
+	for _, spans := range traces {
+		if len(spans) == 0 {
+			continue
+		}
+		data.ResourceSpans = append(data.ResourceSpans, newOTLPResourceSpans(spans))
+	}
+
+	if len(data.ResourceSpans) == 0 {
+		return fmt.Errorf("no spans to export")
+	}
+
+	return writeOTLPTrace(data, filename)
+}
+
+// exportTraces writes traces to filename in format, TraceFormatLegacy or
+// TraceFormatOTLP, with their span attributes filtered through guard
+func exportTraces(format string, guard *AttributeGuard, traces [][]sdktrace.ReadOnlySpan, filename string) error {
+	guarded := make([][]sdktrace.ReadOnlySpan, len(traces))
+	for i, spans := range traces {
+		guarded[i] = make([]sdktrace.ReadOnlySpan, len(spans))
+		for j, span := range spans {
+			guarded[i][j] = guard.Span(span)
+		}
+	}
+	traces = guarded
+
+	if format == TraceFormatOTLP {
+		return ExportTracesToOTLPJSON(traces, filename)
+	}
+	return ExportTracesToJSON(traces, filename)
+}
+
+// otlpScope is the instrumentation scope every exported span belongs to
+var otlpScope = OTLPInstrumentationScope{
+	Name:    "pgx-benchmark",
+	Version: "1.0.0",
+}
+
+// newOTLPBatch converts spans into a single OTLP batch
+func newOTLPBatch(spans []sdktrace.ReadOnlySpan) OTLPBatch {
 	// Convert spans to OTLP format
 	otlpSpans := make([]OTLPSpan, 0, len(spans))
 	for _, span := range spans {
 		otlpSpans = append(otlpSpans, ConvertSpanToOTLP(span))
 	}
 
-	// Create OTLP trace structure in Tempo format
-	serviceName := "pgx-benchmark"
-	serviceVersion := "1.0.0"
-
-	trace := OTLPTrace{
-		Batches: []OTLPBatch{
+	// Create OTLP batch in Tempo format
+	return OTLPBatch{
+		Resource: traceResource(spans),
+		InstrumentationLibrarySpans: []OTLPInstrumentationLibrarySpan{
 			{
-				Resource: OTLPResource{
-					Attributes: []OTLPAttribute{
-						{
-							Key:   "service.name",
-							Value: OTLPValue{StringValue: &serviceName},
-						},
-						{
-							Key:   "service.version",
-							Value: OTLPValue{StringValue: &serviceVersion},
-						},
-					},
-					DroppedAttributesCount: 0,
-				},
-				InstrumentationLibrarySpans: []OTLPInstrumentationLibrarySpan{
-					{
-						InstrumentationLibrary: OTLPInstrumentationLibrary{
-							Name:    "pgx-benchmark",
-							Version: "1.0.0",
-						},
-						Spans: otlpSpans,
-					},
-				},
+				InstrumentationLibrary: otlpScope,
+				Spans:                  otlpSpans,
 			},
 		},
 	}
+}
+
+// newOTLPResourceSpans converts spans into a single resourceSpans entry
+func newOTLPResourceSpans(spans []sdktrace.ReadOnlySpan) OTLPResourceSpans {
+	otlpSpans := make([]OTLPScopeSpan, 0, len(spans))
+	for _, span := range spans {
+		// trace.SpanKind numbers its kinds the way the OTLP enum does
+		otlpSpans = append(otlpSpans, OTLPScopeSpan{OTLPSpan: ConvertSpanToOTLP(span), Kind: int(span.SpanKind())})
+	}
+
+	return OTLPResourceSpans{
+		Resource:   traceResource(spans),
+		ScopeSpans: []OTLPScopeSpans{{Scope: otlpScope, Spans: otlpSpans}},
+	}
+}
+
+// traceResource is the resource the spans were actually recorded with
+// (configured in InitTracer), tagged with the target the trace hit so whole
+// batches can be filtered by mode
+func traceResource(spans []sdktrace.ReadOnlySpan) OTLPResource {
+	res := ConvertResourceToOTLP(spans[0].Resource())
+	res.Attributes = append(res.Attributes, convertAttributes(traceTargetAttributes(spans))...)
+	return res
+}
 
+// writeOTLPTrace marshals an OTLP trace, in either schema, and writes it to a file
+func writeOTLPTrace(trace any, filename string) error {
 	// Marshal to JSON with indentation
 	jsonData, err := json.MarshalIndent(trace, "", "  ")
 	if err != nil {
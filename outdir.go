@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// outputPath returns where to write the output file name. With an outdir, files
+// belonging to one connection type go to outdir/<conn type>/ and combined ones
+// (empty connType) to outdir itself; directories are created as needed. Without
+// an outdir everything stays in the working directory.
+func outputPath(outdir string, connType ConnectionType, name string) (string, error) {
+	if outdir == "" {
+		return name, nil
+	}
+
+	dir := outdir
+	if connType != "" {
+		dir = filepath.Join(outdir, string(connType))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// fileTimestampLayout is the timestamp output filenames carry
+const fileTimestampLayout = "20060102150405"
+
+// exportFilename names a connection type's output file
+// <kind>_<conn type>_<detail>_<timestamp><ext>, leaving out an empty detail.
+// Every per-type exporter names its files this way, so modes never overwrite
+// each other's files, with or without -outdir giving each its own directory.
+func exportFilename(kind string, connType ConnectionType, detail string, now time.Time, ext string) string {
+	parts := []string{kind, string(connType)}
+	if detail != "" {
+		parts = append(parts, detail)
+	}
+	parts = append(parts, now.Format(fileTimestampLayout))
+	return strings.Join(parts, "_") + ext
+}
+
+// runDetail describes a run in output filenames:
+// c<concurrency>_<warmup|actual>[_i<iteration>][_<variant>], so the iterations
+// of a level and its reruns (e.g. -session-state's "state") each get a file of
+// their own. A zero iteration and an empty variant are left out.
+func runDetail(concurrency int, isWarmup bool, iteration int, variant string) string {
+	runType := "actual"
+	if isWarmup {
+		runType = "warmup"
+	}
+	detail := fmt.Sprintf("c%d_%s", concurrency, runType)
+	if iteration > 0 {
+		detail += fmt.Sprintf("_i%d", iteration)
+	}
+	if variant != "" {
+		detail += "_" + variant
+	}
+	return detail
+}
+
+// DefaultReportFile is where the text report goes unless -report-file says otherwise
+const DefaultReportFile = "benchmark_results.txt"
+
+// reportPath resolves a report file: relative paths go under outdir like any
+// other combined output, absolute ones stay put. The parent directory is
+// created as needed.
+func reportPath(outdir, name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(name) {
+		var err error
+		if path, err = outputPath(outdir, "", name); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+	return path, nil
+}
+
+// withExt swaps the extension of path for ext, e.g. for the markdown report
+// that goes next to the text one
+func withExt(path, ext string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+}
+
+// createReport creates the report file at path. With noClobber an existing
+// file is left alone and the report gets a timestamped name next to it
+// instead, like the trace files, with a counter if even that is taken.
+func createReport(path string, noClobber bool, now time.Time) (*os.File, error) {
+	if !noClobber {
+		return os.Create(path)
+	}
+
+	base, ext := strings.TrimSuffix(path, filepath.Ext(path)), filepath.Ext(path)
+	stamped := fmt.Sprintf("%s_%s", base, now.Format(fileTimestampLayout))
+	candidate := path
+	for attempt := 1; ; attempt++ {
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !errors.Is(err, fs.ErrExist) {
+			return f, err
+		}
+		if attempt == 1 {
+			candidate = stamped + ext
+		} else {
+			candidate = fmt.Sprintf("%s_%d%s", stamped, attempt, ext)
+		}
+	}
+}
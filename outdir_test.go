@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutputPath(t *testing.T) {
+	if got, err := outputPath("", PgBouncerSession, "a.csv"); err != nil || got != "a.csv" {
+		t.Errorf("without outdir = %q, %v; want a.csv", got, err)
+	}
+
+	outdir := filepath.Join(t.TempDir(), "results")
+	got, err := outputPath(outdir, PgBouncerTransaction, "a.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(outdir, "pgbouncer-transaction", "a.csv"); got != want {
+		t.Errorf("per-type path = %q, want %q", got, want)
+	}
+	if info, err := os.Stat(filepath.Dir(got)); err != nil || !info.IsDir() {
+		t.Errorf("per-type directory not created: %v", err)
+	}
+
+	if got, _ := outputPath(outdir, "", "benchmark_results.txt"); got != filepath.Join(outdir, "benchmark_results.txt") {
+		t.Errorf("combined path = %q", got)
+	}
+}
+
+func TestReportPath(t *testing.T) {
+	outdir := filepath.Join(t.TempDir(), "results")
+	got, err := reportPath(outdir, "runs/nightly.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(outdir, "runs", "nightly.txt"); got != want {
+		t.Errorf("relative report = %q, want %q", got, want)
+	}
+	if info, err := os.Stat(filepath.Dir(got)); err != nil || !info.IsDir() {
+		t.Errorf("report directory not created: %v", err)
+	}
+
+	abs := filepath.Join(t.TempDir(), "elsewhere", "report.txt")
+	if got, err := reportPath(outdir, abs); err != nil || got != abs {
+		t.Errorf("absolute report = %q, %v; want %q", got, err, abs)
+	}
+}
+
+func TestWithExt(t *testing.T) {
+	if got := withExt("out/benchmark_results.txt", ".md"); got != "out/benchmark_results.md" {
+		t.Errorf("got %q", got)
+	}
+	if got := withExt("report", ".html"); got != "report.html" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestCreateReportNoClobber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "benchmark_results.txt")
+	if err := os.WriteFile(path, []byte("earlier run"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	var names []string
+	for i := 0; i < 2; i++ {
+		f, err := createReport(path, true, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.Base(f.Name()))
+		f.Close()
+	}
+	want := []string{"benchmark_results_20260304050607.txt", "benchmark_results_20260304050607_2.txt"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("report %d written to %s, want %s", i, names[i], want[i])
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != "earlier run" {
+		t.Errorf("existing report overwritten: %q", data)
+	}
+
+	// Without -no-clobber the report is replaced in place
+	f, err := createReport(path, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("report not truncated: %q", data)
+	}
+}
+
+func TestExportFilenameSeparatesModes(t *testing.T) {
+	now := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	if got, want := exportFilename("acquisition_times", DirectPostgres, runDetail(50, true, 0, ""), now, ".csv"),
+		"acquisition_times_direct-postgres_c50_warmup_20261015093000.csv"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := exportFilename("trace_slowest", PgBouncerSession, "", now, ".json"),
+		"trace_slowest_pgbouncer-session_20261015093000.json"; got != want {
+		t.Errorf("without detail got %q, want %q", got, want)
+	}
+
+	// Iterations of a level and their reruns don't overwrite each other
+	names := make(map[string]bool)
+	for _, detail := range []string{
+		runDetail(100, false, 1, ""),
+		runDetail(100, false, 2, ""),
+		runDetail(100, false, 2, "state"),
+		runDetail(100, true, 1, ""),
+	} {
+		names[exportFilename("query_records", PgBouncerSession, detail, now, ".ndjson")] = true
+	}
+	if len(names) != 4 {
+		t.Errorf("iterations and variants share file names: %v", names)
+	}
+	if got, want := runDetail(100, false, 2, "state"), "c100_actual_i2_state"; got != want {
+		t.Errorf("runDetail = %q, want %q", got, want)
+	}
+
+	// Every per-type file name differs between modes run in the same second
+	result := BenchmarkResult{Concurrency: 10}
+	seen := make(map[string]ConnectionType)
+	for _, connType := range []ConnectionType{DirectPostgres, PgBouncerSession, PgBouncerTransaction, PgBouncerSessionSQL, PgBouncerTransactionSQL} {
+		result.ConnectionType = connType
+		for _, name := range []string{
+			acquisitionCSVFilename(result),
+			queryRecordsFilename(connType, 10, false, 1, ""),
+			perTraceFilename(connType, TraceInfo{Duration: time.Millisecond}, now),
+		} {
+			if other, ok := seen[name]; ok {
+				t.Errorf("%s and %s both write %s", other, connType, name)
+			}
+			seen[name] = connType
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+// slogLevels maps pgx tracelog levels onto slog levels
+var slogLevels = map[tracelog.LogLevel]slog.Level{
+	tracelog.LogLevelTrace: slog.LevelDebug,
+	tracelog.LogLevelDebug: slog.LevelDebug,
+	tracelog.LogLevelInfo:  slog.LevelInfo,
+	tracelog.LogLevelWarn:  slog.LevelWarn,
+	tracelog.LogLevelError: slog.LevelError,
+}
+
+// newTraceLog returns a pgx tracer logging driver-level events at or above
+// level to logger, tagged with the connection type. Query end events carry
+// the driver-observed query duration under "time".
+func newTraceLog(logger *slog.Logger, connType ConnectionType, level tracelog.LogLevel) *tracelog.TraceLog {
+	logger = logger.With("conn_type", connType, "source", "pgx")
+	return &tracelog.TraceLog{
+		Logger: tracelog.LoggerFunc(func(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+			logger.Log(ctx, slogLevels[level], msg, traceLogAttrs(data)...)
+		}),
+		LogLevel: level,
+	}
+}
+
+// traceLogAttrs flattens tracelog data into slog key-value pairs in key order
+func traceLogAttrs(data map[string]any) []any {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]any, 0, 2*len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, key, data[key])
+	}
+	return attrs
+}
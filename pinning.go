@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Session-state worker variants for -session-state. Each worker sets the state
+// right after acquiring a connection and checks it is still there before
+// releasing, which only holds when the connection stays on one backend.
+const (
+	SessionStateSet          = "set"           // SET a custom setting to the worker's id
+	SessionStateAdvisoryLock = "advisory-lock" // Take a session-level advisory lock keyed by the worker
+)
+
+// Statements of the session-state variants. set_config(..., false) is a plain
+// session-level SET that takes its value as a parameter.
+const (
+	sessionSetSQL          = "SELECT set_config('pgx_benchmark.worker', $1, false)"
+	sessionShowSQL         = "SELECT coalesce(current_setting('pgx_benchmark.worker', true), '')"
+	sessionTryLockSQL      = "SELECT pg_try_advisory_lock($1)"
+	sessionUnlockSQL       = "SELECT pg_advisory_unlock($1)"
+	pinningLockNamespace   = int64(0x70676278) // "pgbx", the high half of every advisory lock key
+	DefaultPinningDrop     = 0.25              // Throughput lost to session state that counts as pinning
+	DefaultPinningCoverage = 0.10              // How close to session mode a pooled mode must fall to count as pinned
+)
+
+// SessionStateChecks counts how often a worker found its session state intact
+// on release. It is safe for concurrent use; a nil value ignores additions.
+type SessionStateChecks struct {
+	kept atomic.Int64
+	lost atomic.Int64
+}
+
+func (c *SessionStateChecks) add(kept bool) {
+	if c == nil {
+		return
+	}
+	if kept {
+		c.kept.Add(1)
+	} else {
+		c.lost.Add(1)
+	}
+}
+
+// Kept returns the number of connections whose session state survived their queries
+func (c *SessionStateChecks) Kept() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.kept.Load()
+}
+
+// Lost returns the number of connections whose session state was gone or
+// belonged to another worker, because the pooler moved them between backends
+func (c *SessionStateChecks) Lost() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.lost.Load()
+}
+
+// advisoryLockKey is the advisory lock a worker takes, unique per worker
+func advisoryLockKey(workerID int) int64 {
+	return pinningLockNamespace<<32 | int64(uint32(workerID))
+}
+
+// applySessionState establishes cfg.SessionState on conn for workerID. It
+// returns whether the state was established: a try-lock held by another
+// backend (a lock leaked by an earlier unpinned connection) reports false.
+func applySessionState(ctx context.Context, conn PooledConn, workerID int, cfg WorkerConfig) (bool, error) {
+	_, span := cfg.Tracer.Start(ctx, "db.session_state.apply")
+	defer span.End()
+
+	switch cfg.SessionState {
+	case SessionStateSet:
+		_, err := queryOne[string](ctx, conn, sessionSetSQL, strconv.Itoa(workerID))
+		return err == nil, err
+	case SessionStateAdvisoryLock:
+		return queryOne[bool](ctx, conn, sessionTryLockSQL, advisoryLockKey(workerID))
+	}
+	return false, fmt.Errorf("unknown session state %q", cfg.SessionState)
+}
+
+// verifySessionState reports whether the state applySessionState established
+// for workerID is still visible on conn. The advisory-lock variant releases
+// the lock as it checks; under transaction pooling the release lands on
+// whichever backend serves it, so the lock stays held on the original one
+// until PgBouncer closes that server connection.
+func verifySessionState(ctx context.Context, conn PooledConn, workerID int, cfg WorkerConfig) (bool, error) {
+	_, span := cfg.Tracer.Start(ctx, "db.session_state.verify")
+	defer span.End()
+
+	switch cfg.SessionState {
+	case SessionStateSet:
+		value, err := queryOne[string](ctx, conn, sessionShowSQL)
+		return value == strconv.Itoa(workerID), err
+	case SessionStateAdvisoryLock:
+		return queryOne[bool](ctx, conn, sessionUnlockSQL, advisoryLockKey(workerID))
+	}
+	return false, fmt.Errorf("unknown session state %q", cfg.SessionState)
+}
+
+// queryOne runs a single-value query on conn and returns the value of its last row
+func queryOne[T any](ctx context.Context, conn PooledConn, sql string, args ...any) (T, error) {
+	var value T
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return value, err
+	}
+	defer rows.Close()
+
+	_, err = drainRows(rows, func(rows pgx.Rows) error { return rows.Scan(&value) })
+	return value, err
+}
+
+// effectiveConcurrency is how many connections were busy on average during the
+// run, by Little's law: throughput times the time each query held a connection
+func effectiveConcurrency(r BenchmarkResult) float64 {
+	return r.QueriesPerSecond * r.AvgAcquisitionTime.Seconds()
+}
+
+// PinningSample is what the pinning check compares between the plain run and
+// the session-state run
+type PinningSample struct {
+	QPS                  float64
+	EffectiveConcurrency float64
+	Backends             int
+}
+
+func pinningSample(r BenchmarkResult) PinningSample {
+	return PinningSample{
+		QPS:                  r.QueriesPerSecond,
+		EffectiveConcurrency: effectiveConcurrency(r),
+		Backends:             r.DistinctBackendPIDs,
+	}
+}
+
+// PinningCheck compares a measured run with a rerun of the same workload where
+// every worker also held session state (-session-state)
+type PinningCheck struct {
+	Mode      string
+	Plain     PinningSample
+	Pinned    PinningSample
+	StateKept int64
+	StateLost int64
+}
+
+// newPinningCheck compares plain with the session-state run pinned
+func newPinningCheck(plain, pinned BenchmarkResult) PinningCheck {
+	return PinningCheck{
+		Mode:      pinned.SessionState,
+		Plain:     pinningSample(plain),
+		Pinned:    pinningSample(pinned),
+		StateKept: pinned.SessionStateKept,
+		StateLost: pinned.SessionStateLost,
+	}
+}
+
+// ThroughputDrop is the fraction of the plain run's QPS the session state cost
+func (c PinningCheck) ThroughputDrop() float64 {
+	if c.Plain.QPS <= 0 {
+		return 0
+	}
+	return 1 - c.Pinned.QPS/c.Plain.QPS
+}
+
+// Detected reports whether session state pinned connections: throughput fell
+// by at least DefaultPinningDrop, or a mode that outran session mode (at
+// sessionQPS) fell back to within DefaultPinningCoverage of it. Pass 0 for
+// session mode itself or when no session-mode run is available.
+func (c PinningCheck) Detected(sessionQPS float64) bool {
+	if c.ThroughputDrop() >= DefaultPinningDrop {
+		return true
+	}
+	ceiling := sessionQPS * (1 + DefaultPinningCoverage)
+	return sessionQPS > 0 && c.Plain.QPS > ceiling && c.Pinned.QPS <= ceiling
+}
+
+// renderPinningChecks compares each pinning check with its plain run and ends
+// with a pinning verdict per connection type. Returns "" without checks.
+func renderPinningChecks(results []BenchmarkResult) string {
+	// Session mode's plain QPS per level is the reference pooled modes fall back
+	// to, compared within the same pooling library
+	type levelKey struct {
+		connType    ConnectionType
+		concurrency int
+	}
+	sessionQPS := make(map[levelKey]float64)
+	for _, r := range results {
+		if r.Pinning != nil && r.ConnectionType == r.ConnectionType.sessionMode() {
+			sessionQPS[levelKey{r.ConnectionType, r.Concurrency}] = r.Pinning.Plain.QPS
+		}
+	}
+
+	var checked []BenchmarkResult
+	for _, r := range results {
+		if r.Pinning != nil {
+			checked = append(checked, r)
+		}
+	}
+	if len(checked) == 0 {
+		return ""
+	}
+	sort.SliceStable(checked, func(i, j int) bool { return checked[i].Concurrency < checked[j].Concurrency })
+
+	var sb strings.Builder
+	detected := make(map[ConnectionType]bool)
+	lost := make(map[ConnectionType]int64)
+	for _, r := range checked {
+		c := r.Pinning
+		reference := 0.0
+		if session := r.ConnectionType.sessionMode(); r.ConnectionType != session {
+			reference = sessionQPS[levelKey{session, r.Concurrency}]
+		}
+		pinned := c.Detected(reference)
+		detected[r.ConnectionType] = detected[r.ConnectionType] || pinned
+		lost[r.ConnectionType] += c.StateLost
+
+		sb.WriteString(fmt.Sprintf("%s, concurrency %d (%s):\n", r.ConnectionType, r.Concurrency, c.Mode))
+		sb.WriteString(fmt.Sprintf("  QPS:                   %.2f → %.2f (%+.1f%%)\n", c.Plain.QPS, c.Pinned.QPS, -c.ThroughputDrop()*100))
+		sb.WriteString(fmt.Sprintf("  Effective Concurrency: %.1f → %.1f\n", c.Plain.EffectiveConcurrency, c.Pinned.EffectiveConcurrency))
+		sb.WriteString(fmt.Sprintf("  Backend PIDs:          %d → %d\n", c.Plain.Backends, c.Pinned.Backends))
+		if reference > 0 {
+			sb.WriteString(fmt.Sprintf("  vs Session Mode QPS:   %.2f\n", reference))
+		}
+		sb.WriteString(fmt.Sprintf("  Session State Lost:    %d of %d connections\n", c.StateLost, c.StateKept+c.StateLost))
+		verdict := "no"
+		if pinned {
+			verdict = "PINNING DETECTED"
+		}
+		sb.WriteString(fmt.Sprintf("  Pinning:               %s\n\n", verdict))
+	}
+
+	for _, connType := range reportTypeOrder {
+		found, ok := detected[connType]
+		if !ok {
+			continue
+		}
+		verdict := "not detected"
+		if found {
+			verdict = "pinning detected"
+		} else if lost[connType] > 0 {
+			// Unpinned yet stateful: the pooler handed the state to other clients
+			verdict = fmt.Sprintf("not detected; session state lost on %d connections", lost[connType])
+		}
+		sb.WriteString(fmt.Sprintf("  %-22s %s\n", connType, verdict))
+	}
+	return sb.String()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// statefulPooler hands out connections to fake backends that keep session
+// state. Each connection stays on one backend unless rotate is set, which
+// moves every statement to the next backend as transaction pooling may.
+type statefulPooler struct {
+	rotate bool
+
+	mu       sync.Mutex
+	settings []string
+	locks    map[int64]int // Advisory lock key to the backend holding it
+	next     int
+}
+
+func newStatefulPooler(backends int, rotate bool) *statefulPooler {
+	return &statefulPooler{rotate: rotate, settings: make([]string, backends), locks: make(map[int64]int)}
+}
+
+func (p *statefulPooler) Acquire(ctx context.Context) (PooledConn, error) {
+	return &statefulConn{pool: p, backend: p.nextBackend()}, nil
+}
+
+func (p *statefulPooler) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return p.run(p.nextBackend(), sql, args)
+}
+
+func (p *statefulPooler) Stat() *pgxpool.Stat { return nil }
+func (p *statefulPooler) Close()              {}
+
+func (p *statefulPooler) nextBackend() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	backend := p.next
+	p.next = (p.next + 1) % len(p.settings)
+	return backend
+}
+
+// run answers sql as backend would
+func (p *statefulPooler) run(backend int, sql string, args []any) (pgx.Rows, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch sql {
+	case sessionSetSQL:
+		p.settings[backend] = args[0].(string)
+		return &fakeRows{values: [][]any{{args[0]}}}, nil
+	case sessionShowSQL:
+		return &fakeRows{values: [][]any{{p.settings[backend]}}}, nil
+	case sessionTryLockSQL:
+		holder, held := p.locks[args[0].(int64)]
+		if held && holder != backend {
+			return &fakeRows{values: [][]any{{false}}}, nil
+		}
+		p.locks[args[0].(int64)] = backend
+		return &fakeRows{values: [][]any{{true}}}, nil
+	case sessionUnlockSQL:
+		holder, held := p.locks[args[0].(int64)]
+		if !held || holder != backend {
+			return &fakeRows{values: [][]any{{false}}}, nil
+		}
+		delete(p.locks, args[0].(int64))
+		return &fakeRows{values: [][]any{{true}}}, nil
+	}
+	return &fakeRows{values: [][]any{{1, "Alice Johnson", uint32(100 + backend)}}}, nil
+}
+
+type statefulConn struct {
+	pool    *statefulPooler
+	backend int
+}
+
+func (c *statefulConn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	backend := c.backend
+	if c.pool.rotate {
+		backend = c.pool.nextBackend()
+	}
+	return c.pool.run(backend, sql, args)
+}
+
+func (c *statefulConn) Begin(ctx context.Context) (pgx.Tx, error)                    { return nil, nil }
+func (c *statefulConn) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults { return nil }
+func (c *statefulConn) Release()                                                     {}
+
+func TestSessionStateKeptOnPinnedConnections(t *testing.T) {
+	for _, mode := range []string{SessionStateSet, SessionStateAdvisoryLock} {
+		pool := newStatefulPooler(3, false)
+		checks := &SessionStateChecks{}
+		cfg := testWorkerConfig()
+		cfg.SessionState = mode
+		cfg.StateChecks = checks
+		cfg.QueriesPerConn = 3
+
+		for workerID := 0; workerID < 4; workerID++ {
+			if _, _, err := executeWorkerQuery(context.Background(), pool, workerID, 0, cfg); err != nil {
+				t.Fatalf("%s: %v", mode, err)
+			}
+		}
+		if checks.Kept() != 4 || checks.Lost() != 0 {
+			t.Errorf("%s: kept %d, lost %d; want 4 kept", mode, checks.Kept(), checks.Lost())
+		}
+		if len(pool.locks) != 0 {
+			t.Errorf("%s: %d advisory locks still held", mode, len(pool.locks))
+		}
+	}
+}
+
+func TestSessionStateLostWhenStatementsChangeBackend(t *testing.T) {
+	for _, mode := range []string{SessionStateSet, SessionStateAdvisoryLock} {
+		pool := newStatefulPooler(3, true)
+		checks := &SessionStateChecks{}
+		cfg := testWorkerConfig()
+		cfg.SessionState = mode
+		cfg.StateChecks = checks
+
+		for workerID := 0; workerID < 4; workerID++ {
+			if _, _, err := executeWorkerQuery(context.Background(), pool, workerID, 0, cfg); err != nil {
+				t.Fatalf("%s: %v", mode, err)
+			}
+		}
+		if checks.Kept() != 0 || checks.Lost() != 4 {
+			t.Errorf("%s: kept %d, lost %d; want 4 lost", mode, checks.Kept(), checks.Lost())
+		}
+	}
+}
+
+func TestAdvisoryLockLeakedOnAnotherBackendCountsAsLost(t *testing.T) {
+	pool := newStatefulPooler(2, false)
+	pool.locks[advisoryLockKey(7)] = 1
+
+	checks := &SessionStateChecks{}
+	cfg := testWorkerConfig()
+	cfg.SessionState = SessionStateAdvisoryLock
+	cfg.StateChecks = checks
+
+	// The connection lands on backend 0, which can't take the lock backend 1 holds
+	if _, _, err := executeWorkerQuery(context.Background(), pool, 7, 0, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if checks.Lost() != 1 {
+		t.Errorf("lost = %d, want 1", checks.Lost())
+	}
+}
+
+func TestSessionStateChecksNilSafe(t *testing.T) {
+	var checks *SessionStateChecks
+	checks.add(true)
+	if checks.Kept() != 0 || checks.Lost() != 0 {
+		t.Error("nil checks should count nothing")
+	}
+}
+
+func TestEffectiveConcurrencyLittlesLaw(t *testing.T) {
+	r := BenchmarkResult{QueriesPerSecond: 500, AvgAcquisitionTime: 20 * time.Millisecond}
+	if got := effectiveConcurrency(r); got != 10 {
+		t.Errorf("effectiveConcurrency = %v, want 10", got)
+	}
+}
+
+func TestPinningCheckDetected(t *testing.T) {
+	tests := []struct {
+		name       string
+		plain      float64
+		pinned     float64
+		sessionQPS float64
+		want       bool
+	}{
+		{"throughput holds", 1000, 950, 0, false},
+		{"throughput collapses", 1000, 600, 0, true},
+		{"falls back to session mode", 1000, 800, 760, true},
+		{"keeps its edge over session mode", 1000, 900, 600, false},
+		{"never outran session mode", 500, 450, 500, false},
+	}
+	for _, tt := range tests {
+		c := PinningCheck{Plain: PinningSample{QPS: tt.plain}, Pinned: PinningSample{QPS: tt.pinned}}
+		if got := c.Detected(tt.sessionQPS); got != tt.want {
+			t.Errorf("%s: Detected = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderPinningChecks(t *testing.T) {
+	if got := renderPinningChecks([]BenchmarkResult{{ConnectionType: PgBouncerSession, Concurrency: 10}}); got != "" {
+		t.Errorf("without checks got %q, want empty", got)
+	}
+
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerSession, Concurrency: 10, Pinning: &PinningCheck{
+			Mode: SessionStateSet, Plain: PinningSample{QPS: 500}, Pinned: PinningSample{QPS: 490}, StateKept: 100,
+		}},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 10, Pinning: &PinningCheck{
+			Mode: SessionStateSet, Plain: PinningSample{QPS: 1000}, Pinned: PinningSample{QPS: 520}, StateKept: 10, StateLost: 90,
+		}},
+		{ConnectionType: DirectPostgres, Concurrency: 10, Pinning: &PinningCheck{
+			Mode: SessionStateSet, Plain: PinningSample{QPS: 900}, Pinned: PinningSample{QPS: 880}, StateLost: 3,
+		}},
+	}
+	got := renderPinningChecks(results)
+	for _, want := range []string{
+		"pgbouncer-transaction, concurrency 10 (set):",
+		"vs Session Mode QPS:   500.00",
+		"Session State Lost:    90 of 100 connections",
+		"PINNING DETECTED",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+
+	verdicts := got[strings.LastIndex(got, "\n\n")+2:]
+	for _, want := range []string{
+		string(PgBouncerTransaction) + " ",
+		"pinning detected",
+		"not detected; session state lost on 3 connections",
+	} {
+		if !strings.Contains(verdicts, want) {
+			t.Errorf("verdicts missing %q:\n%s", want, verdicts)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Pooler is the subset of *pgxpool.Pool the benchmark uses, so the benchmarking
+// logic can run against a fake pool in tests. Stat may return nil for pools that
+// keep no statistics; pool stat sampling skips them.
+type Pooler interface {
+	Acquire(ctx context.Context) (PooledConn, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Stat() *pgxpool.Stat
+	Close()
+}
+
+// PooledConn is a connection checked out of a Pooler
+type PooledConn interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Release()
+}
+
+// pgxPooler adapts *pgxpool.Pool to Pooler
+type pgxPooler struct {
+	*pgxpool.Pool
+}
+
+// Acquire checks out a connection, returning a nil interface on error
+func (p pgxPooler) Acquire(ctx context.Context) (PooledConn, error) {
+	conn, err := p.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// fakePooler is a Pooler that hands out connections after a fixed latency and
+// answers every query with canned rows
+type fakePooler struct {
+	latency    time.Duration
+	acquireErr error
+	rows       [][]any
+
+	acquires atomic.Int64
+	releases atomic.Int64
+	queries  atomic.Int64
+}
+
+func newFakePooler(latency time.Duration) *fakePooler {
+	return &fakePooler{latency: latency, rows: [][]any{{1, "Alice Johnson", uint32(4242)}}}
+}
+
+func (p *fakePooler) Acquire(ctx context.Context) (PooledConn, error) {
+	p.acquires.Add(1)
+	select {
+	case <-time.After(p.latency):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if p.acquireErr != nil {
+		return nil, p.acquireErr
+	}
+	return &fakeConn{pool: p}, nil
+}
+
+func (p *fakePooler) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	p.queries.Add(1)
+	return &fakeRows{values: p.rows}, nil
+}
+
+func (p *fakePooler) Stat() *pgxpool.Stat { return nil }
+func (p *fakePooler) Close()              {}
+
+type fakeConn struct {
+	pool     *fakePooler
+	released bool
+}
+
+func (c *fakeConn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return c.pool.Query(ctx, sql, args...)
+}
+
+func (c *fakeConn) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &fakeBatchResults{conn: c, remaining: b.Len()}
+}
+
+func (c *fakeConn) Release() {
+	if !c.released {
+		c.released = true
+		c.pool.releases.Add(1)
+	}
+}
+
+func testWorkerConfig() WorkerConfig {
+	return WorkerConfig{
+		ConnType: DirectPostgres,
+		Tracer:   noop.NewTracerProvider().Tracer("test"),
+		Rand:     newWorkerRand(1, 0),
+	}
+}
+
+func TestExecuteWorkerQueryMeasuresAcquisition(t *testing.T) {
+	pool := newFakePooler(20 * time.Millisecond)
+
+	d, queryTimes, err := executeWorkerQuery(context.Background(), pool, 0, 0, testWorkerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queryTimes) != 1 {
+		t.Errorf("got %d query times, want 1", len(queryTimes))
+	}
+	if d < 20*time.Millisecond {
+		t.Errorf("duration %v should include the 20ms acquisition", d)
+	}
+	if pool.acquires.Load() != 1 || pool.releases.Load() != 1 {
+		t.Errorf("acquires=%d releases=%d, want 1 each", pool.acquires.Load(), pool.releases.Load())
+	}
+}
+
+func TestExecuteWorkerQueryRunsSeveralQueriesPerConn(t *testing.T) {
+	pool := newFakePooler(0)
+	cfg := testWorkerConfig()
+	cfg.QueriesPerConn = 3
+
+	d, queryTimes, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.acquires.Load() != 1 || pool.releases.Load() != 1 || pool.queries.Load() != 3 {
+		t.Errorf("acquires=%d releases=%d queries=%d, want 1, 1, 3",
+			pool.acquires.Load(), pool.releases.Load(), pool.queries.Load())
+	}
+	if len(queryTimes) != 3 {
+		t.Fatalf("got %d query times, want 3", len(queryTimes))
+	}
+	var sum time.Duration
+	for _, qt := range queryTimes {
+		sum += qt
+	}
+	if d < sum {
+		t.Errorf("total %v shorter than its queries' %v", d, sum)
+	}
+}
+
+func TestExecuteWorkerQueryAcquireTimeout(t *testing.T) {
+	pool := newFakePooler(time.Second)
+	cfg := testWorkerConfig()
+	cfg.AcquireTimeout = 10 * time.Millisecond
+
+	_, _, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg)
+	if got := errorCategory(err); got != ErrAcquireTimedOut {
+		t.Errorf("category = %s (err %v), want %s", got, err, ErrAcquireTimedOut)
+	}
+}
+
+func TestExecuteWorkerQueryScanFailure(t *testing.T) {
+	pool := newFakePooler(0)
+	pool.rows = [][]any{{1, "a"}, {2, "b"}}
+
+	// Rows drained through a scan that rejects the second row fail the query
+	cfg := testWorkerConfig()
+	_, _, err := executeWorkerQuery(context.Background(), &scanFailPooler{pool}, 0, 0, cfg)
+	if err == nil {
+		t.Fatal("expected a scan error")
+	}
+	if pool.releases.Load() != 1 {
+		t.Errorf("connection not released after a scan failure")
+	}
+}
+
+// scanFailPooler wraps a fakePooler so its rows fail to scan on the second row
+type scanFailPooler struct{ *fakePooler }
+
+func (p *scanFailPooler) Acquire(ctx context.Context) (PooledConn, error) {
+	conn, err := p.fakePooler.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &scanFailConn{conn.(*fakeConn)}, nil
+}
+
+type scanFailConn struct{ *fakeConn }
+
+func (c *scanFailConn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return &fakeRows{values: c.pool.rows, scanErr: errors.New("bad row"), failAt: 1}, nil
+}
+
+func TestRunBenchmarkWithFakePools(t *testing.T) {
+	healthy := newFakePooler(time.Millisecond)
+	failing := newFakePooler(time.Millisecond)
+	failing.acquireErr = errors.New("connection refused")
+	other := newFakePooler(time.Millisecond)
+	other.rows = [][]any{{2, "Bob Smith", uint32(4343)}}
+	pools := []Pooler{healthy, failing, other}
+
+	result := runBenchmark(Config{ConnType: DirectPostgres}, pools, nil, 9, false, nil, Options{Seed: 1})
+
+	if result.TotalQueries != 9 || result.PoolInstances != 3 {
+		t.Fatalf("TotalQueries=%d PoolInstances=%d, want 9 and 3", result.TotalQueries, result.PoolInstances)
+	}
+	if got := result.ErrorCategories[ErrConnection]; got != 3 {
+		t.Errorf("connection errors = %d, want 3 (every worker on the failing pool): %v", got, result.ErrorCategories)
+	}
+	if result.PerPool[1].Failures != 3 || result.PerPool[0].Failures != 0 || result.PerPool[2].Failures != 0 {
+		t.Errorf("failures not attributed to pool 1: %+v", result.PerPool)
+	}
+	if result.DistinctBackendPIDs != 2 {
+		t.Errorf("DistinctBackendPIDs = %d, want 2 (one per healthy pool)", result.DistinctBackendPIDs)
+	}
+	if result.MinAcquisitionTime < time.Millisecond {
+		t.Errorf("min acquisition %v should include the fake 1ms latency", result.MinAcquisitionTime)
+	}
+}
+
+func TestPollUntil(t *testing.T) {
+	calls := 0
+	if !pollUntil(context.Background(), func() bool { calls++; return calls == 3 }, time.Second, time.Millisecond) {
+		t.Fatal("pollUntil gave up before the condition held")
+	}
+	if calls != 3 {
+		t.Errorf("condition checked %d times, want 3", calls)
+	}
+	if pollUntil(context.Background(), func() bool { return false }, 5*time.Millisecond, time.Millisecond) {
+		t.Error("pollUntil reported success for a condition that never held")
+	}
+}
+
+func TestPollUntilStopsOnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if pollUntil(ctx, func() bool { return false }, time.Hour, 10*time.Millisecond) {
+		t.Error("pollUntil reported success for a condition that never held")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("pollUntil kept polling after cancellation")
+	}
+}
+
+func TestWorkerQueryRowsPerQuery(t *testing.T) {
+	cfg := testWorkerConfig()
+	if sql, args := workerQuery(cfg); sql != WorkerQuery || len(args) != 1 {
+		t.Errorf("single-row query = %q %v, want WorkerQuery with one argument", sql, args)
+	}
+
+	cfg.RowsPerQuery = 30
+	for i := 0; i < 100; i++ {
+		sql, args := workerQuery(cfg)
+		if sql != WorkerRangeQuery || len(args) != 2 || args[1] != 30 {
+			t.Fatalf("range query = %q %v, want WorkerRangeQuery limited to 30 rows", sql, args)
+		}
+		if start := args[0].(int); start < 1 || start+30-1 > NumBenchmarkRows {
+			t.Fatalf("range starts at %d, so 30 rows don't fit in %d", start, NumBenchmarkRows)
+		}
+	}
+}
+
+func TestExecuteWorkerQueryRecordsStreamTimes(t *testing.T) {
+	pool := newFakePooler(0)
+	pool.rows = [][]any{{1, "a", uint32(1)}, {2, "b", uint32(1)}, {3, "c", uint32(1)}}
+	cfg := testWorkerConfig()
+	cfg.RowsPerQuery = 3
+	cfg.QueriesPerConn = 2
+	cfg.StreamTimes = &StreamTimes{}
+
+	if _, _, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(cfg.StreamTimes.Times()); got != 2 {
+		t.Errorf("recorded %d streaming times, want one per query (2)", got)
+	}
+}
+
+func TestExecuteWorkerQueryRecordsReleaseTimes(t *testing.T) {
+	pool := newFakePooler(0)
+	cfg := testWorkerConfig()
+	cfg.QueriesPerConn = 3
+	cfg.ReleaseTimes = &ReleaseTimes{}
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(cfg.ReleaseTimes.Times()); got != 2 {
+		t.Errorf("recorded %d release times, want one per acquired connection (2)", got)
+	}
+
+	// A failed query never reaches the timed release
+	pool.acquireErr = errors.New("pool closed")
+	if _, _, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg); err == nil {
+		t.Fatal("expected an acquire error")
+	}
+	if got := len(cfg.ReleaseTimes.Times()); got != 2 {
+		t.Errorf("recorded %d release times after a failed acquire, want 2", got)
+	}
+
+	var nilTimes *ReleaseTimes
+	nilTimes.Add(time.Millisecond)
+	if nilTimes.Times() != nil {
+		t.Error("nil recorder should record nothing")
+	}
+}
+
+func TestPoolLeaks(t *testing.T) {
+	leaks := poolLeaks([]int32{0, 2, 0}, []int32{2, 5, 2})
+	if len(leaks) != 1 || leaks[0] != (PoolLeak{PoolIndex: 1, Acquired: 2, Total: 5}) {
+		t.Errorf("poolLeaks = %+v, want pool 1 with 2 of 5 acquired", leaks)
+	}
+	if leaks := poolLeaks([]int32{0, 0}, []int32{2, 2}); len(leaks) != 0 {
+		t.Errorf("poolLeaks = %+v, want none", leaks)
+	}
+}
+
+func TestShutdownReportsLeakWithoutBlocking(t *testing.T) {
+	server := startFakePGServer(t, 0)
+	pools, err := NewPoolSet(Config{ConnType: PgBouncerSession, DSN: server.DSN(), PoolInstances: 1, MaxConns: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := pools.Poolers()[0].Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing a pgxpool blocks until the held connection is released, so
+	// Shutdown has to report the leak and give up waiting rather than hang
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+	start := time.Now()
+	leaks := pools.Shutdown(log, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown took %v with a connection held", elapsed)
+	}
+	if len(leaks) != 1 || leaks[0].Acquired != 1 {
+		t.Errorf("leaks = %+v, want pool 0 with 1 acquired", leaks)
+	}
+	if !strings.Contains(buf.String(), "Pool closed with connections still acquired") {
+		t.Errorf("leak not logged:\n%s", buf.String())
+	}
+	conn.Release()
+}
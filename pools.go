@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+// PoolSet owns the pool instances used for one connection configuration.
+// Each pool simulates a separate Go server instance with its own pool.
+type PoolSet struct {
+	pools     []*pgxpool.Pool
+	dbs       []*sql.DB // Instead of pools for connection types that use database/sql
+	connects  *ConnectTimer
+	closeOnce sync.Once
+}
+
+// NewPoolSet creates n pools for the given configuration
+func NewPoolSet(config Config, n int) (*PoolSet, error) {
+	ctx := context.Background()
+	ps := &PoolSet{pools: make([]*pgxpool.Pool, 0, n), connects: &ConnectTimer{}}
+
+	for i := 0; i < n; i++ {
+		if config.ConnType.usesDatabaseSQL() {
+			db, err := newSQLDB(config.forPool(i), ps.connects)
+			if err != nil {
+				ps.Close()
+				return nil, fmt.Errorf("unable to parse config for pool %d: %w", i, err)
+			}
+			ps.dbs = append(ps.dbs, db)
+			continue
+		}
+
+		poolConfig, err := newPoolConfig(config.forPool(i))
+		if err != nil {
+			ps.Close()
+			return nil, fmt.Errorf("unable to parse config for pool %d: %w", i, err)
+		}
+		if poolConfig.ConnConfig.Tracer != nil {
+			poolConfig.ConnConfig.Tracer = multitracer.New(ps.connects, poolConfig.ConnConfig.Tracer)
+		} else {
+			poolConfig.ConnConfig.Tracer = ps.connects
+		}
+
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			ps.Close()
+			return nil, fmt.Errorf("unable to create connection pool %d: %w", i, err)
+		}
+		ps.pools = append(ps.pools, pool)
+	}
+
+	return ps, nil
+}
+
+// PoolTuning holds the pool lifecycle settings under study
+type PoolTuning struct {
+	MaxConnLifetime       time.Duration
+	MaxConnLifetimeJitter time.Duration
+	MaxConnIdleTime       time.Duration
+	HealthCheckPeriod     time.Duration
+}
+
+// DefaultPoolTuning returns the tuning from the pool configuration constants
+func DefaultPoolTuning() PoolTuning {
+	return PoolTuning{
+		MaxConnLifetime:       DefaultMaxConnLifetime,
+		MaxConnLifetimeJitter: DefaultMaxConnLifetimeJitter,
+		MaxConnIdleTime:       DefaultMaxConnIdleTime,
+		HealthCheckPeriod:     DefaultHealthCheckPeriod,
+	}
+}
+
+func (t PoolTuning) String() string {
+	return fmt.Sprintf("MaxConnLifetime=%v, MaxConnLifetimeJitter=%v, MaxConnIdleTime=%v, HealthCheckPeriod=%v",
+		t.MaxConnLifetime, t.MaxConnLifetimeJitter, t.MaxConnIdleTime, t.HealthCheckPeriod)
+}
+
+// newPoolConfig parses the DSN and applies the pool configuration constants and tuning.
+// A zero Tuning uses DefaultPoolTuning.
+func newPoolConfig(config Config) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.MaxConns = config.maxConns()
+	poolConfig.MinConns = min(int32(DefaultMinConnections), poolConfig.MaxConns)
+
+	tuning := config.Tuning
+	if tuning == (PoolTuning{}) {
+		tuning = DefaultPoolTuning()
+	}
+	poolConfig.MaxConnLifetime = tuning.MaxConnLifetime
+	poolConfig.MaxConnLifetimeJitter = tuning.MaxConnLifetimeJitter
+	poolConfig.MaxConnIdleTime = tuning.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = tuning.HealthCheckPeriod
+	if config.ExecMode != 0 {
+		poolConfig.ConnConfig.DefaultQueryExecMode = config.ExecMode
+	}
+	if config.PgxTraceLevel > tracelog.LogLevelNone {
+		poolConfig.ConnConfig.Tracer = newTraceLog(slog.Default(), config.ConnType, config.PgxTraceLevel)
+	}
+	if err := applyTLS(&poolConfig.ConnConfig.Config, config.TLS); err != nil {
+		return nil, err
+	}
+
+	return poolConfig, nil
+}
+
+// Len returns the number of pool instances
+func (ps *PoolSet) Len() int {
+	return len(ps.pools) + len(ps.dbs)
+}
+
+// Pool returns the pool instance at index i
+func (ps *PoolSet) Pool(i int) *pgxpool.Pool {
+	return ps.pools[i]
+}
+
+// ConnectTimer returns the timer shared by every pool instance to time new connections
+func (ps *PoolSet) ConnectTimer() *ConnectTimer {
+	return ps.connects
+}
+
+// Poolers returns every pool instance as a Pooler
+func (ps *PoolSet) Poolers() []Pooler {
+	poolers := make([]Pooler, 0, ps.Len())
+	for _, pool := range ps.pools {
+		poolers = append(poolers, pgxPooler{pool})
+	}
+	for _, db := range ps.dbs {
+		poolers = append(poolers, sqlPooler{db})
+	}
+	return poolers
+}
+
+// Prime establishes MinConns connections on each pool by holding that many
+// connections at once and running a trivial query on each
+func (ps *PoolSet) Prime() {
+	ctx := context.Background()
+
+	for i, pool := range ps.pools {
+		minConns := int(pool.Config().MinConns)
+		conns := make([]*pgxpool.Conn, 0, minConns)
+
+		for j := 0; j < minConns; j++ {
+			conn, err := pool.Acquire(ctx)
+			if err != nil {
+				slog.Warn("Priming failed to acquire connection", "pool_index", i, "conn", j, "error", err)
+				break
+			}
+			if _, err := conn.Exec(ctx, "SELECT 1"); err != nil {
+				slog.Warn("Priming query failed", "pool_index", i, "error", err)
+			}
+			conns = append(conns, conn)
+		}
+
+		// Hand the established connections back to the pool as idle
+		for _, conn := range conns {
+			conn.Release()
+		}
+	}
+
+	// database/sql has no MinConns; it keeps whatever it opened idle
+	for i, db := range ps.dbs {
+		conns := make([]*sql.Conn, 0, DefaultMinConnections)
+		for j := 0; j < DefaultMinConnections; j++ {
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				slog.Warn("Priming failed to acquire connection", "pool_index", i, "conn", j, "error", err)
+				break
+			}
+			if err := conn.PingContext(ctx); err != nil {
+				slog.Warn("Priming query failed", "pool_index", i, "error", err)
+			}
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
+
+// poolReadyPollInterval is how often WaitReady rechecks the pools' connection counts
+const poolReadyPollInterval = 10 * time.Millisecond
+
+// WaitReady waits until every pool holds at least MinConns connections, giving
+// up after timeout. It returns how long that took and whether all pools got there.
+func (ps *PoolSet) WaitReady(timeout time.Duration) (time.Duration, bool) {
+	start := time.Now()
+	ready := pollUntil(context.Background(), func() bool {
+		for _, pool := range ps.pools {
+			if !poolReady(pool) {
+				return false
+			}
+		}
+		for _, db := range ps.dbs {
+			if db.Stats().OpenConnections < min(DefaultMinConnections, db.Stats().MaxOpenConnections) {
+				return false
+			}
+		}
+		return true
+	}, timeout, poolReadyPollInterval)
+	return time.Since(start), ready
+}
+
+// waitPoolReady is WaitReady for a single pool, cut short when ctx is done
+func waitPoolReady(ctx context.Context, pool *pgxpool.Pool, timeout time.Duration) (time.Duration, bool) {
+	start := time.Now()
+	ready := pollUntil(ctx, func() bool { return poolReady(pool) }, timeout, poolReadyPollInterval)
+	return time.Since(start), ready
+}
+
+// poolReady reports whether pool has established its MinConns connections
+func poolReady(pool *pgxpool.Pool) bool {
+	return pool.Stat().TotalConns() >= pool.Config().MinConns
+}
+
+// pollUntil checks ready every interval until it returns true, timeout passes or
+// ctx is done, and reports whether it returned true
+func pollUntil(ctx context.Context, ready func() bool, timeout, interval time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if ready() {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// PoolLeak is a pool instance that still had connections acquired at shutdown
+type PoolLeak struct {
+	PoolIndex int
+	Acquired  int32
+	Total     int32
+}
+
+// Shutdown closes every pool like Close, after logging each pool's final
+// statistics and, to log, any pool that still has connections acquired, which
+// means some worker never released its connection. Closing a pgxpool waits for
+// its acquired connections, so with any left it waits at most wait for the pools
+// to close and leaves the rest of the close running. It returns the leaks.
+func (ps *PoolSet) Shutdown(log *slog.Logger, wait time.Duration) []PoolLeak {
+	acquired := make([]int32, 0, ps.Len())
+	total := make([]int32, 0, ps.Len())
+	for i, pool := range ps.pools {
+		stat := pool.Stat()
+		acquired, total = append(acquired, stat.AcquiredConns()), append(total, stat.TotalConns())
+		log.Debug("Pool final stats", "pool_index", i, "acquired", stat.AcquiredConns(), "idle", stat.IdleConns(),
+			"total", stat.TotalConns(), "acquire_count", stat.AcquireCount(), "canceled_acquires", stat.CanceledAcquireCount())
+	}
+	for i, db := range ps.dbs {
+		stat := db.Stats()
+		acquired, total = append(acquired, int32(stat.InUse)), append(total, int32(stat.OpenConnections))
+		log.Debug("Pool final stats", "pool_index", i, "acquired", stat.InUse, "idle", stat.Idle,
+			"total", stat.OpenConnections, "wait_count", stat.WaitCount, "wait_duration", stat.WaitDuration)
+	}
+
+	leaks := poolLeaks(acquired, total)
+	if len(leaks) == 0 {
+		ps.Close()
+		return nil
+	}
+	for _, leak := range leaks {
+		log.Warn("Pool closed with connections still acquired",
+			"pool_index", leak.PoolIndex, "acquired", leak.Acquired, "total", leak.Total)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		ps.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(wait):
+		log.Warn("Gave up waiting for pools to close", "wait", wait)
+	}
+	return leaks
+}
+
+// poolLeaks lists the pools with acquired connections left, given each pool's
+// acquired and total connection counts
+func poolLeaks(acquired, total []int32) []PoolLeak {
+	var leaks []PoolLeak
+	for i, n := range acquired {
+		if n > 0 {
+			leaks = append(leaks, PoolLeak{PoolIndex: i, Acquired: n, Total: total[i]})
+		}
+	}
+	return leaks
+}
+
+// Close closes every pool instance. It is safe to call more than once.
+func (ps *PoolSet) Close() {
+	ps.closeOnce.Do(func() {
+		for _, pool := range ps.pools {
+			pool.Close()
+		}
+		for _, db := range ps.dbs {
+			db.Close()
+		}
+	})
+}
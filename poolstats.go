@@ -0,0 +1,134 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// PoolStatSample is a point-in-time snapshot of one pool instance's statistics
+type PoolStatSample struct {
+	Time              time.Time
+	PoolIndex         int
+	AcquiredConns     int32
+	IdleConns         int32
+	TotalConns        int32
+	EmptyAcquireCount int64
+	AcquireDuration   time.Duration
+}
+
+// PoolStatSampler polls Stat() for every pool instance at a fixed interval
+type PoolStatSampler struct {
+	pools    []Pooler
+	interval time.Duration
+
+	mu      sync.Mutex
+	samples []PoolStatSample
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartPoolStatSampler takes an initial sample and keeps sampling in the background until Stop is called
+func StartPoolStatSampler(pools []Pooler, interval time.Duration) *PoolStatSampler {
+	s := &PoolStatSampler{
+		pools:    pools,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	s.sample()
+	go s.run()
+
+	return s
+}
+
+func (s *PoolStatSampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sample records the current statistics of every pool instance
+func (s *PoolStatSampler) sample() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, pool := range s.pools {
+		stat := pool.Stat()
+		if stat == nil {
+			continue
+		}
+		s.samples = append(s.samples, PoolStatSample{
+			Time:              now,
+			PoolIndex:         i,
+			AcquiredConns:     stat.AcquiredConns(),
+			IdleConns:         stat.IdleConns(),
+			TotalConns:        stat.TotalConns(),
+			EmptyAcquireCount: stat.EmptyAcquireCount(),
+			AcquireDuration:   stat.AcquireDuration(),
+		})
+	}
+}
+
+// Stop stops sampling, takes a final sample and returns the recorded time series
+func (s *PoolStatSampler) Stop() []PoolStatSample {
+	close(s.stop)
+	<-s.done
+	s.sample()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples
+}
+
+// PoolStatSummary aggregates a pool statistics time series across all pool instances
+type PoolStatSummary struct {
+	PeakAcquiredConns int32         // Most connections acquired at once across all pools
+	EmptyAcquireWaits int64         // Acquires that had to wait because no connection was idle
+	AcquireDuration   time.Duration // Total time spent in successful acquires
+}
+
+// summarizePoolStats aggregates the time series across pools. The pools'
+// EmptyAcquireCount and AcquireDuration are cumulative over their lifetime,
+// so only the change between the first and last sample of each pool counts.
+func summarizePoolStats(samples []PoolStatSample) PoolStatSummary {
+	var summary PoolStatSummary
+
+	// Sum acquired connections across pools for each sampling instant
+	acquiredAt := make(map[time.Time]int32)
+	first := make(map[int]PoolStatSample)
+	last := make(map[int]PoolStatSample)
+
+	for _, sample := range samples {
+		acquiredAt[sample.Time] += sample.AcquiredConns
+
+		if _, ok := first[sample.PoolIndex]; !ok {
+			first[sample.PoolIndex] = sample
+		}
+		last[sample.PoolIndex] = sample
+	}
+
+	for _, acquired := range acquiredAt {
+		summary.PeakAcquiredConns = max(summary.PeakAcquiredConns, acquired)
+	}
+	for poolIndex, end := range last {
+		start := first[poolIndex]
+		summary.EmptyAcquireWaits += end.EmptyAcquireCount - start.EmptyAcquireCount
+		summary.AcquireDuration += end.AcquireDuration - start.AcquireDuration
+	}
+
+	return summary
+}
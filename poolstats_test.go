@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizePoolStats(t *testing.T) {
+	t0 := time.Now()
+	t1 := t0.Add(100 * time.Millisecond)
+
+	// Two pools whose cumulative counters already had values before the run
+	samples := []PoolStatSample{
+		{Time: t0, PoolIndex: 0, AcquiredConns: 1, EmptyAcquireCount: 5, AcquireDuration: time.Second},
+		{Time: t0, PoolIndex: 1, AcquiredConns: 0, EmptyAcquireCount: 2, AcquireDuration: time.Second},
+		{Time: t1, PoolIndex: 0, AcquiredConns: 10, EmptyAcquireCount: 25, AcquireDuration: 3 * time.Second},
+		{Time: t1, PoolIndex: 1, AcquiredConns: 7, EmptyAcquireCount: 4, AcquireDuration: 2 * time.Second},
+	}
+
+	summary := summarizePoolStats(samples)
+
+	if summary.PeakAcquiredConns != 17 {
+		t.Errorf("Expected peak of 17 acquired conns, got %d", summary.PeakAcquiredConns)
+	}
+	if summary.EmptyAcquireWaits != 22 {
+		t.Errorf("Expected 22 empty acquire waits, got %d", summary.EmptyAcquireWaits)
+	}
+	if summary.AcquireDuration != 3*time.Second {
+		t.Errorf("Expected 3s acquire duration, got %v", summary.AcquireDuration)
+	}
+}
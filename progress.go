@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultProgressInterval is how often the progress line is printed during a run
+const DefaultProgressInterval = time.Second
+
+// ProgressReporter prints a periodic line with the number of completed queries
+// and the QPS since the previous line. Workers call Done once per query; a nil
+// reporter does nothing, which is how -quiet disables it.
+type ProgressReporter struct {
+	completed atomic.Int64
+	label     string        // Connection type, to tell runs apart with -parallel
+	total     int           // Expected queries, 0 when unknown (sustained load)
+	duration  time.Duration // Planned run length with sustained load, else 0
+	start     time.Time
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+// StartProgress starts printing progress to w every interval until Stop
+func StartProgress(w io.Writer, label string, total int, duration, interval time.Duration) *ProgressReporter {
+	p := &ProgressReporter{
+		label:    label,
+		total:    total,
+		duration: duration,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go p.run(w, interval)
+	return p
+}
+
+func (p *ProgressReporter) run(w io.Writer, interval time.Duration) {
+	defer close(p.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last int64
+	lastTick := p.start
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			completed := p.completed.Load()
+			qps := float64(completed-last) / now.Sub(lastTick).Seconds()
+			fmt.Fprintln(w, formatProgress(p.label, completed, p.total, now.Sub(p.start), p.duration, qps))
+			last, lastTick = completed, now
+		}
+	}
+}
+
+// Done records one completed query, successful or not
+func (p *ProgressReporter) Done() {
+	if p == nil {
+		return
+	}
+	p.completed.Add(1)
+}
+
+// Stop stops printing and waits for the reporter to finish
+func (p *ProgressReporter) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+}
+
+// formatProgress renders one progress line: completed out of total queries when
+// the total is known, otherwise elapsed out of the planned duration
+func formatProgress(label string, completed int64, total int, elapsed, duration time.Duration, qps float64) string {
+	elapsed = elapsed.Round(time.Second)
+	switch {
+	case total > 0:
+		return fmt.Sprintf("   … %s: %d/%d queries (%.0f%%), %.1f QPS, %v elapsed",
+			label, completed, total, float64(completed)*100/float64(total), qps, elapsed)
+	case duration > 0:
+		return fmt.Sprintf("   … %s: %d queries, %.1f QPS, %v/%v", label, completed, qps, elapsed, duration)
+	default:
+		return fmt.Sprintf("   … %s: %d queries, %.1f QPS, %v elapsed", label, completed, qps, elapsed)
+	}
+}
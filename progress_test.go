@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatProgress(t *testing.T) {
+	got := formatProgress("pgbouncer-session", 250, 1000, 2*time.Second, 0, 125)
+	want := "   … pgbouncer-session: 250/1000 queries (25%), 125.0 QPS, 2s elapsed"
+	if got != want {
+		t.Errorf("formatProgress = %q, want %q", got, want)
+	}
+
+	got = formatProgress("direct-postgres", 900, 0, 3*time.Second, time.Minute, 300)
+	if !strings.Contains(got, "900 queries") || !strings.Contains(got, "3s/1m0s") {
+		t.Errorf("sustained-load progress = %q", got)
+	}
+}
+
+func TestProgressReporterPrintsUntilStopped(t *testing.T) {
+	var buf bytes.Buffer
+	p := StartProgress(&buf, "direct-postgres", 10, 0, 5*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		p.Done()
+	}
+	time.Sleep(30 * time.Millisecond)
+	p.Stop()
+
+	out := buf.String()
+	if !strings.Contains(out, "4/10 queries") {
+		t.Errorf("progress output = %q, want a line with 4/10 queries", out)
+	}
+	if lines := strings.Count(out, "\n"); lines == 0 {
+		t.Error("no progress lines printed")
+	}
+}
+
+func TestNilProgressReporter(t *testing.T) {
+	var p *ProgressReporter
+	p.Done()
+	p.Stop()
+}
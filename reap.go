@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Defaults for the reaping experiment. The health check has to run well within
+// the idle time for reaping to happen soon after it elapses.
+const (
+	DefaultReapIdleTime = 2 * time.Second
+	ReapHealthCheck     = 250 * time.Millisecond
+	ReapSettleTimeout   = 10 * time.Second // How long to wait past the idle time for reaping to finish
+	reapPollInterval    = 50 * time.Millisecond
+)
+
+// BurstStats summarizes one burst of simultaneous acquisitions
+type BurstStats struct {
+	Avg      time.Duration
+	P99      time.Duration
+	Duration time.Duration // Until every worker held a connection
+	NewConns int64         // Connections opened to serve the burst
+}
+
+// ReapResult compares a burst against a full pool with one right after the
+// pool's idle connections were reaped
+type ReapResult struct {
+	IdleTime time.Duration
+	Warm     BurstStats
+	Cold     BurstStats
+	Reaped   int64 // Connections closed for exceeding IdleTime
+}
+
+// runReapTest measures what MaxConnIdleTime costs: it grows a pool with a short
+// idle time to MaxConns, measures a warm burst, idles until the pool has reaped
+// back down to MinConns, then measures the burst that has to rebuild it
+func runReapTest(ctx context.Context, config Config, idleTime time.Duration) (ReapResult, error) {
+	if config.Tuning == (PoolTuning{}) {
+		config.Tuning = DefaultPoolTuning()
+	}
+	config.Tuning.MaxConnIdleTime = idleTime
+	config.Tuning.HealthCheckPeriod = ReapHealthCheck
+	poolConfig, err := newPoolConfig(config)
+	if err != nil {
+		return ReapResult{}, fmt.Errorf("unable to parse config: %w", err)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return ReapResult{}, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+	defer pool.Close()
+
+	pooler := pgxPooler{pool}
+	workers := int(poolConfig.MaxConns)
+	result := ReapResult{IdleTime: idleTime}
+
+	// The first burst grows the pool to MaxConns; the second one finds it full
+	if _, err := measureBurst(ctx, pooler, workers); err != nil {
+		return result, fmt.Errorf("warmup burst: %w", err)
+	}
+	if result.Warm, err = measureBurst(ctx, pooler, workers); err != nil {
+		return result, fmt.Errorf("warm burst: %w", err)
+	}
+
+	// Idle past the idle time until the health check has reaped the pool to MinConns
+	slog.Info("reap test idling", "conn_type", config.ConnType, "idle_time", idleTime)
+	reapedBefore := pool.Stat().MaxIdleDestroyCount()
+	select {
+	case <-time.After(idleTime):
+	case <-ctx.Done():
+		return result, fmt.Errorf("idle period interrupted: %w", ctx.Err())
+	}
+	reaped := pollUntil(ctx, func() bool {
+		return pool.Stat().TotalConns() <= poolConfig.MinConns
+	}, ReapSettleTimeout, reapPollInterval)
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("waiting for reaping interrupted: %w", err)
+	}
+	if !reaped {
+		slog.Warn("Pool was not reaped down to MinConns", "conn_type", config.ConnType,
+			"total_conns", pool.Stat().TotalConns(), "min_conns", poolConfig.MinConns)
+	}
+	result.Reaped = pool.Stat().MaxIdleDestroyCount() - reapedBefore
+
+	if result.Cold, err = measureBurst(ctx, pooler, workers); err != nil {
+		return result, fmt.Errorf("post-reap burst: %w", err)
+	}
+	return result, nil
+}
+
+// measureBurst has n workers acquire a connection from pool at once and hold it
+// until all of them have one, so the pool has to serve n connections
+// simultaneously. Acquisition times are measured per worker.
+func measureBurst(ctx context.Context, pool Pooler, n int) (BurstStats, error) {
+	newConnsBefore := newConnsCount(pool)
+
+	times := make([]time.Duration, n)
+	errs := make([]error, n)
+	var acquired, done sync.WaitGroup
+	acquired.Add(n)
+	done.Add(n)
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer done.Done()
+			acquireStart := time.Now()
+			conn, err := pool.Acquire(ctx)
+			times[i] = time.Since(acquireStart)
+			acquired.Done()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			// Hold the connection until every worker has one
+			acquired.Wait()
+			conn.Release()
+		}(i)
+	}
+	acquired.Wait()
+	duration := time.Since(start)
+	done.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return BurstStats{}, fmt.Errorf("acquire: %w", err)
+		}
+	}
+
+	return BurstStats{
+		Avg:      averageDuration(times),
+		P99:      percentile(times, 99),
+		Duration: duration,
+		NewConns: newConnsCount(pool) - newConnsBefore,
+	}, nil
+}
+
+// newConnsCount is pool's count of connections opened, or 0 without pool statistics
+func newConnsCount(pool Pooler) int64 {
+	if stat := pool.Stat(); stat != nil {
+		return stat.NewConnsCount()
+	}
+	return 0
+}
+
+// printReapResult prints the warm and post-reap bursts side by side
+func printReapResult(r ReapResult) {
+	fmt.Printf("Reap Test Results (MaxConnIdleTime %v, %d connections reaped):\n", r.IdleTime, r.Reaped)
+	fmt.Printf("   %-28s %12s %12s %14s %10s\n", "", "Avg Acquire", "P99 Acquire", "All Acquired", "New Conns")
+	for _, row := range []struct {
+		name  string
+		stats BurstStats
+	}{
+		{"Steady-state warm", r.Warm},
+		{"Post-reap cold", r.Cold},
+	} {
+		fmt.Printf("   %-28s %12v %12v %14v %10d\n", row.name, row.stats.Avg, row.stats.P99, row.stats.Duration, row.stats.NewConns)
+	}
+	if r.Warm.Avg > 0 {
+		fmt.Printf("   Post-reap acquisition is %.1fx the warm average\n", float64(r.Cold.Avg)/float64(r.Warm.Avg))
+	}
+	fmt.Println()
+}
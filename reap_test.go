@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMeasureBurstHoldsEveryConnection(t *testing.T) {
+	pool := newFakePooler(5 * time.Millisecond)
+
+	stats, err := measureBurst(context.Background(), pool, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.acquires.Load(); got != 8 {
+		t.Errorf("acquires = %d, want 8", got)
+	}
+	if got := pool.releases.Load(); got != 8 {
+		t.Errorf("releases = %d, want 8", got)
+	}
+	if stats.Avg < 5*time.Millisecond || stats.P99 < stats.Avg || stats.Duration < stats.P99 {
+		t.Errorf("implausible burst stats %+v", stats)
+	}
+}
+
+func TestMeasureBurstReportsAcquireErrors(t *testing.T) {
+	pool := newFakePooler(0)
+	pool.acquireErr = errors.New("too many connections")
+
+	if _, err := measureBurst(context.Background(), pool, 3); err == nil {
+		t.Fatal("expected an error when acquisitions fail")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Report formats accepted by -report-format
+const (
+	ReportFormatText     = "text"
+	ReportFormatMarkdown = "markdown"
+	ReportFormatHTML     = "html"
+)
+
+// reportTypeOrder lists connection types in the order reports present them
+var reportTypeOrder = []ConnectionType{DirectPostgres, PgBouncerSession, PgBouncerTransaction, PgBouncerSessionSQL, PgBouncerTransactionSQL}
+
+// sortedConnTypes returns the connection types of byType in report order
+func sortedConnTypes[V any](byType map[ConnectionType]V) []ConnectionType {
+	var types []ConnectionType
+	for _, connType := range reportTypeOrder {
+		if _, ok := byType[connType]; ok {
+			types = append(types, connType)
+		}
+	}
+	return types
+}
+
+// failedQueries counts the failed (zero) entries of a run's acquisition times
+func failedQueries(r BenchmarkResult) int {
+	failed := 0
+	for _, t := range r.AcquisitionTimes {
+		if t == 0 {
+			failed++
+		}
+	}
+	return failed
+}
+
+// runLabel names a run by concurrency and whether it was a warmup
+func runLabel(r BenchmarkResult) string {
+	if r.IsWarmup {
+		return fmt.Sprintf("%d (warmup)", r.Concurrency)
+	}
+	if r.Iteration > 1 {
+		return fmt.Sprintf("%d (run %d)", r.Concurrency, r.Iteration)
+	}
+	return fmt.Sprintf("%d", r.Concurrency)
+}
+
+// renderMarkdownReport renders one table per connection type
+func renderMarkdownReport(byType map[ConnectionType][]BenchmarkResult, generated time.Time) string {
+	var sb strings.Builder
+	sb.WriteString("# PGX Connection Pool Benchmark Results\n\n")
+	sb.WriteString(fmt.Sprintf("Generated: %s\n", generated.Format(time.RFC3339)))
+
+	for _, connType := range sortedConnTypes(byType) {
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", connType))
+		sb.WriteString("| Concurrency | Avg | p50 | p95 | p99 | QPS | Failures |\n")
+		sb.WriteString("|---|---|---|---|---|---|---|\n")
+		for _, r := range byType[connType] {
+			sb.WriteString(fmt.Sprintf("| %s | %v | %v | %v | %v | %.2f | %d |\n",
+				runLabel(r), r.AvgAcquisitionTime,
+				percentile(r.AcquisitionTimes, 50), percentile(r.AcquisitionTimes, 95), r.P99AcquisitionTime,
+				r.QueriesPerSecond, failedQueries(r)))
+		}
+	}
+
+	return sb.String()
+}
+
+// renderHTMLReport renders a standalone page with a table per connection type
+// and each run's acquisition-time histogram inline
+func renderHTMLReport(byType map[ConnectionType][]BenchmarkResult, generated time.Time, histogramBuckets int) string {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<title>PGX Connection Pool Benchmark Results</title>\n")
+	sb.WriteString("<style>body{font-family:sans-serif}table{border-collapse:collapse;margin-bottom:1em}" +
+		"td,th{border:1px solid #ccc;padding:4px 8px;text-align:right}.bar{background:#4a90d9;height:12px}</style>\n")
+	sb.WriteString("</head>\n<body>\n<h1>PGX Connection Pool Benchmark Results</h1>\n")
+	sb.WriteString(fmt.Sprintf("<p>Generated: %s</p>\n", generated.Format(time.RFC3339)))
+
+	for _, connType := range sortedConnTypes(byType) {
+		sb.WriteString(fmt.Sprintf("<h2>%s</h2>\n", html.EscapeString(string(connType))))
+		sb.WriteString("<table>\n<tr><th>Concurrency</th><th>Avg</th><th>p50</th><th>p95</th><th>p99</th><th>Max</th><th>QPS</th><th>Failures</th></tr>\n")
+		for _, r := range byType[connType] {
+			sb.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td><td>%v</td><td>%.2f</td><td>%d</td></tr>\n",
+				runLabel(r), r.AvgAcquisitionTime,
+				percentile(r.AcquisitionTimes, 50), percentile(r.AcquisitionTimes, 95), r.P99AcquisitionTime,
+				r.MaxAcquisitionTime, r.QueriesPerSecond, failedQueries(r)))
+		}
+		sb.WriteString("</table>\n")
+
+		for _, r := range byType[connType] {
+			buckets := buildHistogram(r.AcquisitionTimes, histogramBuckets)
+			if len(buckets) == 0 {
+				continue
+			}
+
+			maxCount := 0
+			for _, b := range buckets {
+				maxCount = max(maxCount, b.Count)
+			}
+
+			sb.WriteString(fmt.Sprintf("<h3>Concurrency %s: acquisition time distribution</h3>\n<table>\n", runLabel(r)))
+			for _, b := range buckets {
+				width := 0
+				if maxCount > 0 {
+					width = b.Count * 300 / maxCount
+				}
+				sb.WriteString(fmt.Sprintf("<tr><td>%v – %v</td><td>%d</td><td style=\"text-align:left\"><div class=\"bar\" style=\"width:%dpx\"></div></td></tr>\n",
+					b.Lower, b.Upper, b.Count, width))
+			}
+			sb.WriteString("</table>\n")
+		}
+	}
+
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
+
+// writeFormattedReport writes the markdown or HTML report next to the text one and
+// returns the file name, or "" for the text format
+func writeFormattedReport(byType map[ConnectionType][]BenchmarkResult, opts Options, generated time.Time) (string, error) {
+	var ext, content string
+	switch opts.ReportFormat {
+	case ReportFormatMarkdown:
+		ext, content = ".md", renderMarkdownReport(byType, generated)
+	case ReportFormatHTML:
+		ext, content = ".html", renderHTMLReport(byType, generated, opts.HistogramBuckets)
+	default:
+		return "", nil
+	}
+
+	filename, err := reportPath(opts.OutDir, withExt(opts.ReportFile, ext))
+	if err != nil {
+		return "", err
+	}
+	f, err := createReport(filename, opts.NoClobber, generated)
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s report: %w", opts.ReportFormat, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		return "", fmt.Errorf("failed to write %s report: %w", opts.ReportFormat, err)
+	}
+	return f.Name(), nil
+}
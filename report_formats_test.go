@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdownReport(t *testing.T) {
+	ms := time.Millisecond
+	byType := map[ConnectionType][]BenchmarkResult{
+		PgBouncerTransaction: {{ConnectionType: PgBouncerTransaction, Concurrency: 4, QueriesPerSecond: 12.5,
+			AcquisitionTimes: []time.Duration{1 * ms, 2 * ms, 0, 4 * ms}, AvgAcquisitionTime: 2 * ms, P99AcquisitionTime: 4 * ms}},
+		PgBouncerSession: {{ConnectionType: PgBouncerSession, Concurrency: 4, IsWarmup: true}},
+	}
+
+	out := renderMarkdownReport(byType, time.Unix(0, 0))
+	if strings.Index(out, "## pgbouncer-session") > strings.Index(out, "## pgbouncer-transaction") {
+		t.Error("connection types out of order")
+	}
+	if !strings.Contains(out, "| 4 | 2ms | 2ms | 4ms | 4ms | 12.50 | 1 |") {
+		t.Errorf("missing transaction row:\n%s", out)
+	}
+	if !strings.Contains(out, "| 4 (warmup) |") {
+		t.Errorf("missing warmup row:\n%s", out)
+	}
+}
+
+func TestRenderHTMLReportIncludesHistogram(t *testing.T) {
+	byType := map[ConnectionType][]BenchmarkResult{
+		DirectPostgres: {{ConnectionType: DirectPostgres, Concurrency: 3,
+			AcquisitionTimes: []time.Duration{time.Millisecond, 2 * time.Millisecond, 8 * time.Millisecond}}},
+	}
+
+	out := renderHTMLReport(byType, time.Unix(0, 0), 3)
+	if !strings.Contains(out, "<h2>direct-postgres</h2>") || !strings.Contains(out, "acquisition time distribution") {
+		t.Errorf("missing table or histogram:\n%s", out)
+	}
+	if strings.Count(out, `class="bar"`) != 3 {
+		t.Errorf("expected 3 histogram bars:\n%s", out)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReportMetricsAll selects every report metric
+const ReportMetricsAll = "all"
+
+// DefaultReportMetrics are the metrics the text report shows without
+// -report-metrics: all of them, as before the selection existed
+var DefaultReportMetrics = []string{ReportMetricsAll}
+
+// reportMetric is a group of per-run lines of the text report, rendered from a
+// BenchmarkResult. A metric that doesn't apply to a run renders nothing.
+type reportMetric struct {
+	Name   string
+	render func(r BenchmarkResult) string
+}
+
+// reportField formats one "  Label:  value" line, values aligned in one column
+func reportField(label, format string, args ...any) string {
+	return fmt.Sprintf("  %-22s"+format+"\n", append([]any{label + ":"}, args...)...)
+}
+
+// reportMetrics lists every metric in the order the report shows them
+var reportMetrics = []reportMetric{
+	{"seed", func(r BenchmarkResult) string {
+		if r.Replayed {
+			return reportField("Workload", "replayed (-replay)")
+		}
+		s := reportField("Seed", "%d", r.Seed)
+		if r.ArgDist.Kind != ArgDistUniform && r.ArgDist.Kind != "" {
+			s += reportField("Query Ids", "%s", r.ArgDist)
+		}
+		return s
+	}},
+	{"pool-instances", func(r BenchmarkResult) string {
+		return reportField("Pool Instances", "%d", r.PoolInstances)
+	}},
+	{"exec-mode", func(r BenchmarkResult) string {
+		return reportField("Query Exec Mode", "%s", execModeName(r.ExecMode))
+	}},
+	{"duration", func(r BenchmarkResult) string {
+		s := reportField("Total Duration", "%v", r.TotalDuration)
+		if r.RampUp > 0 {
+			s += reportField("Ramp-Up", "%v", r.RampUp)
+		}
+		if r.Duration > 0 {
+			s += reportField("Sustained Load For", "%v", r.Duration)
+			s += reportField("Total Queries", "%d", r.TotalQueries)
+		}
+		return s
+	}},
+	{"queue-wait", func(r BenchmarkResult) string {
+		if r.TargetQPS <= 0 && !r.Replayed {
+			return ""
+		}
+		var s string
+		if r.TargetQPS > 0 {
+			s = reportField("Target QPS", "%.2f", r.TargetQPS)
+		}
+		return s + reportField("Avg Queue Wait", "%v", r.AvgQueueWait) +
+			reportField("Max Queue Wait", "%v", r.MaxQueueWait)
+	}},
+	{"acquisition", func(r BenchmarkResult) string {
+		return reportField("Avg Acquisition", "%v", r.AvgAcquisitionTime) +
+			reportField("Min Acquisition", "%v", r.MinAcquisitionTime) +
+			reportField("Max Acquisition", "%v", r.MaxAcquisitionTime) +
+			reportField("P99 Acquisition", "%v", r.P99AcquisitionTime)
+	}},
+	{"release", func(r BenchmarkResult) string {
+		return reportField("Avg Release", "%v", r.AvgReleaseTime) +
+			reportField("P99 Release", "%v", r.P99ReleaseTime)
+	}},
+	// The workload's own timings: transactions, batches, queries per
+	// connection or streamed rows, whichever the run used
+	{"query-time", func(r BenchmarkResult) string {
+		var s string
+		if r.Transactions {
+			s += reportField("Avg Transaction Time", "%v", r.AvgQueryTime)
+			s += reportField("P99 Transaction Time", "%v", r.P99QueryTime)
+			s += reportField("Commits / Rollbacks", "%d / %d", r.Commits, r.Rollbacks)
+		}
+		if r.BatchSize > 1 {
+			s += reportField("Batch Size", "%d", r.BatchSize)
+			s += reportField("Avg Batch Time", "%v", r.AvgQueryTime)
+			s += reportField("P99 Batch Time", "%v", r.P99QueryTime)
+			s += reportField("Statements/s", "%.2f", r.QueriesPerSecond*float64(r.BatchSize))
+		}
+		if r.QueriesPerConn > 1 {
+			s += reportField("Queries Per Conn", "%d", r.QueriesPerConn)
+			if !r.Transactions && r.BatchSize <= 1 {
+				s += reportField("Avg Per-Query Time", "%v", r.AvgQueryTime)
+				s += reportField("P99 Per-Query Time", "%v", r.P99QueryTime)
+			}
+		}
+		if r.RowsPerQuery > 1 {
+			s += reportField("Rows Per Query", "%d", r.RowsPerQuery)
+			s += reportField("Avg Streaming Time", "%v", r.AvgStreamTime)
+			s += reportField("P99 Streaming Time", "%v", r.P99StreamTime)
+		}
+		return s
+	}},
+	{"qps", func(r BenchmarkResult) string {
+		return reportField("QPS", "%.2f", r.QueriesPerSecond)
+	}},
+	{"timeline", func(r BenchmarkResult) string {
+		if timeline := formatTimelineSummary(r); timeline != "" {
+			return reportField("Timeline", "%s", timeline)
+		}
+		return ""
+	}},
+	{"fairness", func(r BenchmarkResult) string {
+		if fairness := formatFairness(r); fairness != "" {
+			return reportField("Fairness", "%s", fairness)
+		}
+		return ""
+	}},
+	{"goroutines", func(r BenchmarkResult) string {
+		g := r.Goroutines
+		if g == nil {
+			return ""
+		}
+		leaked := ""
+		if g.Leaked {
+			leaked = " (LEAK SUSPECTED)"
+		}
+		return reportField("Goroutines", "%d → %d (%+d)%s", g.Before, g.After, g.Delta(), leaked)
+	}},
+	{"server-waits", func(r BenchmarkResult) string {
+		if r.ActivitySamples == 0 {
+			return ""
+		}
+		return reportField("Server Waits", "%s (%d samples)", formatTopWaits(r.ServerWaits, DefaultTopWaits), r.ActivitySamples)
+	}},
+	{"pool-stats", func(r BenchmarkResult) string {
+		return reportField("Peak Acquired Conns", "%d", r.PeakAcquiredConns) +
+			reportField("Empty Acquire Waits", "%d", r.EmptyAcquireWaits) +
+			reportField("Pool Acquire Time", "%v", r.PoolAcquireTime) +
+			reportField("New Connections", "%d (establishing took %v, avg %v)", r.NewConns, r.NewConnEstablishTime, avgConnectTime(r))
+	}},
+	{"backend-pids", func(r BenchmarkResult) string {
+		return reportField("Backend PIDs", "%d distinct (%.1f queries per backend)", r.DistinctBackendPIDs, backendReuseRatio(r))
+	}},
+	{"backend-latency", func(r BenchmarkResult) string {
+		if len(r.NewBackendQueryTimes)+len(r.ReusedBackendQueryTimes) == 0 {
+			return ""
+		}
+		return reportField("Backend Latency", "%s", formatBackendLatency(r.NewBackendQueryTimes, r.ReusedBackendQueryTimes))
+	}},
+	{"timeouts", func(r BenchmarkResult) string {
+		if r.AcquireTimeout <= 0 {
+			return ""
+		}
+		return reportField("Acquire Timeouts", "%d (timeout %v)", r.AcquisitionTimeouts, r.AcquireTimeout)
+	}},
+	{"cancellations", func(r BenchmarkResult) string {
+		if r.QueryTimeout <= 0 {
+			return ""
+		}
+		return reportField("Cancelled Queries", "%s", formatCancellations(r))
+	}},
+	{"watchdog", func(r BenchmarkResult) string {
+		if r.AbandonedWorkers == 0 {
+			return ""
+		}
+		return reportField("Abandoned Workers", "%d of %d (watchdog deadline passed)", r.AbandonedWorkers, r.Concurrency)
+	}},
+	{"errors", func(r BenchmarkResult) string {
+		if len(r.ErrorCategories) == 0 {
+			return ""
+		}
+		return reportField("Errors", "%s", formatErrorCounts(r.ErrorCategories))
+	}},
+	{"per-pool", func(r BenchmarkResult) string {
+		if len(r.PerPool) <= 1 {
+			return ""
+		}
+		s := "  Per Pool Instance:\n"
+		for _, ps := range r.PerPool {
+			s += fmt.Sprintf("    Pool %d: queries=%d failures=%d avg=%v p99=%v max=%v\n",
+				ps.PoolIndex, ps.Queries, ps.Failures, ps.Avg, ps.P99, ps.Max)
+		}
+		return s
+	}},
+	{"per-endpoint", func(r BenchmarkResult) string {
+		if len(r.PerEndpoint) <= 1 {
+			return ""
+		}
+		return "  Per Endpoint:\n" + renderEndpointStats(r.PerEndpoint)
+	}},
+}
+
+// reportMetricNames lists every metric name in report order
+func reportMetricNames() []string {
+	names := make([]string, len(reportMetrics))
+	for i, m := range reportMetrics {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// validateReportMetrics checks that every name is a known metric or "all"
+func validateReportMetrics(names []string) error {
+	known := make(map[string]bool, len(reportMetrics))
+	for _, m := range reportMetrics {
+		known[m.Name] = true
+	}
+	var unknown []string
+	for _, name := range names {
+		if name != ReportMetricsAll && !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown report metric(s) %s; known: %s, or %s",
+			strings.Join(unknown, ", "), strings.Join(reportMetricNames(), ", "), ReportMetricsAll)
+	}
+	return nil
+}
+
+// renderReportMetrics renders a run's selected metrics, in report order
+// whatever order they were selected in
+func renderReportMetrics(r BenchmarkResult, names []string) string {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	var sb strings.Builder
+	for _, m := range reportMetrics {
+		if selected[ReportMetricsAll] || selected[m.Name] {
+			sb.WriteString(m.render(r))
+		}
+	}
+	return sb.String()
+}
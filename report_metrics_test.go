@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderReportMetricsSelectsInReportOrder(t *testing.T) {
+	r := BenchmarkResult{
+		Seed:               42,
+		AvgAcquisitionTime: time.Millisecond,
+		QueriesPerSecond:   1234.5,
+		ErrorCategories:    map[ErrorCategory]int{ErrOther: 3},
+	}
+
+	// Selected in any order, shown in report order
+	got := renderReportMetrics(r, []string{"qps", "seed"})
+	want := "  Seed:                 42\n" +
+		"  QPS:                  1234.50\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A selected metric that doesn't apply to the run renders nothing
+	if got := renderReportMetrics(r, []string{"timeouts", "per-pool"}); got != "" {
+		t.Errorf("expected nothing without -acquire-timeout or several pools, got:\n%s", got)
+	}
+
+	all := renderReportMetrics(r, []string{ReportMetricsAll})
+	for _, label := range []string{"Seed:", "Avg Acquisition:", "QPS:", "Backend PIDs:", "Errors:"} {
+		if !strings.Contains(all, label) {
+			t.Errorf("all metrics missing %q:\n%s", label, all)
+		}
+	}
+	if defaults := renderReportMetrics(r, DefaultReportMetrics); defaults != all {
+		t.Errorf("default metrics should be all of them:\n%s", defaults)
+	}
+}
+
+func TestValidateReportMetrics(t *testing.T) {
+	if err := validateReportMetrics(append(reportMetricNames(), ReportMetricsAll)); err != nil {
+		t.Errorf("every known metric should validate: %v", err)
+	}
+	err := validateReportMetrics([]string{"qps", "p999", "stddev"})
+	if err == nil || !strings.Contains(err.Error(), "p999, stddev") {
+		t.Errorf("err = %v, want both unknown names listed", err)
+	}
+}
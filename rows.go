@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StreamTimes records how long each query took to stream its rows, from the
+// query returning to its last row being read. It is safe for concurrent use; a
+// nil recorder ignores additions.
+type StreamTimes struct {
+	mu    sync.Mutex
+	times []time.Duration
+}
+
+// Add records one query's streaming time
+func (s *StreamTimes) Add(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.times = append(s.times, d)
+	s.mu.Unlock()
+}
+
+// Times returns a copy of the streaming times recorded so far
+func (s *StreamTimes) Times() []time.Duration {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Duration(nil), s.times...)
+}
+
+// ReleaseTimes records how long each worker took to give its connection back:
+// closing the last query's rows and returning the connection to the pool. It
+// is safe for concurrent use; a nil recorder ignores additions.
+type ReleaseTimes struct {
+	mu    sync.Mutex
+	times []time.Duration
+}
+
+// Add records one release
+func (r *ReleaseTimes) Add(d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.times = append(r.times, d)
+	r.mu.Unlock()
+}
+
+// Times returns a copy of the release times recorded so far
+func (r *ReleaseTimes) Times() []time.Duration {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Duration(nil), r.times...)
+}
+
+// drainRows reads every remaining row of rows, calling scan for each, and returns
+// how many rows were read. It stops at the first scan error; otherwise it reports
+// any iteration error from rows.Err. The caller still owns closing rows.
+func drainRows(rows pgx.Rows, scan func(pgx.Rows) error) (int, error) {
+	n := 0
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return n, fmt.Errorf("scan row %d: %w", n, err)
+		}
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("iterate rows: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeRows is a pgx.Rows over canned values that can fail scanning or iteration
+type fakeRows struct {
+	values  [][]any
+	pos     int
+	scanErr error // returned by Scan on the row at failAt
+	failAt  int
+	iterErr error // returned by Err once Next is exhausted
+}
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *fakeRows) Next() bool {
+	if r.pos >= len(r.values) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+func (r *fakeRows) Err() error {
+	if r.pos >= len(r.values) {
+		return r.iterErr
+	}
+	return nil
+}
+
+func (r *fakeRows) Values() ([]any, error) { return r.values[r.pos-1], nil }
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if r.scanErr != nil && r.pos-1 == r.failAt {
+		return r.scanErr
+	}
+	row := r.values[r.pos-1]
+	for i, d := range dest {
+		if i >= len(row) {
+			break
+		}
+		switch d := d.(type) {
+		case *int:
+			*d = row[i].(int)
+		case *string:
+			*d = row[i].(string)
+		case *uint32:
+			*d = row[i].(uint32)
+		case *bool:
+			*d = row[i].(bool)
+		case *int64:
+			*d = row[i].(int64)
+		}
+	}
+	return nil
+}
+
+func scanIDName(ids *[]int) func(pgx.Rows) error {
+	return func(rows pgx.Rows) error {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		*ids = append(*ids, id)
+		return nil
+	}
+}
+
+func TestDrainRowsReadsEveryRow(t *testing.T) {
+	rows := &fakeRows{values: [][]any{{1, "a"}, {2, "b"}, {3, "c"}}}
+
+	var ids []int
+	n, err := drainRows(rows, scanIDName(&ids))
+	if err != nil || n != 3 || len(ids) != 3 {
+		t.Errorf("drainRows = %d, %v (ids %v), want 3 rows", n, err, ids)
+	}
+}
+
+func TestDrainRowsStopsAtScanError(t *testing.T) {
+	scanErr := errors.New("cannot scan")
+	rows := &fakeRows{values: [][]any{{1, "a"}, {2, "b"}, {3, "c"}}, scanErr: scanErr, failAt: 1}
+
+	var ids []int
+	n, err := drainRows(rows, scanIDName(&ids))
+	if !errors.Is(err, scanErr) || n != 1 {
+		t.Errorf("drainRows = %d, %v, want 1 row and the scan error", n, err)
+	}
+}
+
+func TestDrainRowsReportsIterationError(t *testing.T) {
+	iterErr := errors.New("connection reset")
+	rows := &fakeRows{values: [][]any{{1, "a"}}, iterErr: iterErr}
+
+	var ids []int
+	n, err := drainRows(rows, scanIDName(&ids))
+	if !errors.Is(err, iterErr) || n != 1 {
+		t.Errorf("drainRows = %d, %v, want 1 row and the iteration error", n, err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetupBenchmarkData creates the benchmark_data table (matching init-db/init.sql)
+// and reseeds it with the given number of rows, ids 1..rows. dsn should connect
+// straight to PostgreSQL, bypassing PgBouncer: transaction-mode PgBouncer
+// complicates DDL.
+func SetupBenchmarkData(ctx context.Context, dsn string, rows int) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS benchmark_data (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			email VARCHAR(100) NOT NULL,
+			age INTEGER NOT NULL,
+			city VARCHAR(50) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_benchmark_email ON benchmark_data(email)`,
+		`CREATE INDEX IF NOT EXISTS idx_benchmark_city ON benchmark_data(city)`,
+		`TRUNCATE benchmark_data RESTART IDENTITY`,
+	}
+	for _, stmt := range statements {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to prepare benchmark_data: %w", err)
+		}
+	}
+
+	_, err = conn.Exec(ctx, `
+		INSERT INTO benchmark_data (name, email, age, city)
+		SELECT 'User ' || n, 'user' || n || '@example.com', 20 + n % 50, 'City ' || n % 25
+		FROM generate_series(1, $1) AS n`, rows)
+	if err != nil {
+		return fmt.Errorf("failed to seed benchmark_data: %w", err)
+	}
+
+	return nil
+}
+
+// TeardownBenchmarkData drops the benchmark_data table
+func TeardownBenchmarkData(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, `DROP TABLE IF EXISTS benchmark_data`); err != nil {
+		return fmt.Errorf("failed to drop benchmark_data: %w", err)
+	}
+
+	return nil
+}
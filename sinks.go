@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ResultSink is one destination for a benchmark's results: the console, a
+// report file, a saved-results file. main writes the results to every sink
+// the options enable (see resultSinks), so a new output is a new sink rather
+// than one more flag threaded through the report code.
+type ResultSink interface {
+	Write(results []BenchmarkResult) error
+}
+
+// resultSinks assembles the sinks opts enable, in the order they are written:
+// the console and the text report always, then the -report-format report and
+// the -save-results file when asked for. The report sinks also need the idle
+// test results and the time the report was generated.
+func resultSinks(opts Options, idleResults []IdleTestResult, generated time.Time) []ResultSink {
+	sinks := []ResultSink{
+		&ConsoleSink{W: os.Stdout, IdleResults: idleResults, Opts: opts, Generated: generated},
+		&TextReportSink{IdleResults: idleResults, Opts: opts, Generated: generated},
+	}
+	if opts.ReportFormat != ReportFormatText {
+		sinks = append(sinks, &FormattedReportSink{Opts: opts, Generated: generated})
+	}
+	if opts.SaveResults != "" {
+		sinks = append(sinks, &SavedResultsSink{Filename: opts.SaveResults, Tuning: opts.PoolTuning})
+	}
+	return sinks
+}
+
+// ConsoleSink prints the text report under a FINAL BENCHMARK REPORT banner
+type ConsoleSink struct {
+	W           io.Writer
+	IdleResults []IdleTestResult
+	Opts        Options
+	Generated   time.Time
+}
+
+func (s *ConsoleSink) Write(results []BenchmarkResult) error {
+	banner := strings.Repeat("=", 80)
+	_, err := fmt.Fprintf(s.W, "\n%s\nFINAL BENCHMARK REPORT\n%s\n%s\n", banner, banner,
+		renderTextReport(results, s.IdleResults, s.Opts, s.Generated))
+	return err
+}
+
+// TextReportSink writes the text report to -report-file, under -outdir and
+// keeping any earlier report with -no-clobber
+type TextReportSink struct {
+	IdleResults []IdleTestResult
+	Opts        Options
+	Generated   time.Time
+}
+
+func (s *TextReportSink) Write(results []BenchmarkResult) error {
+	filename, err := reportPath(s.Opts.OutDir, s.Opts.ReportFile)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	f, err := createReport(filename, s.Opts.NoClobber, s.Generated)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(renderTextReport(results, s.IdleResults, s.Opts, s.Generated)); err != nil {
+		return fmt.Errorf("failed to write report file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write report file: %w", err)
+	}
+	fmt.Printf("\nFull report saved to: %s\n", f.Name())
+	logPhase(PhaseReportWritten, "", "path", f.Name(), "format", ReportFormatText)
+	return nil
+}
+
+// FormattedReportSink writes the markdown or HTML report of -report-format next
+// to the text report
+type FormattedReportSink struct {
+	Opts      Options
+	Generated time.Time
+}
+
+func (s *FormattedReportSink) Write(results []BenchmarkResult) error {
+	byType := make(map[ConnectionType][]BenchmarkResult)
+	for _, r := range results {
+		byType[r.ConnectionType] = append(byType[r.ConnectionType], r)
+	}
+	filename, err := writeFormattedReport(byType, s.Opts, s.Generated)
+	if err != nil || filename == "" {
+		return err
+	}
+	fmt.Printf("%s report saved to: %s\n", s.Opts.ReportFormat, filename)
+	logPhase(PhaseReportWritten, "", "path", filename, "format", s.Opts.ReportFormat)
+	return nil
+}
+
+// SavedResultsSink saves the results as JSON for -baseline and -merge (-save-results)
+type SavedResultsSink struct {
+	Filename string
+	Tuning   PoolTuning
+}
+
+func (s *SavedResultsSink) Write(results []BenchmarkResult) error {
+	if err := SaveResults(results, s.Tuning, s.Filename); err != nil {
+		return fmt.Errorf("failed to save results: %w", err)
+	}
+	fmt.Printf("Saved results to %s\n", s.Filename)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultSinksFollowOptions(t *testing.T) {
+	sinkTypes := func(args ...string) string {
+		opts, err := parseOptions(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var types []string
+		for _, sink := range resultSinks(opts, nil, time.Now()) {
+			types = append(types, fmt.Sprintf("%T", sink))
+		}
+		return strings.Join(types, " ")
+	}
+
+	if got, want := sinkTypes(), "*main.ConsoleSink *main.TextReportSink"; got != want {
+		t.Errorf("default sinks = %s, want %s", got, want)
+	}
+	want := "*main.ConsoleSink *main.TextReportSink *main.FormattedReportSink *main.SavedResultsSink"
+	if got := sinkTypes("-report-format", "markdown", "-save-results", "results.json"); got != want {
+		t.Errorf("sinks = %s, want %s", got, want)
+	}
+}
+
+func TestReportSinksWriteTheSameReport(t *testing.T) {
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerTransaction, Concurrency: 10, AcquisitionTimes: []time.Duration{time.Millisecond}, TotalQueries: 1},
+		{ConnectionType: PgBouncerSession, Concurrency: 10, AcquisitionTimes: []time.Duration{2 * time.Millisecond}, TotalQueries: 1},
+	}
+	opts, err := parseOptions([]string{"-outdir", t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	var console bytes.Buffer
+	if err := (&ConsoleSink{W: &console, Opts: opts, Generated: generated}).Write(results); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&TextReportSink{Opts: opts, Generated: generated}).Write(results); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(filepath.Join(opts.OutDir, DefaultReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(console.String(), "FINAL BENCHMARK REPORT") || !strings.Contains(console.String(), string(saved)) {
+		t.Errorf("console output should be the banner and the saved report:\n%s", console.String())
+	}
+	// Connection types come in report order, not map order
+	session := strings.Index(string(saved), "Connection Type: "+string(PgBouncerSession))
+	if transaction := strings.Index(string(saved), "Connection Type: "+string(PgBouncerTransaction)); session < 0 || session > transaction {
+		t.Errorf("session mode should come first:\n%s", saved)
+	}
+}
+
+func TestSavedResultsSink(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.json")
+	sink := &SavedResultsSink{Filename: filename, Tuning: DefaultPoolTuning()}
+	if err := sink.Write([]BenchmarkResult{{ConnectionType: PgBouncerSession, Concurrency: 10, QueriesPerSecond: 42}}); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := LoadBaseline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline) != 1 || baseline[0].QueriesPerSecond != 42 {
+		t.Errorf("saved results = %+v", baseline)
+	}
+
+	bad := &SavedResultsSink{Filename: filepath.Join(t.TempDir(), "missing", "results.json")}
+	if err := bad.Write(nil); err == nil {
+		t.Error("expected an error writing into a missing directory")
+	}
+}
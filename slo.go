@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SLO is an acquisition-latency objective such as "99% of acquisitions under 50ms"
+type SLO struct {
+	Percentile float64       // 0-100
+	Threshold  time.Duration // The percentile must not exceed this
+}
+
+func (s SLO) String() string {
+	return fmt.Sprintf("p%s<%v", strconv.FormatFloat(s.Percentile, 'f', -1, 64), s.Threshold)
+}
+
+// parseSLO parses "p99<50ms" (or "p99.9<10ms")
+func parseSLO(spec string) (SLO, error) {
+	pct, threshold, ok := strings.Cut(strings.TrimSpace(spec), "<")
+	if !ok || !strings.HasPrefix(pct, "p") {
+		return SLO{}, fmt.Errorf("invalid SLO %q, want e.g. p99<50ms", spec)
+	}
+
+	p, err := strconv.ParseFloat(strings.TrimPrefix(pct, "p"), 64)
+	if err != nil || p <= 0 || p > 100 {
+		return SLO{}, fmt.Errorf("invalid SLO percentile %q, want p1 to p100", pct)
+	}
+	d, err := time.ParseDuration(threshold)
+	if err != nil || d <= 0 {
+		return SLO{}, fmt.Errorf("invalid SLO threshold %q, want a positive duration", threshold)
+	}
+	return SLO{Percentile: p, Threshold: d}, nil
+}
+
+// SLOResult is the verdict of an SLO for one connection type, judged on its
+// worst actual run
+type SLOResult struct {
+	ConnectionType ConnectionType
+	Passed         bool
+	Worst          time.Duration // Highest percentile value across the actual runs
+	WorstAt        int           // Concurrency of the run with the highest value
+}
+
+// evaluateSLO checks the SLO against every actual (non-warmup) run and returns
+// one verdict per connection type, in first-seen order
+func evaluateSLO(slo SLO, results []BenchmarkResult) []SLOResult {
+	var verdicts []SLOResult
+	index := make(map[ConnectionType]int)
+	for _, r := range results {
+		if r.IsWarmup {
+			continue
+		}
+		i, ok := index[r.ConnectionType]
+		if !ok {
+			i = len(verdicts)
+			index[r.ConnectionType] = i
+			verdicts = append(verdicts, SLOResult{ConnectionType: r.ConnectionType, Passed: true})
+		}
+
+		value := percentile(r.AcquisitionTimes, slo.Percentile)
+		if value > verdicts[i].Worst {
+			verdicts[i].Worst = value
+			verdicts[i].WorstAt = r.Concurrency
+		}
+		if value > slo.Threshold {
+			verdicts[i].Passed = false
+		}
+	}
+	return verdicts
+}
+
+// printSLOResults prints a PASS/FAIL line per connection type and reports whether all passed
+func printSLOResults(slo SLO, verdicts []SLOResult) bool {
+	allPassed := true
+	fmt.Printf("SLO %s:\n", slo)
+	for _, v := range verdicts {
+		status := "PASS"
+		if !v.Passed {
+			status = "FAIL"
+			allPassed = false
+		}
+		fmt.Printf("  %s %-22s worst p%s %v at concurrency %d\n",
+			status, v.ConnectionType, strconv.FormatFloat(slo.Percentile, 'f', -1, 64), v.Worst, v.WorstAt)
+	}
+	return allPassed
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSLO(t *testing.T) {
+	slo, err := parseSLO("p99.9<50ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slo.Percentile != 99.9 || slo.Threshold != 50*time.Millisecond {
+		t.Errorf("parseSLO = %+v", slo)
+	}
+	if slo.String() != "p99.9<50ms" {
+		t.Errorf("String = %q", slo.String())
+	}
+
+	for _, bad := range []string{"99<50ms", "p99", "p0<50ms", "p101<50ms", "p99<fast", "p99<-1ms"} {
+		if _, err := parseSLO(bad); err == nil {
+			t.Errorf("parseSLO(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestEvaluateSLO(t *testing.T) {
+	ms := time.Millisecond
+	results := []BenchmarkResult{
+		// Warmups don't count, however slow
+		{ConnectionType: PgBouncerSession, Concurrency: 10, IsWarmup: true, AcquisitionTimes: []time.Duration{time.Second}},
+		{ConnectionType: PgBouncerSession, Concurrency: 10, AcquisitionTimes: []time.Duration{10 * ms, 20 * ms}},
+		{ConnectionType: PgBouncerSession, Concurrency: 100, AcquisitionTimes: []time.Duration{30 * ms, 40 * ms}},
+		{ConnectionType: PgBouncerTransaction, Concurrency: 10, AcquisitionTimes: []time.Duration{10 * ms, 60 * ms}},
+	}
+
+	verdicts := evaluateSLO(SLO{Percentile: 99, Threshold: 50 * ms}, results)
+	if len(verdicts) != 2 {
+		t.Fatalf("got %d verdicts, want 2", len(verdicts))
+	}
+	if v := verdicts[0]; !v.Passed || v.Worst != 40*ms || v.WorstAt != 100 {
+		t.Errorf("session = %+v, want a pass with worst 40ms at 100", v)
+	}
+	if v := verdicts[1]; v.Passed || v.Worst != 60*ms {
+		t.Errorf("transaction = %+v, want a fail at 60ms", v)
+	}
+}
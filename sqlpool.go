@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// newSQLDB opens a database/sql pool through pgx's stdlib driver, sized and
+// aged like the pgxpool instances: MaxOpenConns and MaxIdleConns are MaxConns,
+// and connections are retired after MaxConnLifetime or MaxConnIdleTime.
+// database/sql has no lifetime jitter, health checks or MinConns.
+func newSQLDB(config Config, connects *ConnectTimer) (*sql.DB, error) {
+	poolConfig, err := newPoolConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	connConfig := poolConfig.ConnConfig
+	if connConfig.Tracer != nil {
+		connConfig.Tracer = multitracer.New(connects, connConfig.Tracer)
+	} else {
+		connConfig.Tracer = connects
+	}
+
+	db := stdlib.OpenDB(*connConfig)
+	db.SetMaxOpenConns(int(poolConfig.MaxConns))
+	db.SetMaxIdleConns(int(poolConfig.MaxConns))
+	db.SetConnMaxLifetime(poolConfig.MaxConnLifetime)
+	db.SetConnMaxIdleTime(poolConfig.MaxConnIdleTime)
+	return db, nil
+}
+
+// sqlPooler adapts a database/sql pool to Pooler. Connections are checked out
+// of and back into database/sql's pool, while queries run on the pgx
+// connection underneath, so the workload matches the pgxpool connection types
+// and only the pooling differs.
+type sqlPooler struct {
+	db *sql.DB
+}
+
+// Acquire checks out a connection, returning a nil interface on error
+func (p sqlPooler) Acquire(ctx context.Context) (PooledConn, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pgx connection is used past Raw's callback, which is safe as long as
+	// the sql.Conn stays checked out: database/sql hands it to nobody else
+	var pgxConn *pgx.Conn
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected database/sql driver connection %T", driverConn)
+		}
+		pgxConn = c.Conn()
+		return nil
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &sqlConn{Conn: pgxConn, conn: conn}, nil
+}
+
+// Query runs sql on a connection of its own, released when the rows are closed
+func (p sqlPooler) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &releasingRows{Rows: rows, release: conn.Release}, nil
+}
+
+// Stat returns nil: database/sql keeps its own statistics (sql.DBStats), which
+// pgxpool.Stat can't carry
+func (p sqlPooler) Stat() *pgxpool.Stat { return nil }
+
+func (p sqlPooler) Close() { p.db.Close() }
+
+// sqlConn is a database/sql connection checked out by a worker
+type sqlConn struct {
+	*pgx.Conn
+	conn *sql.Conn
+}
+
+// Release returns the connection to database/sql's pool. Releasing twice is harmless.
+func (c *sqlConn) Release() {
+	c.conn.Close()
+}
+
+// releasingRows releases the connection its rows came from once they're closed
+type releasingRows struct {
+	pgx.Rows
+	release func()
+}
+
+func (r *releasingRows) Close() {
+	r.Rows.Close()
+	r.release()
+}
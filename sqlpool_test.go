@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestConnectionTypeDatabaseSQL(t *testing.T) {
+	for connType, want := range map[ConnectionType]bool{
+		PgBouncerSession:        false,
+		PgBouncerTransaction:    false,
+		PgBouncerSessionSQL:     true,
+		PgBouncerTransactionSQL: true,
+	} {
+		if got := connType.usesDatabaseSQL(); got != want {
+			t.Errorf("%s usesDatabaseSQL = %v, want %v", connType, got, want)
+		}
+	}
+	if got := PgBouncerTransactionSQL.sessionMode(); got != PgBouncerSessionSQL {
+		t.Errorf("sessionMode = %s, want %s", got, PgBouncerSessionSQL)
+	}
+	if got := PgBouncerTransaction.sessionMode(); got != PgBouncerSession {
+		t.Errorf("sessionMode = %s, want %s", got, PgBouncerSession)
+	}
+}
+
+func TestTargetAttributesDatabaseSQLPoolMode(t *testing.T) {
+	attrs := targetAttributes(Config{ConnType: PgBouncerTransactionSQL, DSN: "postgres://u:p@localhost:6433/db"})
+	for _, attr := range attrs {
+		if attr.Key == AttrPoolMode && attr.Value.AsString() == "transaction" {
+			return
+		}
+	}
+	t.Errorf("attributes %v lack pgbouncer.pool_mode=transaction", attrs)
+}
+
+func TestDatabaseSQLPoolSetAgainstFakeServer(t *testing.T) {
+	const latency = time.Millisecond
+	server := startFakePGServer(t, latency)
+	config := Config{ConnType: PgBouncerTransactionSQL, DSN: server.DSN(), MaxConns: 4}
+
+	pools, err := NewPoolSet(config, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pools.Close()
+	if pools.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", pools.Len())
+	}
+	for _, pooler := range pools.Poolers() {
+		if _, ok := pooler.(sqlPooler); !ok {
+			t.Fatalf("pooler is %T, want sqlPooler", pooler)
+		}
+	}
+
+	pools.Prime()
+	if _, ready := pools.WaitReady(time.Second); !ready {
+		t.Error("database/sql pools not ready after priming")
+	}
+
+	// Rows from the pooler itself hand their connection back once closed
+	pooler := pools.Poolers()[0]
+	rows, err := pooler.Query(context.Background(), "SELECT COUNT(*) FROM benchmark_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if _, err := drainRows(rows, func(rows pgx.Rows) error { return rows.Scan(&count) }); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if inUse := pooler.(sqlPooler).db.Stats().InUse; inUse != 0 {
+		t.Errorf("%d connections still in use after closing the rows", inUse)
+	}
+
+	for _, flags := range [][]string{nil, {"-transactions"}, {"-batch-size", "3"}} {
+		opts, err := parseOptions(append([]string{"-quiet", "-log-level", "warn"}, flags...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		const concurrency = 12
+		r := runBenchmark(config, pools.Poolers(), pools.ConnectTimer(), concurrency, false, NewTraceCollector(), opts)
+		if r.TotalQueries != concurrency || len(r.ErrorCategories) != 0 {
+			t.Fatalf("%v: ran %d queries with errors %v, want %d without errors", flags, r.TotalQueries, r.ErrorCategories, concurrency)
+		}
+		if r.MinAcquisitionTime < latency || r.DistinctBackendPIDs < 1 {
+			t.Errorf("%v: min %v, %d backends", flags, r.MinAcquisitionTime, r.DistinctBackendPIDs)
+		}
+	}
+
+	if leaks := pools.Shutdown(slog.Default(), PoolCloseWait); len(leaks) != 0 {
+		t.Errorf("leaks = %+v, want none", leaks)
+	}
+}
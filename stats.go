@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// successfulTimes returns the non-zero (successful) durations sorted ascending
+func successfulTimes(times []time.Duration) []time.Duration {
+	sorted := make([]time.Duration, 0, len(times))
+	for _, t := range times {
+		if t > 0 {
+			sorted = append(sorted, t)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// percentile returns the p-th percentile (0-100) of the successful durations
+// using the nearest-rank method, or 0 when there are none
+func percentile(times []time.Duration, p float64) time.Duration {
+	sorted := successfulTimes(times)
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+// averageDuration returns the mean of the successful durations, or 0 when there are none
+func averageDuration(times []time.Duration) time.Duration {
+	succeeded := successfulTimes(times)
+	if len(succeeded) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, t := range succeeded {
+		total += t
+	}
+	return total / time.Duration(len(succeeded))
+}
+
+// PoolStats summarizes the queries issued through one pool instance
+type PoolStats struct {
+	PoolIndex int
+	Queries   int
+	Failures  int
+	Avg       time.Duration
+	Min       time.Duration
+	Max       time.Duration
+	P99       time.Duration
+}
+
+// summarizePerPool buckets query times by the pool instance of the issuing worker
+// (workerID % poolInstances) and summarizes each bucket. Without worker IDs, entry i
+// belongs to worker i.
+func summarizePerPool(times []time.Duration, workerIDs []int, poolInstances int) []PoolStats {
+	if poolInstances <= 0 {
+		return nil
+	}
+
+	buckets := make([][]time.Duration, poolInstances)
+	for i, t := range times {
+		workerID := i
+		if i < len(workerIDs) {
+			workerID = workerIDs[i]
+		}
+		poolIndex := workerID % poolInstances
+		buckets[poolIndex] = append(buckets[poolIndex], t)
+	}
+
+	stats := make([]PoolStats, poolInstances)
+	for poolIndex, bucket := range buckets {
+		succeeded := successfulTimes(bucket)
+		ps := PoolStats{
+			PoolIndex: poolIndex,
+			Queries:   len(bucket),
+			Failures:  len(bucket) - len(succeeded),
+			P99:       percentile(succeeded, 99),
+		}
+		if len(succeeded) > 0 {
+			var total time.Duration
+			for _, t := range succeeded {
+				total += t
+			}
+			ps.Avg = total / time.Duration(len(succeeded))
+			ps.Min = succeeded[0]
+			ps.Max = succeeded[len(succeeded)-1]
+		}
+		stats[poolIndex] = ps
+	}
+
+	return stats
+}
+
+// EndpointStats summarizes the queries issued through the pool instances
+// connected to one shard endpoint
+type EndpointStats struct {
+	Endpoint string // host:port of the DSN
+	Pools    int    // Pool instances connected to it
+	Queries  int
+	Failures int
+	Avg      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+// summarizePerEndpoint buckets query times by the endpoint of the issuing
+// worker's pool instance, pool i connecting to dsns[i%len(dsns)]. It returns nil
+// when there are fewer than two endpoints.
+func summarizePerEndpoint(times []time.Duration, workerIDs []int, poolInstances int, dsns []string) []EndpointStats {
+	if len(dsns) < 2 || poolInstances <= 0 {
+		return nil
+	}
+
+	stats := make([]EndpointStats, len(dsns))
+	for i, dsn := range dsns {
+		stats[i].Endpoint = endpointLabel(dsn, i)
+	}
+	for poolIndex := 0; poolIndex < poolInstances; poolIndex++ {
+		stats[poolIndex%len(dsns)].Pools++
+	}
+
+	buckets := make([][]time.Duration, len(dsns))
+	for i, t := range times {
+		workerID := i
+		if i < len(workerIDs) {
+			workerID = workerIDs[i]
+		}
+		endpoint := (workerID % poolInstances) % len(dsns)
+		buckets[endpoint] = append(buckets[endpoint], t)
+	}
+
+	for i, bucket := range buckets {
+		succeeded := successfulTimes(bucket)
+		stats[i].Queries = len(bucket)
+		stats[i].Failures = len(bucket) - len(succeeded)
+		stats[i].Avg = averageDuration(succeeded)
+		stats[i].P99 = percentile(succeeded, 99)
+		if len(succeeded) > 0 {
+			stats[i].Max = succeeded[len(succeeded)-1]
+		}
+	}
+
+	return stats
+}
+
+// endpointLabel names a DSN by host and port, leaving out credentials. A DSN
+// that doesn't parse is named by its position i.
+func endpointLabel(dsn string, i int) string {
+	connConfig, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return fmt.Sprintf("endpoint %d", i)
+	}
+	return net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port)))
+}
+
+// renderEndpointStats renders one line per endpoint, marking the one with the
+// highest p99 as the hot shard
+func renderEndpointStats(stats []EndpointStats) string {
+	hottest := 0
+	for i, es := range stats {
+		if es.P99 > stats[hottest].P99 {
+			hottest = i
+		}
+	}
+
+	var sb strings.Builder
+	for i, es := range stats {
+		marker := ""
+		if i == hottest && len(stats) > 1 {
+			marker = "  ← hottest"
+		}
+		sb.WriteString(fmt.Sprintf("    %-22s pools=%d queries=%d failures=%d avg=%v p99=%v max=%v%s\n",
+			es.Endpoint, es.Pools, es.Queries, es.Failures, es.Avg, es.P99, es.Max, marker))
+	}
+	return sb.String()
+}
+
+// tCritical95 holds two-sided 95% Student's t critical values indexed by
+// degrees of freedom (1-30); larger samples use the normal approximation
+var tCritical95 = [...]float64{
+	0, 12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// meanCI95 returns the sample mean and the half-width of its 95% confidence
+// interval. The half-width is 0 for fewer than two samples.
+func meanCI95(values []float64) (mean, halfWidth float64) {
+	n := len(values)
+	if n == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(n)
+	if n < 2 {
+		return mean, 0
+	}
+
+	var sumSquares float64
+	for _, v := range values {
+		sumSquares += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(sumSquares / float64(n-1))
+
+	t := 1.960
+	if df := n - 1; df < len(tCritical95) {
+		t = tCritical95[df]
+	}
+	return mean, t * stddev / math.Sqrt(float64(n))
+}
+
+// IterationStats aggregates the repeated actual runs of one connection type at one concurrency level
+type IterationStats struct {
+	ConnectionType ConnectionType
+	Concurrency    int
+	Iterations     int
+	QPS            float64
+	QPSCI          float64 // 95% confidence interval half-width
+	P99            time.Duration
+	P99CI          time.Duration // 95% confidence interval half-width
+}
+
+// aggregateIterations groups actual runs by connection type and concurrency
+// level, in first-seen order, and computes mean ± 95% CI for QPS and p99
+func aggregateIterations(results []BenchmarkResult) []IterationStats {
+	type key struct {
+		connType    ConnectionType
+		concurrency int
+	}
+	groups := make(map[key][]BenchmarkResult)
+	var order []key
+	for _, r := range results {
+		if r.IsWarmup {
+			continue
+		}
+		k := key{r.ConnectionType, r.Concurrency}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], r)
+	}
+
+	stats := make([]IterationStats, 0, len(order))
+	for _, k := range order {
+		group := groups[k]
+		qps := make([]float64, len(group))
+		p99 := make([]float64, len(group))
+		for i, r := range group {
+			qps[i] = r.QueriesPerSecond
+			p99[i] = float64(r.P99AcquisitionTime)
+		}
+		qpsMean, qpsCI := meanCI95(qps)
+		p99Mean, p99CI := meanCI95(p99)
+		stats = append(stats, IterationStats{
+			ConnectionType: k.connType,
+			Concurrency:    k.concurrency,
+			Iterations:     len(group),
+			QPS:            qpsMean,
+			QPSCI:          qpsCI,
+			P99:            time.Duration(p99Mean),
+			P99CI:          time.Duration(p99CI),
+		})
+	}
+	return stats
+}
+
+// renderIterationSummary formats aggregateIterations as a text table, or
+// returns "" when no configuration was measured more than once
+func renderIterationSummary(results []BenchmarkResult) string {
+	stats := aggregateIterations(results)
+	repeated := false
+	for _, s := range stats {
+		if s.Iterations > 1 {
+			repeated = true
+			break
+		}
+	}
+	if !repeated {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-20s %11s %5s %24s %28s\n", "Connection Type", "Concurrency", "Runs", "QPS", "P99 Acquisition")
+	fmt.Fprintf(&b, "%s\n", strings.Repeat("-", 92))
+	for _, s := range stats {
+		fmt.Fprintf(&b, "%-20s %11d %5d %24s %28s\n",
+			s.ConnectionType, s.Concurrency, s.Iterations,
+			fmt.Sprintf("%.2f ± %.2f", s.QPS, s.QPSCI),
+			fmt.Sprintf("%v ± %v", s.P99.Round(time.Microsecond), s.P99CI.Round(time.Microsecond)))
+	}
+	return b.String()
+}
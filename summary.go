@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Exit codes, so scripts can tell how a benchmark went without parsing the
+// report. When several apply, the highest one wins.
+const (
+	ExitOK            = 0
+	ExitError         = 1 // Setup or runtime error, see fatal
+	ExitQueryFailures = 2 // Actual runs failed more queries than -max-failure-rate allows
+	ExitSLOBreach     = 3 // A connection type breached -slo
+	ExitRegression    = 4 // A metric regressed against -baseline
+	ExitWatchdog      = 5 // The -watchdog deadline passed, so the results are partial
+)
+
+// DefaultMaxFailureRate is the share of failed queries tolerated before the
+// exit status reports failures
+const DefaultMaxFailureRate = 0.01
+
+// RunOutcome sums up a whole benchmark invocation for the exit status and the
+// final SUMMARY line
+type RunOutcome struct {
+	OK          int
+	Failed      int
+	P99         map[ConnectionType]time.Duration // Over every actual run of the connection type
+	SLO         string                           // "pass" or "fail"; empty without -slo
+	Regressions int
+
+	// Whether the -watchdog deadline passed, and how many workers it abandoned
+	WatchdogExpired bool
+	Abandoned       int
+}
+
+// newRunOutcome counts the queries of the actual (non-warmup) runs
+func newRunOutcome(results []BenchmarkResult) RunOutcome {
+	outcome := RunOutcome{P99: make(map[ConnectionType]time.Duration)}
+	byType := make(map[ConnectionType][]time.Duration)
+	for _, r := range results {
+		outcome.Abandoned += r.AbandonedWorkers
+		if r.IsWarmup {
+			continue
+		}
+		failed := failedQueries(r)
+		outcome.Failed += failed
+		outcome.OK += len(r.AcquisitionTimes) - failed
+		byType[r.ConnectionType] = append(byType[r.ConnectionType], r.AcquisitionTimes...)
+	}
+	for connType, times := range byType {
+		outcome.P99[connType] = percentile(times, 99)
+	}
+	return outcome
+}
+
+// FailureRate is the share of the actual runs' queries that failed
+func (o RunOutcome) FailureRate() float64 {
+	if total := o.OK + o.Failed; total > 0 {
+		return float64(o.Failed) / float64(total)
+	}
+	return 0
+}
+
+// ExitCode picks the exit status for the outcome
+func (o RunOutcome) ExitCode(maxFailureRate float64) int {
+	switch {
+	case o.WatchdogExpired:
+		return ExitWatchdog
+	case o.Regressions > 0:
+		return ExitRegression
+	case o.SLO == "fail":
+		return ExitSLOBreach
+	case o.FailureRate() > maxFailureRate:
+		return ExitQueryFailures
+	default:
+		return ExitOK
+	}
+}
+
+// SummaryLine renders the outcome as space-separated key=value pairs, e.g.
+// "SUMMARY exit=0 ok=48500 failed=12 failure_rate=0.0002 p99_direct=12ms p99_session=30ms p99_transaction=41ms"
+func (o RunOutcome) SummaryLine(exitCode int) string {
+	fields := []string{
+		"SUMMARY",
+		fmt.Sprintf("exit=%d", exitCode),
+		fmt.Sprintf("ok=%d", o.OK),
+		fmt.Sprintf("failed=%d", o.Failed),
+		fmt.Sprintf("failure_rate=%.4f", o.FailureRate()),
+	}
+	for _, connType := range sortedConnTypes(o.P99) {
+		fields = append(fields, fmt.Sprintf("p99_%s=%v", summaryKey(connType), o.P99[connType].Round(time.Microsecond)))
+	}
+	if o.SLO != "" {
+		fields = append(fields, "slo="+o.SLO)
+	}
+	fields = append(fields, fmt.Sprintf("regressions=%d", o.Regressions))
+	if o.WatchdogExpired {
+		fields = append(fields, "watchdog=expired", fmt.Sprintf("abandoned=%d", o.Abandoned))
+	}
+	return strings.Join(fields, " ")
+}
+
+// summaryKey shortens a connection type for SUMMARY keys: direct, session or transaction
+func summaryKey(connType ConnectionType) string {
+	switch connType {
+	case DirectPostgres:
+		return "direct"
+	default:
+		return strings.TrimPrefix(string(connType), "pgbouncer-")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunOutcomeCountsActualRuns(t *testing.T) {
+	ms := time.Millisecond
+	results := []BenchmarkResult{
+		{ConnectionType: PgBouncerTransaction, IsWarmup: true, AcquisitionTimes: []time.Duration{0, 0, ms}},
+		{ConnectionType: PgBouncerTransaction, AcquisitionTimes: []time.Duration{ms, 0, 41 * ms}},
+		{ConnectionType: DirectPostgres, AcquisitionTimes: []time.Duration{2 * ms, 3 * ms}},
+	}
+
+	outcome := newRunOutcome(results)
+	if outcome.OK != 4 || outcome.Failed != 1 {
+		t.Errorf("ok=%d failed=%d, want ok=4 failed=1", outcome.OK, outcome.Failed)
+	}
+	if got := outcome.P99[PgBouncerTransaction]; got != 41*ms {
+		t.Errorf("transaction p99 = %v, want 41ms", got)
+	}
+
+	line := outcome.SummaryLine(ExitOK)
+	want := "SUMMARY exit=0 ok=4 failed=1 failure_rate=0.2000 p99_direct=3ms p99_transaction=41ms regressions=0"
+	if line != want {
+		t.Errorf("SummaryLine =\n  %q\nwant\n  %q", line, want)
+	}
+}
+
+func TestRunOutcomeExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome RunOutcome
+		want    int
+	}{
+		{"clean", RunOutcome{OK: 100}, ExitOK},
+		{"failures within tolerance", RunOutcome{OK: 100, Failed: 1}, ExitOK},
+		{"too many failures", RunOutcome{OK: 90, Failed: 10}, ExitQueryFailures},
+		{"slo passed", RunOutcome{OK: 100, SLO: "pass"}, ExitOK},
+		{"slo failed", RunOutcome{OK: 100, SLO: "fail"}, ExitSLOBreach},
+		{"slo failed with failures", RunOutcome{OK: 90, Failed: 10, SLO: "fail"}, ExitSLOBreach},
+		{"regression wins", RunOutcome{OK: 90, Failed: 10, SLO: "fail", Regressions: 2}, ExitRegression},
+		{"watchdog wins", RunOutcome{OK: 90, Failed: 10, Regressions: 2, WatchdogExpired: true}, ExitWatchdog},
+	}
+	for _, tt := range tests {
+		if got := tt.outcome.ExitCode(0.01); got != tt.want {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSummaryLineIncludesSLO(t *testing.T) {
+	line := RunOutcome{SLO: "fail"}.SummaryLine(ExitSLOBreach)
+	if !strings.Contains(line, " slo=fail ") || !strings.HasPrefix(line, "SUMMARY exit=3 ") {
+		t.Errorf("SummaryLine = %q", line)
+	}
+}
+
+func TestSummaryLineFlagsWatchdog(t *testing.T) {
+	outcome := newRunOutcome([]BenchmarkResult{{IsWarmup: true, AbandonedWorkers: 1}, {AbandonedWorkers: 2}})
+	if line := outcome.SummaryLine(ExitOK); strings.Contains(line, "watchdog") {
+		t.Errorf("SummaryLine = %q, want no watchdog fields before it expired", line)
+	}
+	outcome.WatchdogExpired = true
+	if line := outcome.SummaryLine(ExitWatchdog); !strings.HasSuffix(line, " watchdog=expired abandoned=3") {
+		t.Errorf("SummaryLine = %q, want the warmup's and actual run's abandoned workers", line)
+	}
+}
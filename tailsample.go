@@ -0,0 +1,147 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultSampledTraces is roughly how many worker traces per run the automatic
+// -trace-sample-ratio keeps: runs up to this concurrency keep every trace
+const DefaultSampledTraces = 1000
+
+// autoSampleRatio is the sampling ratio keeping about DefaultSampledTraces
+// traces per burst run at the highest concurrency level
+func autoSampleRatio(concurrencyLevels []int) float64 {
+	highest := 0
+	for _, level := range concurrencyLevels {
+		highest = max(highest, level)
+	}
+	if highest <= DefaultSampledTraces {
+		return 1
+	}
+	return float64(DefaultSampledTraces) / float64(highest)
+}
+
+// tailSampler is a span processor that holds each trace's spans until its root
+// span ends, then passes the whole trace on to next if the ratio sampler picks
+// its trace ID or its root is among the keep slowest seen so far for its
+// connection type. Decisions can't be taken back, so a trace kept early may
+// later be outranked; at least the keep slowest of each type always survive.
+// Sampling by ratio alone would mostly drop the slow tail that the
+// slowest-trace export is after.
+type tailSampler struct {
+	next  []sdktrace.SpanProcessor
+	ratio sdktrace.Sampler
+	keep  int
+
+	mu      sync.Mutex
+	pending map[trace.TraceID][]sdktrace.ReadOnlySpan
+	slowest map[ConnectionType]*durationHeap
+}
+
+// newTailSampler keeps a ratio of traces plus the keep slowest ones, passing
+// them to next
+func newTailSampler(ratio float64, keep int, next ...sdktrace.SpanProcessor) *tailSampler {
+	return &tailSampler{
+		next:    next,
+		ratio:   sdktrace.TraceIDRatioBased(ratio),
+		keep:    keep,
+		pending: make(map[trace.TraceID][]sdktrace.ReadOnlySpan),
+		slowest: make(map[ConnectionType]*durationHeap),
+	}
+}
+
+func (s *tailSampler) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd buffers span, deciding the fate of its trace once the root span ends
+func (s *tailSampler) OnEnd(span sdktrace.ReadOnlySpan) {
+	traceID := span.SpanContext().TraceID()
+
+	s.mu.Lock()
+	if span.Parent().IsValid() {
+		s.pending[traceID] = append(s.pending[traceID], span)
+		s.mu.Unlock()
+		return
+	}
+	spans := append(s.pending[traceID], span)
+	delete(s.pending, traceID)
+	keep := s.keepSlow(span) || s.sampled(traceID)
+	s.mu.Unlock()
+
+	if !keep {
+		return
+	}
+	for _, next := range s.next {
+		for _, span := range spans {
+			next.OnEnd(span)
+		}
+	}
+}
+
+// sampled reports whether the ratio sampler picks traceID
+func (s *tailSampler) sampled(traceID trace.TraceID) bool {
+	decision := s.ratio.ShouldSample(sdktrace.SamplingParameters{TraceID: traceID})
+	return decision.Decision == sdktrace.RecordAndSample
+}
+
+// keepSlow reports whether root is among the keep slowest roots of its
+// connection type so far, and records it if so. Must be called with s.mu held.
+func (s *tailSampler) keepSlow(root sdktrace.ReadOnlySpan) bool {
+	if s.keep <= 0 {
+		return false
+	}
+	connType, _ := traceConnType([]sdktrace.ReadOnlySpan{root})
+	slowest := s.slowest[connType]
+	if slowest == nil {
+		slowest = &durationHeap{}
+		s.slowest[connType] = slowest
+	}
+
+	duration := root.EndTime().Sub(root.StartTime())
+	if slowest.Len() < s.keep {
+		heap.Push(slowest, duration)
+		return true
+	}
+	if duration <= (*slowest)[0] {
+		return false
+	}
+	(*slowest)[0] = duration
+	heap.Fix(slowest, 0)
+	return true
+}
+
+func (s *tailSampler) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, next := range s.next {
+		errs = append(errs, next.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *tailSampler) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, next := range s.next {
+		errs = append(errs, next.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// durationHeap is a min-heap of durations
+type durationHeap []time.Duration
+
+func (h durationHeap) Len() int           { return len(h) }
+func (h durationHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h durationHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *durationHeap) Push(x any)        { *h = append(*h, x.(time.Duration)) }
+func (h *durationHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordTrace ends a worker.request root with one child, lasting duration
+func recordTrace(tracer trace.Tracer, connType ConnectionType, duration time.Duration) trace.TraceID {
+	start := time.Now()
+	ctx, root := tracer.Start(context.Background(), RootSpanName,
+		trace.WithTimestamp(start), trace.WithAttributes(AttrConnType.String(string(connType))))
+	_, query := tracer.Start(ctx, "db.query", trace.WithTimestamp(start))
+	query.End(trace.WithTimestamp(start.Add(duration / 2)))
+	root.End(trace.WithTimestamp(start.Add(duration)))
+	return root.SpanContext().TraceID()
+}
+
+func TestTailSamplerKeepsSlowestPerConnType(t *testing.T) {
+	collector := NewTraceCollector()
+	// A ratio this small samples essentially nothing by trace ID
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(
+		newTailSampler(1e-12, 2, sdktrace.NewSimpleSpanProcessor(collector))))
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	// Decisions are made as roots end, so faster traces after the two slowest are dropped
+	for _, ms := range []int{30, 20, 5, 10, 1} {
+		recordTrace(tracer, PgBouncerSession, time.Duration(ms)*time.Millisecond)
+	}
+	recordTrace(tracer, PgBouncerTransaction, time.Millisecond)
+
+	kept := FindSlowestTraces(collector, 10)
+	if len(kept) != 3 {
+		t.Fatalf("kept %d traces, want 3", len(kept))
+	}
+	for _, traceInfo := range kept {
+		if len(traceInfo.Spans) != 2 {
+			t.Errorf("trace %s kept %d spans, want 2", traceInfo.TraceID, len(traceInfo.Spans))
+		}
+	}
+	if kept[0].Duration != 30*time.Millisecond || kept[1].Duration != 20*time.Millisecond {
+		t.Errorf("slowest kept = %v, %v, want 30ms, 20ms", kept[0].Duration, kept[1].Duration)
+	}
+}
+
+func TestTailSamplerRatioOnly(t *testing.T) {
+	collector := NewTraceCollector()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(
+		newTailSampler(1e-12, 0, sdktrace.NewSimpleSpanProcessor(collector))))
+	defer tp.Shutdown(context.Background())
+
+	recordTrace(tp.Tracer("test"), PgBouncerSession, time.Second)
+	if spans := collector.GetSpans(); len(spans) != 0 {
+		t.Errorf("kept %d spans with no slowest to keep, want 0", len(spans))
+	}
+}
+
+func TestAutoSampleRatio(t *testing.T) {
+	tests := []struct {
+		levels []int
+		want   float64
+	}{
+		{[]int{100, 1000}, 1},
+		{[]int{2000, 500}, 0.5},
+		{[]int{10000}, 0.1},
+	}
+	for _, tt := range tests {
+		if got := autoSampleRatio(tt.levels); got != tt.want {
+			t.Errorf("autoSampleRatio(%v) = %v, want %v", tt.levels, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TimelineInterval is the width of each bucket of a run's timeline
+const TimelineInterval = time.Second
+
+// TimelineBucket is one interval of a run's timeline: the queries that
+// completed in it, counted the same way as the run's overall QPS
+type TimelineBucket struct {
+	Start      time.Duration `json:"start_ns"` // Offset from the run start
+	Queries    int           `json:"queries"`
+	Errors     int           `json:"errors,omitempty"`
+	QPS        float64       `json:"qps"`
+	AvgLatency time.Duration `json:"avg_latency_ns"` // Mean of the bucket's successful queries
+}
+
+// buildTimeline buckets queries by completion offset into interval-wide
+// windows covering total. times holds each query's duration, 0 for a failure,
+// parallel to offsets. The last bucket's QPS only counts the part of it the
+// run covered. Empty buckets are kept, so stalls show up as zero QPS.
+func buildTimeline(offsets, times []time.Duration, interval, total time.Duration) []TimelineBucket {
+	if interval <= 0 || total <= 0 {
+		return nil
+	}
+	buckets := make([]TimelineBucket, int((total+interval-1)/interval))
+	latencySums := make([]time.Duration, len(buckets))
+	for i := range buckets {
+		buckets[i].Start = time.Duration(i) * interval
+	}
+
+	for i, offset := range offsets {
+		b := min(max(int(offset/interval), 0), len(buckets)-1)
+		buckets[b].Queries++
+		if times[i] == 0 {
+			buckets[b].Errors++
+			continue
+		}
+		latencySums[b] += times[i]
+	}
+
+	for i := range buckets {
+		width := min(interval, total-buckets[i].Start)
+		buckets[i].QPS = float64(buckets[i].Queries) / width.Seconds()
+		if succeeded := buckets[i].Queries - buckets[i].Errors; succeeded > 0 {
+			buckets[i].AvgLatency = latencySums[i] / time.Duration(succeeded)
+		}
+	}
+	return buckets
+}
+
+// TimelineSummary condenses a timeline to the numbers the text report shows
+type TimelineSummary struct {
+	PeakQPS   float64
+	PeakAt    time.Duration // Start of the peak bucket
+	SteadyQPS float64       // Median QPS past the first bucket, where the run settles
+	Trend     float64       // Mean QPS of the last third relative to the first, e.g. -0.2 = 20% lower
+}
+
+// summarizeTimeline summarizes the whole buckets of a timeline; a last bucket
+// shorter than half an interval is too noisy to count. It returns false with
+// fewer than three whole buckets, too short a run to show a trend.
+func summarizeTimeline(buckets []TimelineBucket, total time.Duration) (TimelineSummary, bool) {
+	if n := len(buckets); n > 0 && total-buckets[n-1].Start < TimelineInterval/2 {
+		buckets = buckets[:n-1]
+	}
+	if len(buckets) < 3 {
+		return TimelineSummary{}, false
+	}
+
+	var s TimelineSummary
+	for _, b := range buckets {
+		if b.QPS > s.PeakQPS {
+			s.PeakQPS, s.PeakAt = b.QPS, b.Start
+		}
+	}
+
+	settled := make([]float64, 0, len(buckets)-1)
+	for _, b := range buckets[1:] {
+		settled = append(settled, b.QPS)
+	}
+	sort.Float64s(settled)
+	if mid := len(settled) / 2; len(settled)%2 == 0 {
+		s.SteadyQPS = (settled[mid-1] + settled[mid]) / 2
+	} else {
+		s.SteadyQPS = settled[mid]
+	}
+
+	third := len(buckets) / 3
+	first, last := meanQPS(buckets[:third]), meanQPS(buckets[len(buckets)-third:])
+	if first > 0 {
+		s.Trend = last/first - 1
+	}
+	return s, true
+}
+
+func meanQPS(buckets []TimelineBucket) float64 {
+	if len(buckets) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, b := range buckets {
+		sum += b.QPS
+	}
+	return sum / float64(len(buckets))
+}
+
+// formatTimelineSummary renders a timeline summary for the report, or "" when
+// the run was too short for one
+func formatTimelineSummary(r BenchmarkResult) string {
+	s, ok := summarizeTimeline(r.Timeline, r.TotalDuration)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("peak %.2f QPS at %v, steady %.2f QPS, last third vs first %+.1f%%",
+		s.PeakQPS, s.PeakAt, s.SteadyQPS, s.Trend*100)
+}
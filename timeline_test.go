@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildTimelineBucketsBySecond(t *testing.T) {
+	ms := time.Millisecond
+	offsets := []time.Duration{100 * ms, 900 * ms, 1500 * ms, 1600 * ms, 3200 * ms, 3300 * ms}
+	times := []time.Duration{10 * ms, 30 * ms, 0, 40 * ms, 5 * ms, 15 * ms}
+
+	got := buildTimeline(offsets, times, time.Second, 3500*ms)
+	if len(got) != 4 {
+		t.Fatalf("got %d buckets, want 4", len(got))
+	}
+
+	want := []TimelineBucket{
+		{Start: 0, Queries: 2, QPS: 2, AvgLatency: 20 * ms},
+		{Start: time.Second, Queries: 2, Errors: 1, QPS: 2, AvgLatency: 40 * ms},
+		{Start: 2 * time.Second},                                          // A stall stays in the series
+		{Start: 3 * time.Second, Queries: 2, QPS: 4, AvgLatency: 10 * ms}, // Half a bucket
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBuildTimelineEmptyRun(t *testing.T) {
+	if got := buildTimeline(nil, nil, time.Second, 0); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
+
+func TestSummarizeTimeline(t *testing.T) {
+	qps := []float64{100, 400, 500, 300, 200, 200, 50}
+	buckets := make([]TimelineBucket, len(qps))
+	for i, q := range qps {
+		buckets[i] = TimelineBucket{Start: time.Duration(i) * time.Second, QPS: q}
+	}
+
+	// The last bucket covers 200ms of the run, too little to count
+	s, ok := summarizeTimeline(buckets, 6200*time.Millisecond)
+	if !ok {
+		t.Fatal("expected a summary")
+	}
+	if s.PeakQPS != 500 || s.PeakAt != 2*time.Second {
+		t.Errorf("peak = %.0f at %v, want 500 at 2s", s.PeakQPS, s.PeakAt)
+	}
+	if s.SteadyQPS != 300 {
+		t.Errorf("steady = %.0f, want 300 (median of 400,500,300,200,200)", s.SteadyQPS)
+	}
+	// First third 100,400 (mean 250), last third 200,200
+	if math.Abs(s.Trend+0.2) > 1e-9 {
+		t.Errorf("trend = %v, want -0.2", s.Trend)
+	}
+}
+
+func TestSummarizeTimelineTooShort(t *testing.T) {
+	buckets := []TimelineBucket{{QPS: 10}, {Start: time.Second, QPS: 20}}
+	if _, ok := summarizeTimeline(buckets, 2*time.Second); ok {
+		t.Error("two buckets shouldn't be summarized")
+	}
+	if got := formatTimelineSummary(BenchmarkResult{Timeline: buckets, TotalDuration: 2 * time.Second}); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func TestFormatTimelineSummary(t *testing.T) {
+	r := BenchmarkResult{TotalDuration: 3 * time.Second, Timeline: []TimelineBucket{
+		{QPS: 100}, {Start: time.Second, QPS: 200}, {Start: 2 * time.Second, QPS: 150},
+	}}
+	got := formatTimelineSummary(r)
+	if !strings.Contains(got, "peak 200.00 QPS at 1s") || !strings.Contains(got, "+50.0%") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSaveResultsKeepsTimeline(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.json")
+	results := []BenchmarkResult{{ConnectionType: PgBouncerTransaction, Concurrency: 10,
+		Timeline: []TimelineBucket{{Queries: 5, QPS: 5, AvgLatency: time.Millisecond}}}}
+	if err := SaveResults(results, DefaultPoolTuning(), filename); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := LoadBaseline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || len(saved[0].Timeline) != 1 || saved[0].Timeline[0].AvgLatency != time.Millisecond {
+		t.Errorf("timeline lost in round trip: %+v", saved)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TLSOptions overrides the TLS settings parsed from a DSN
+type TLSOptions struct {
+	SSLMode    string // disable, require, verify-ca or verify-full; empty keeps the DSN's sslmode
+	CAFile     string // PEM bundle of CAs trusted for verify-ca and verify-full
+	ServerName string // Name to verify in verify-full; defaults to the DSN host
+}
+
+// applyTLS replaces the TLS configuration of a parsed connection config. Plaintext
+// fallbacks are dropped so a TLS failure is reported rather than silently downgraded.
+func applyTLS(connConfig *pgconn.Config, opts TLSOptions) error {
+	if opts.SSLMode == "" {
+		return nil
+	}
+
+	connConfig.Fallbacks = nil
+	if opts.SSLMode == "disable" {
+		connConfig.TLSConfig = nil
+		return nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: connConfig.Host}
+	if opts.ServerName != "" {
+		tlsConfig.ServerName = opts.ServerName
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA bundle %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	switch opts.SSLMode {
+	case "require":
+		tlsConfig.InsecureSkipVerify = true
+	case "verify-ca":
+		// Verify the chain but not the host name, like libpq's verify-ca
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChainOnly(tlsConfig.RootCAs)
+	case "verify-full":
+	default:
+		return fmt.Errorf("unsupported sslmode %q", opts.SSLMode)
+	}
+
+	connConfig.TLSConfig = tlsConfig
+	return nil
+}
+
+// verifyChainOnly returns a certificate check that validates the server chain
+// against roots (or the system pool when nil) without checking the host name
+func verifyChainOnly(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server presented no certificate")
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse server certificate: %w", err)
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestApplyTLSModes(t *testing.T) {
+	parse := func() *pgconn.Config {
+		cfg, err := pgconn.ParseConfig("postgres://u:p@db.example.com:6432/benchdb?sslmode=prefer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	cfg := parse()
+	if err := applyTLS(cfg, TLSOptions{}); err != nil || len(cfg.Fallbacks) == 0 {
+		t.Fatalf("empty sslmode should leave the DSN's settings alone (err=%v)", err)
+	}
+
+	cfg = parse()
+	if err := applyTLS(cfg, TLSOptions{SSLMode: "disable"}); err != nil || cfg.TLSConfig != nil || cfg.Fallbacks != nil {
+		t.Errorf("disable: err=%v tls=%v fallbacks=%d", err, cfg.TLSConfig, len(cfg.Fallbacks))
+	}
+
+	cfg = parse()
+	if err := applyTLS(cfg, TLSOptions{SSLMode: "require"}); err != nil || cfg.TLSConfig == nil || !cfg.TLSConfig.InsecureSkipVerify {
+		t.Errorf("require should skip verification: err=%v", err)
+	}
+
+	cfg = parse()
+	if err := applyTLS(cfg, TLSOptions{SSLMode: "verify-full"}); err != nil || cfg.TLSConfig.InsecureSkipVerify || cfg.TLSConfig.ServerName != "db.example.com" {
+		t.Errorf("verify-full should verify the DSN host: err=%v", err)
+	}
+
+	cfg = parse()
+	if err := applyTLS(cfg, TLSOptions{SSLMode: "verify-ca"}); err != nil || cfg.TLSConfig.VerifyPeerCertificate == nil {
+		t.Errorf("verify-ca should verify the chain: err=%v", err)
+	}
+}
+
+func TestApplyTLSRejectsBadCABundle(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := pgconn.ParseConfig("postgres://u:p@localhost:6432/benchdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyTLS(cfg, TLSOptions{SSLMode: "verify-full", CAFile: caFile}); err == nil {
+		t.Error("expected an error for a CA bundle without certificates")
+	}
+}
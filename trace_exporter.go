@@ -2,22 +2,109 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // TraceInfo holds information about a trace for sorting
 type TraceInfo struct {
-	TraceID  trace.TraceID
-	Duration time.Duration
-	Spans    []sdktrace.ReadOnlySpan
+	TraceID      trace.TraceID
+	Duration     time.Duration // Wall-clock duration of the root span
+	CriticalPath time.Duration // Longest chain of non-overlapping work through the span tree
+	Spans        []sdktrace.ReadOnlySpan
+}
+
+// RootSpanName is the span that wraps a whole worker request
+const RootSpanName = "worker.request"
+
+// Attribute keys identifying which server and pooling mode a request targeted
+const (
+	AttrConnType = attribute.Key("conn_type")
+	AttrPoolMode = attribute.Key("pgbouncer.pool_mode")
+)
+
+// targetAttributeKeys are the root span attributes promoted to the resource of exported batches
+var targetAttributeKeys = map[attribute.Key]bool{
+	AttrConnType:             true,
+	AttrPoolMode:             true,
+	semconv.ServerAddressKey: true,
+	semconv.ServerPortKey:    true,
+}
+
+// targetAttributes describes the server a configuration points at: its connection
+// type, PgBouncer pool mode (empty for direct PostgreSQL), host and port
+func targetAttributes(config Config) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{AttrConnType.String(string(config.ConnType))}
+
+	switch config.ConnType {
+	case PgBouncerSession, PgBouncerSessionSQL:
+		attrs = append(attrs, AttrPoolMode.String("session"))
+	case PgBouncerTransaction, PgBouncerTransactionSQL:
+		attrs = append(attrs, AttrPoolMode.String("transaction"))
+	}
+
+	if connConfig, err := pgconn.ParseConfig(config.DSN); err == nil {
+		attrs = append(attrs, semconv.ServerAddress(connConfig.Host), semconv.ServerPort(int(connConfig.Port)))
+	}
+	return attrs
+}
+
+// traceTargetAttributes returns the target attributes recorded on a trace's spans
+func traceTargetAttributes(spans []sdktrace.ReadOnlySpan) []attribute.KeyValue {
+	seen := make(map[attribute.Key]bool)
+	var attrs []attribute.KeyValue
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			if targetAttributeKeys[attr.Key] && !seen[attr.Key] {
+				seen[attr.Key] = true
+				attrs = append(attrs, attr)
+			}
+		}
+	}
+	return attrs
 }
 
 // FindSlowestTraces identifies the N slowest traces from collected spans
 func FindSlowestTraces(collector *TraceCollector, n int) []TraceInfo {
+	return FindSlowestTracesByRoot(collector, RootSpanName, n)
+}
+
+// FindSlowestTracesByRoot identifies the N slowest traces, timing each trace by
+// the span named rootSpanName. Traces without such a span are timed from their
+// earliest span start to their latest span end.
+func FindSlowestTracesByRoot(collector *TraceCollector, rootSpanName string, n int) []TraceInfo {
+	return FindSlowestTracesSorted(collector, rootSpanName, TraceSortWall, n)
+}
+
+// FindSlowestTracesSorted identifies the N slowest traces, ranked by wall-clock
+// duration (TraceSortWall) or critical path (TraceSortCriticalPath). Both
+// durations are filled in either way.
+func FindSlowestTracesSorted(collector *TraceCollector, rootSpanName, sortBy string, n int) []TraceInfo {
+	return findSlowestTraces(collector, rootSpanName, sortBy, n, func([]sdktrace.ReadOnlySpan) bool { return true })
+}
+
+// FindSlowestTracesOfType is FindSlowestTracesSorted over the traces of
+// connType alone. Traces without a conn_type attribute are included, since
+// they can't be attributed.
+func FindSlowestTracesOfType(collector *TraceCollector, connType ConnectionType, sortBy string, n int) []TraceInfo {
+	return findSlowestTraces(collector, RootSpanName, sortBy, n, func(spans []sdktrace.ReadOnlySpan) bool {
+		traceType, ok := traceConnType(spans)
+		return !ok || traceType == connType
+	})
+}
+
+// findSlowestTraces ranks the traces for which keep returns true, leaving the
+// others out before their durations are computed and sorted
+func findSlowestTraces(collector *TraceCollector, rootSpanName, sortBy string, n int, keep func([]sdktrace.ReadOnlySpan) bool) []TraceInfo {
 	allSpans := collector.GetSpans()
 
 	// Group spans by trace ID
@@ -27,34 +114,28 @@ func FindSlowestTraces(collector *TraceCollector, n int) []TraceInfo {
 		traceMap[traceID] = append(traceMap[traceID], span)
 	}
 
-	// Calculate duration for each trace (using root span duration)
+	// Calculate duration for each trace
 	traces := make([]TraceInfo, 0, len(traceMap))
 	for traceID, spans := range traceMap {
-		// Find the root span (worker.request span) to get total duration
-		var maxDuration time.Duration
-		for _, span := range spans {
-			if span.Name() == "worker.request" {
-				duration := span.EndTime().Sub(span.StartTime())
-				if duration > maxDuration {
-					maxDuration = duration
-				}
-			}
+		if !keep(spans) {
+			continue
 		}
-
 		traces = append(traces, TraceInfo{
-			TraceID:  traceID,
-			Duration: maxDuration,
-			Spans:    spans,
+			TraceID:      traceID,
+			Duration:     traceDuration(spans, rootSpanName),
+			CriticalPath: criticalPath(spans, rootSpanName),
+			Spans:        spans,
 		})
 	}
 
-	// Sort by duration (slowest first)
+	// Sort by the chosen duration (slowest first)
 	sort.Slice(traces, func(i, j int) bool {
+		if sortBy == TraceSortCriticalPath {
+			return traces[i].CriticalPath > traces[j].CriticalPath
+		}
 		return traces[i].Duration > traces[j].Duration
 	})
 
-	fmt.Printf("Debug: Found %d total traces, returning top %d\n", len(traces), n)
-
 	// Return top N
 	if len(traces) > n {
 		traces = traces[:n]
@@ -63,37 +144,140 @@ func FindSlowestTraces(collector *TraceCollector, n int) []TraceInfo {
 	return traces
 }
 
-// ExportSlowestTraces exports the slowest traces to a single JSON file
-func ExportSlowestTraces(collector *TraceCollector, connType ConnectionType, numToExport int) error {
-	slowestTraces := FindSlowestTraces(collector, numToExport)
+// traceDuration returns the duration of the longest span named rootSpanName,
+// or the overall extent of the spans when none of them has that name
+func traceDuration(spans []sdktrace.ReadOnlySpan, rootSpanName string) time.Duration {
+	var rootDuration time.Duration
+	var foundRoot bool
+	var earliestStart, latestEnd time.Time
+
+	for _, span := range spans {
+		if span.Name() == rootSpanName {
+			foundRoot = true
+			if duration := span.EndTime().Sub(span.StartTime()); duration > rootDuration {
+				rootDuration = duration
+			}
+		}
+
+		if earliestStart.IsZero() || span.StartTime().Before(earliestStart) {
+			earliestStart = span.StartTime()
+		}
+		if span.EndTime().After(latestEnd) {
+			latestEnd = span.EndTime()
+		}
+	}
+
+	if foundRoot {
+		return rootDuration
+	}
+	return latestEnd.Sub(earliestStart)
+}
+
+// traceConnType returns the conn_type attribute recorded on a trace's spans
+func traceConnType(spans []sdktrace.ReadOnlySpan) (ConnectionType, bool) {
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			if attr.Key == AttrConnType {
+				return ConnectionType(attr.Value.AsString()), true
+			}
+		}
+	}
+	return "", false
+}
+
+// LargeTraceFileBytes is the size above which an exported trace file gets a
+// warning, since trace viewers tend to struggle with files this big
+const LargeTraceFileBytes = 10 << 20
+
+// TraceExportOptions controls how ExportSlowestTraces ranks and writes traces
+type TraceExportOptions struct {
+	Count    int    // How many of the slowest traces to export
+	SortBy   string // TraceSortWall or TraceSortCriticalPath
+	OutDir   string // Base output directory; see outputPath
+	PerTrace bool   // One file per trace instead of a single combined file
+	Format   string // TraceFormatLegacy (the default when empty) or TraceFormatOTLP
+}
+
+// ExportSlowestTraces exports the slowest traces of connType to JSON in its
+// output directory: one combined file, or one file per trace with PerTrace
+func ExportSlowestTraces(collector *TraceCollector, connType ConnectionType, exportOpts TraceExportOptions) error {
+	numToExport, sortBy, outdir := exportOpts.Count, exportOpts.SortBy, exportOpts.OutDir
+
+	slowestTraces := FindSlowestTracesOfType(collector, connType, sortBy, numToExport)
 
 	if len(slowestTraces) == 0 {
 		return fmt.Errorf("no traces found to export")
 	}
 
 	fmt.Printf("\nExporting %d slowest traces for %s...\n", len(slowestTraces), connType)
+	if dropped := collector.DroppedSpans(); dropped > 0 {
+		fmt.Printf("  ⚠ Span cap of %d reached: %d spans were dropped, slowest traces may be incomplete\n",
+			collector.MaxSpans(), dropped)
+	}
 
-	// Collect all spans from all slowest traces
-	allSpans := make([]sdktrace.ReadOnlySpan, 0)
-	for _, traceInfo := range slowestTraces {
-		allSpans = append(allSpans, traceInfo.Spans...)
+	// Name of the combined file; per-trace files are named by trace instead
+	now := time.Now()
+	filename, err := outputPath(outdir, connType, exportFilename("trace_slowest", connType, fmt.Sprintf("top%d", len(slowestTraces)), now, ".json"))
+	if err != nil {
+		return err
 	}
 
-	// Create single filename for all traces
-	timestamp := time.Now().Format("20060102150405")
-	filename := fmt.Sprintf("trace_slowest_%s_top%d_%s.json", connType, len(slowestTraces), timestamp)
+	if exportOpts.PerTrace {
+		for _, traceInfo := range slowestTraces {
+			traceFilename, err := outputPath(outdir, connType, perTraceFilename(connType, traceInfo, now))
+			if err != nil {
+				return err
+			}
+			if err := exportTraces(exportOpts.Format, collector.guard, [][]sdktrace.ReadOnlySpan{traceInfo.Spans}, traceFilename); err != nil {
+				return fmt.Errorf("failed to export trace %s: %w", traceInfo.TraceID, err)
+			}
+			warnIfLargeTrace(traceFilename, len(traceInfo.Spans))
+		}
+		fmt.Printf("  ✓ Exported %d traces to trace_%s_<id>_<duration>_<timestamp>.json files in %s\n", len(slowestTraces), connType, filepath.Dir(filename))
+	} else {
+		// Keep each trace's spans together so they export as separate batches
+		traceSpans := make([][]sdktrace.ReadOnlySpan, 0, len(slowestTraces))
+		spanCount := 0
+		for _, traceInfo := range slowestTraces {
+			traceSpans = append(traceSpans, traceInfo.Spans)
+			spanCount += len(traceInfo.Spans)
+		}
 
-	err := ExportTraceToJSON(allSpans, filename)
-	if err != nil {
-		return fmt.Errorf("failed to export traces: %w", err)
+		if err := exportTraces(exportOpts.Format, collector.guard, traceSpans, filename); err != nil {
+			return fmt.Errorf("failed to export traces: %w", err)
+		}
+		warnIfLargeTrace(filename, spanCount)
+		fmt.Printf("  ✓ Exported %d traces to %s\n", len(slowestTraces), filename)
+	}
+
+	// The same traces as folded stacks, for a local flamegraph
+	foldedFilename := strings.TrimSuffix(filename, ".json") + ".folded"
+	if err := ExportFoldedStacks(slowestTraces, foldedFilename); err != nil {
+		return fmt.Errorf("failed to export folded stacks: %w", err)
 	}
 
 	// Show summary of exported traces
-	fmt.Printf("  ✓ Exported %d traces to %s\n", len(slowestTraces), filename)
-	fmt.Printf("  Top %d slowest durations:\n", numToExport)
+	fmt.Printf("  ✓ Folded stacks for flamegraphs in %s\n", foldedFilename)
+	fmt.Printf("  Top %d slowest durations (by %s):\n", numToExport, sortBy)
 	for i := 0; i < numToExport && i < len(slowestTraces); i++ {
-		fmt.Printf("    %d. %v\n", i+1, slowestTraces[i].Duration)
+		fmt.Printf("    %d. %v wall, %v critical path; %s\n", i+1, slowestTraces[i].Duration, slowestTraces[i].CriticalPath,
+			diagnoseTrace(slowestTraces[i].Spans))
 	}
 
 	return nil
 }
+
+// perTraceFilename names a single trace's file by its ID and wall-clock duration
+func perTraceFilename(connType ConnectionType, traceInfo TraceInfo, now time.Time) string {
+	return exportFilename("trace", connType, fmt.Sprintf("%s_%dus", traceInfo.TraceID, traceInfo.Duration.Microseconds()), now, ".json")
+}
+
+// warnIfLargeTrace warns when an exported file is big enough to trouble trace viewers
+func warnIfLargeTrace(filename string, spans int) {
+	info, err := os.Stat(filename)
+	if err != nil || info.Size() <= LargeTraceFileBytes {
+		return
+	}
+	fmt.Printf("  ⚠ %s is %.1f MB (%d spans); some trace viewers may refuse or struggle to load it\n",
+		filename, float64(info.Size())/(1<<20), spans)
+}
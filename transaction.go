@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerUpdate is the write of each worker transaction. It rewrites a row with
+// its own value, so committing leaves the data unchanged but still takes the row lock.
+const WorkerUpdate = "UPDATE benchmark_data SET name = name WHERE id = $1"
+
+// TxOutcomes counts how worker transactions ended. It is safe for concurrent
+// use; a nil value ignores additions.
+type TxOutcomes struct {
+	commits   atomic.Int64
+	rollbacks atomic.Int64
+}
+
+func (o *TxOutcomes) addCommit() {
+	if o != nil {
+		o.commits.Add(1)
+	}
+}
+
+func (o *TxOutcomes) addRollback() {
+	if o != nil {
+		o.rollbacks.Add(1)
+	}
+}
+
+// Commits returns the number of committed transactions
+func (o *TxOutcomes) Commits() int64 {
+	if o == nil {
+		return 0
+	}
+	return o.commits.Load()
+}
+
+// Rollbacks returns the number of rolled back transactions, deliberate or after an error
+func (o *TxOutcomes) Rollbacks() int64 {
+	if o == nil {
+		return 0
+	}
+	return o.rollbacks.Load()
+}
+
+// runWorkerTransaction runs BEGIN, WorkerQuery, WorkerUpdate and COMMIT on conn,
+// rolling back instead with probability cfg.RollbackRatio. Under transaction-mode
+// PgBouncer the whole transaction is pinned to one server connection. It returns
+// the backend PID that served the transaction and when it ended; a failed
+// statement rolls the transaction back.
+func runWorkerTransaction(ctx context.Context, conn PooledConn, workerLog *slog.Logger, cfg WorkerConfig) (uint32, time.Time, error) {
+	// The UPDATE rewrites the first row the query selects
+	sql, args := workerQuery(cfg)
+	id := args[0]
+
+	// Span: BEGIN
+	_, beginSpan := cfg.Tracer.Start(ctx, "db.begin")
+	tx, err := conn.Begin(ctx)
+	beginSpan.End()
+	if err != nil {
+		return 0, time.Now(), fmt.Errorf("begin: %w", err)
+	}
+
+	fail := func(err error) (uint32, time.Time, error) {
+		// A rollback error only hides the statement error that caused it
+		_ = tx.Rollback(ctx)
+		cfg.TxOutcomes.addRollback()
+		return 0, time.Now(), err
+	}
+
+	// Span: Query execution inside the transaction
+	_, querySpan := cfg.Tracer.Start(ctx, "db.query")
+	rows, err := tx.Query(ctx, sql, args...)
+	querySpan.End()
+	if err != nil {
+		return fail(fmt.Errorf("query: %w", err))
+	}
+
+	// Span: Row scanning
+	_, scanSpan := cfg.Tracer.Start(ctx, "db.scan")
+	_, servedBy, err := streamWorkerRows(rows, workerLog, cfg)
+	rows.Close()
+	if err != nil {
+		scanSpan.RecordError(err)
+	}
+	scanSpan.End()
+	if err != nil {
+		return fail(err)
+	}
+
+	// Span: Write inside the transaction
+	_, updateSpan := cfg.Tracer.Start(ctx, "db.update")
+	_, err = tx.Exec(ctx, WorkerUpdate, id)
+	updateSpan.End()
+	if err != nil {
+		return fail(fmt.Errorf("update: %w", err))
+	}
+
+	// Deliberately roll back some transactions to exercise that path too
+	if cfg.Rand.Float64() < cfg.RollbackRatio {
+		_, rollbackSpan := cfg.Tracer.Start(ctx, "db.rollback")
+		err = tx.Rollback(ctx)
+		rollbackSpan.End()
+		if err != nil {
+			return 0, time.Now(), fmt.Errorf("rollback: %w", err)
+		}
+		cfg.TxOutcomes.addRollback()
+		return servedBy, time.Now(), nil
+	}
+
+	_, commitSpan := cfg.Tracer.Start(ctx, "db.commit")
+	err = tx.Commit(ctx)
+	commitSpan.End()
+	if err != nil {
+		cfg.TxOutcomes.addRollback()
+		return 0, time.Now(), fmt.Errorf("commit: %w", err)
+	}
+	cfg.TxOutcomes.addCommit()
+	return servedBy, time.Now(), nil
+}
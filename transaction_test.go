@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeTx is a pgx.Tx over a fakeConn that records how it ended. Methods the
+// worker doesn't use are left to the nil embedded interface.
+type fakeTx struct {
+	pgx.Tx
+	conn    *fakeConn
+	execErr error
+	ended   string
+}
+
+func (tx *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return tx.conn.Query(ctx, sql, args...)
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("UPDATE 1"), tx.execErr
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.ended = "commit"
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.ended = "rollback"
+	return nil
+}
+
+func TestExecuteWorkerQueryTransactions(t *testing.T) {
+	tests := []struct {
+		name          string
+		rollbackRatio float64
+		wantCommits   int64
+		wantRollbacks int64
+	}{
+		{"all commit", 0, 4, 0},
+		{"all roll back", 1, 0, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newFakePooler(0)
+			cfg := testWorkerConfig()
+			cfg.Transactions = true
+			cfg.RollbackRatio = tt.rollbackRatio
+			cfg.TxOutcomes = &TxOutcomes{}
+			cfg.QueriesPerConn = 4
+
+			_, txTimes, err := executeWorkerQuery(context.Background(), pool, 0, 0, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txTimes) != 4 {
+				t.Errorf("got %d transaction times, want 4", len(txTimes))
+			}
+			if cfg.TxOutcomes.Commits() != tt.wantCommits || cfg.TxOutcomes.Rollbacks() != tt.wantRollbacks {
+				t.Errorf("commits=%d rollbacks=%d, want %d and %d",
+					cfg.TxOutcomes.Commits(), cfg.TxOutcomes.Rollbacks(), tt.wantCommits, tt.wantRollbacks)
+			}
+			if pool.acquires.Load() != 1 || pool.releases.Load() != 1 {
+				t.Errorf("acquires=%d releases=%d, want 1 each", pool.acquires.Load(), pool.releases.Load())
+			}
+		})
+	}
+}
+
+func TestRunWorkerTransactionRollsBackOnError(t *testing.T) {
+	pool := newFakePooler(0)
+	tx := &fakeTx{conn: &fakeConn{pool: pool}, execErr: errors.New("deadlock detected")}
+	conn := &txConn{fakeConn: tx.conn, tx: tx}
+
+	cfg := testWorkerConfig()
+	cfg.TxOutcomes = &TxOutcomes{}
+	_, _, err := runWorkerTransaction(context.Background(), conn, slog.Default(), cfg)
+	if err == nil {
+		t.Fatal("expected the update error")
+	}
+	if tx.ended != "rollback" || cfg.TxOutcomes.Rollbacks() != 1 || cfg.TxOutcomes.Commits() != 0 {
+		t.Errorf("ended=%q commits=%d rollbacks=%d, want a single rollback",
+			tx.ended, cfg.TxOutcomes.Commits(), cfg.TxOutcomes.Rollbacks())
+	}
+}
+
+// txConn is a fakeConn that hands out a prepared transaction
+type txConn struct {
+	*fakeConn
+	tx *fakeTx
+}
+
+func (c *txConn) Begin(ctx context.Context) (pgx.Tx, error) { return c.tx, nil }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Watchdog is the deadline of a whole benchmark invocation (-watchdog). A run
+// still waiting on workers when it passes abandons them and is reported from
+// the queries that completed, and no further runs start, so a wedged worker
+// can't keep the report from being written. The zero value never expires.
+type Watchdog struct {
+	Deadline time.Time
+}
+
+// NewWatchdog starts a watchdog expiring timeout from now, or one that never
+// expires when timeout is zero
+func NewWatchdog(timeout time.Duration) Watchdog {
+	if timeout <= 0 {
+		return Watchdog{}
+	}
+	return Watchdog{Deadline: time.Now().Add(timeout)}
+}
+
+// Expired reports whether the deadline has passed
+func (w Watchdog) Expired() bool {
+	return !w.Deadline.IsZero() && !time.Now().Before(w.Deadline)
+}
+
+// WaitWorkers waits for a run's workers to return, or until the deadline
+// passes. It returns how many of the workers had not returned by then; those
+// are abandoned and keep running in the background. returned counts the
+// workers that have returned, each adding one as it does.
+func (w Watchdog) WaitWorkers(wg *sync.WaitGroup, returned *atomic.Int64, workers int) int {
+	if w.Deadline.IsZero() {
+		wg.Wait()
+		return 0
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(time.Until(w.Deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return 0
+	case <-timer.C:
+		return workers - int(returned.Load())
+	}
+}
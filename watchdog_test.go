@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stuckPooler's acquisitions never return until the test ends, like a worker
+// wedged somewhere no timeout reaches
+type stuckPooler struct {
+	*fakePooler
+	unstick chan struct{}
+}
+
+func newStuckPooler(t *testing.T) *stuckPooler {
+	p := &stuckPooler{newFakePooler(0), make(chan struct{})}
+	t.Cleanup(func() { close(p.unstick) })
+	return p
+}
+
+func (p *stuckPooler) Acquire(ctx context.Context) (PooledConn, error) {
+	<-p.unstick
+	return p.fakePooler.Acquire(ctx)
+}
+
+func TestWatchdogWaitWorkers(t *testing.T) {
+	var wg sync.WaitGroup
+	var returned atomic.Int64
+	unstick := make(chan struct{})
+	defer close(unstick)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(stuck bool) {
+			defer wg.Done()
+			defer returned.Add(1)
+			if stuck {
+				<-unstick
+			}
+		}(i == 0)
+	}
+
+	start := time.Now()
+	if abandoned := NewWatchdog(30*time.Millisecond).WaitWorkers(&wg, &returned, 3); abandoned != 1 {
+		t.Errorf("abandoned = %d, want the 1 stuck worker", abandoned)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %v past a 30ms deadline", waited)
+	}
+
+	var done sync.WaitGroup
+	var none atomic.Int64
+	if abandoned := (Watchdog{}).WaitWorkers(&done, &none, 0); abandoned != 0 {
+		t.Errorf("a watchdog without deadline abandoned %d workers", abandoned)
+	}
+	if (Watchdog{}).Expired() || NewWatchdog(0).Expired() || !NewWatchdog(time.Nanosecond).Expired() {
+		t.Error("only a watchdog with a passed deadline should be expired")
+	}
+}
+
+func TestRunBenchmarkWatchdogAbandonsStuckWorkers(t *testing.T) {
+	pools := []Pooler{newFakePooler(time.Millisecond), newStuckPooler(t)}
+	opts := Options{Seed: 1, WatchdogTimeout: 100 * time.Millisecond}
+	opts.Watchdog = NewWatchdog(opts.WatchdogTimeout)
+
+	result := runBenchmark(Config{ConnType: PgBouncerTransaction}, pools, nil, 4, false, nil, opts)
+	if result.AbandonedWorkers != 2 || result.TotalQueries != 2 {
+		t.Errorf("abandoned %d, queries %d; want the 2 workers on the stuck pool abandoned and the other 2 reported",
+			result.AbandonedWorkers, result.TotalQueries)
+	}
+	if got := renderReportMetrics(result, DefaultReportMetrics); !strings.Contains(got, "Abandoned Workers:    2 of 4") {
+		t.Errorf("report should flag the abandoned workers:\n%s", got)
+	}
+
+	// Every worker stuck still makes a (empty) result rather than a panic
+	opts.Watchdog = NewWatchdog(20 * time.Millisecond)
+	result = runBenchmark(Config{ConnType: PgBouncerTransaction}, []Pooler{newStuckPooler(t)}, nil, 2, false, nil, opts)
+	if result.AbandonedWorkers != 2 || result.TotalQueries != 0 {
+		t.Errorf("abandoned %d, queries %d; want 2 and 0", result.AbandonedWorkers, result.TotalQueries)
+	}
+}
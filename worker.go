@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WorkerQuery is the SQL every worker runs, keyed by a random benchmark_data id.
+// It also returns the backend PID that served it: PgBouncer hides the server behind
+// its own PID at connect time, and in transaction mode the server can change with
+// every query, so only the query itself can tell which backend ran it.
+const WorkerQuery = "SELECT id, name, pg_backend_pid() FROM benchmark_data WHERE id = $1"
+
+// WorkerRangeQuery is WorkerQuery returning up to $2 consecutive rows from id $1,
+// used with -rows-per-query to exercise result streaming
+const WorkerRangeQuery = "SELECT id, name, pg_backend_pid() FROM benchmark_data WHERE id >= $1 ORDER BY id LIMIT $2"
+
+// WorkerConfig holds what a worker needs to run its queries
+type WorkerConfig struct {
+	ConnType       ConnectionType
+	AcquireTimeout time.Duration // 0 waits for a connection as long as it takes
+	QueryTimeout   time.Duration // Deadline for the queries on each acquired connection; 0 for none
+	Tracer         trace.Tracer
+	Rand           *rand.Rand     // Per-worker source of query arguments
+	Args           *ArgPicker     // Draws query ids from Rand; nil picks them uniformly
+	BackendPIDs    *BackendPIDSet // Records the backend serving each query; may be nil
+	QueriesPerConn int            // Queries run on each acquired connection; values below 1 mean 1
+	RowsPerQuery   int            // Rows each query returns; values above 1 run WorkerRangeQuery
+	StreamTimes    *StreamTimes   // Records how long each query's rows took to stream; may be nil
+	ReleaseTimes   *ReleaseTimes  // Records how long each release took; may be nil
+
+	// Records each query's time by whether it ran on a new or reused backend; may be nil
+	BackendLatencies *BackendLatencies
+
+	// A replayed dispatch takes its queries from replay (-replay) instead of
+	// generating them; a recorded one appends each query it generates to
+	// recorded (-record)
+	replay   *replayQueue
+	recorded *[]TracedQuery
+
+	// Counts queries cut off by QueryTimeout and whether their connection
+	// went back to the pool; may be nil
+	Cancellations *CancelledQueries
+
+	// With Transactions, each query is an explicit transaction (see runWorkerTransaction)
+	Transactions  bool
+	RollbackRatio float64     // Fraction of transactions deliberately rolled back
+	TxOutcomes    *TxOutcomes // Counts commits and rollbacks; may be nil
+
+	// With BatchSize of 2 or more, each query is a pipelined batch of that many
+	// queries instead (see runWorkerBatch)
+	BatchSize int
+
+	// With SessionState, each acquired connection first gets session state
+	// (see applySessionState), which is checked again before release
+	SessionState string
+	StateChecks  *SessionStateChecks // Counts connections that kept their state; may be nil
+}
+
+// executeWorkerQuery acquires a connection from pool, runs WorkerQuery
+// cfg.QueriesPerConn times in sequence, draining the rows of each, and releases
+// the connection. With cfg.Transactions each query is an explicit transaction
+// instead, and with cfg.BatchSize a pipelined batch. The returned duration
+// covers acquisition and query execution, which is what the benchmark
+// measures, and queryTimes holds the execution time of each query on its own.
+// Errors carry the ErrorCategory they were classified under (see
+// errorCategory); queries cut off by cfg.QueryTimeout are ErrCancelled.
+func executeWorkerQuery(ctx context.Context, pool Pooler, workerID, poolIndex int, cfg WorkerConfig) (time.Duration, []time.Duration, error) {
+	workerLog := slog.With("worker_id", workerID, "pool_index", poolIndex, "conn_type", cfg.ConnType)
+
+	queryStart := time.Now()
+	// getGoroutineID parses a stack trace, so skip it when the record is dropped
+	if workerLog.Enabled(ctx, slog.LevelInfo) {
+		workerLog.Info("query start", "goroutine", getGoroutineID())
+	}
+
+	acquireCtx, cancelAcquire := ctx, context.CancelFunc(func() {})
+	if cfg.AcquireTimeout > 0 {
+		acquireCtx, cancelAcquire = context.WithTimeout(ctx, cfg.AcquireTimeout)
+	}
+
+	// Span: Connection acquisition, bounded by the acquire timeout when set, with
+	// the pool's state around it so a slow trace tells pool starvation apart.
+	// Stat() takes the pool's lock, so it is only sampled for recorded spans.
+	_, connSpan := cfg.Tracer.Start(ctx, AcquireSpanName)
+	var statBefore *pgxpool.Stat
+	if connSpan.IsRecording() {
+		statBefore = pool.Stat()
+		annotateAcquireStart(connSpan, statBefore)
+	}
+	acquireStart := time.Now()
+	conn, err := pool.Acquire(acquireCtx)
+	if connSpan.IsRecording() {
+		annotateAcquireEnd(connSpan, time.Since(acquireStart), statBefore, pool.Stat())
+	}
+	timedOut := errors.Is(acquireCtx.Err(), context.DeadlineExceeded)
+	cancelAcquire()
+	connSpan.End()
+
+	if err != nil {
+		if timedOut {
+			return 0, nil, &categorizedError{category: ErrAcquireTimedOut, err: fmt.Errorf("acquire: %w", err)}
+		}
+		return 0, nil, fmt.Errorf("acquire: %w", err)
+	}
+	defer conn.Release()
+
+	// The deadline covers everything run on the connection, but not acquiring it
+	queryCtx, cancelQuery := ctx, context.CancelFunc(func() {})
+	if cfg.QueryTimeout > 0 {
+		queryCtx, cancelQuery = context.WithTimeout(ctx, cfg.QueryTimeout)
+	}
+	defer cancelQuery()
+
+	// Session state only survives the queries below if the pooler pins the
+	// connection to one backend
+	stateApplied := false
+	if cfg.SessionState != "" {
+		if stateApplied, err = applySessionState(queryCtx, conn, workerID, cfg); err != nil {
+			return 0, nil, cancellationError(queryCtx, conn, fmt.Errorf("session state: %w", err), cfg)
+		}
+	}
+
+	// Every query but the last is drained before the next one starts, so the
+	// connection is held for the whole sequence
+	queries := max(cfg.QueriesPerConn, 1)
+	queryTimes := make([]time.Duration, 0, queries)
+	var rows pgx.Rows
+	var executedAt time.Time
+	for i := 0; i < queries; i++ {
+		if rows != nil {
+			rows.Close()
+			rows = nil
+		}
+
+		var servedBy uint32 // Backend PID the query returned; 0 if it returned no rows
+		start := time.Now()
+		if cfg.Transactions {
+			servedBy, executedAt, err = runWorkerTransaction(queryCtx, conn, workerLog, cfg)
+		} else if cfg.BatchSize > 1 {
+			servedBy, executedAt, err = runWorkerBatch(queryCtx, conn, workerLog, cfg)
+		} else {
+			rows, servedBy, executedAt, err = runWorkerQuery(queryCtx, conn, workerLog, cfg)
+		}
+		if err != nil {
+			return 0, queryTimes, cancellationError(queryCtx, conn, err, cfg)
+		}
+		queryTimes = append(queryTimes, executedAt.Sub(start))
+		if servedBy != 0 {
+			cfg.BackendLatencies.Add(conn, servedBy, executedAt.Sub(start))
+		}
+	}
+
+	// The check is part of what the connection was held for
+	if cfg.SessionState != "" {
+		if rows != nil {
+			rows.Close()
+			rows = nil
+		}
+		kept, err := verifySessionState(queryCtx, conn, workerID, cfg)
+		if err != nil {
+			return 0, queryTimes, cancellationError(queryCtx, conn, fmt.Errorf("session state: %w", err), cfg)
+		}
+		cfg.StateChecks.add(stateApplied && kept)
+		executedAt = time.Now()
+	}
+
+	totalDuration := executedAt.Sub(queryStart)
+	workerLog.Info("query end", "duration", totalDuration, "queries", queries)
+
+	// Span: Connection release
+	_, releaseSpan := cfg.Tracer.Start(ctx, "pool.release_connection")
+	closeStart := time.Now()
+	if rows != nil {
+		rows.Close()
+	}
+	conn.Release()
+	closeDuration := time.Since(closeStart)
+	releaseSpan.End()
+	cfg.ReleaseTimes.Add(closeDuration)
+
+	workerLog.Info("rows closed", "duration", closeDuration)
+
+	return totalDuration, queryTimes, nil
+}
+
+// runWorkerQuery runs WorkerQuery once on conn and drains its rows, returning
+// the backend PID that served it and when the query finished executing, before
+// its rows were scanned. The rows are returned still open, since closing them
+// is part of releasing the connection; on error they are already closed.
+func runWorkerQuery(ctx context.Context, conn PooledConn, workerLog *slog.Logger, cfg WorkerConfig) (pgx.Rows, uint32, time.Time, error) {
+	// Span: Query execution on the acquired connection
+	_, querySpan := cfg.Tracer.Start(ctx, "db.query")
+	sql, args := workerQuery(cfg)
+	rows, err := conn.Query(ctx, sql, args...)
+	querySpan.End()
+	executedAt := time.Now()
+
+	if err != nil {
+		return nil, 0, executedAt, fmt.Errorf("query: %w", err)
+	}
+
+	// Span: Row scanning. A scan or iteration error fails the whole query.
+	_, scanSpan := cfg.Tracer.Start(ctx, "db.scan")
+	n, pid, err := streamWorkerRows(rows, workerLog, cfg)
+	if err != nil {
+		scanSpan.RecordError(err)
+	}
+	scanSpan.SetAttributes(attribute.Int("rows", n))
+	scanSpan.End()
+
+	if err != nil {
+		rows.Close()
+		return nil, 0, executedAt, err
+	}
+
+	return rows, pid, executedAt, nil
+}
+
+// workerQuery returns the worker's next query and its arguments: the next
+// recorded one when replaying, otherwise a generated one (see generateWorkerQuery),
+// added to the dispatch's recording when recording
+func workerQuery(cfg WorkerConfig) (string, []any) {
+	if q, ok := cfg.replay.pop(); ok {
+		return q.SQL, q.args()
+	}
+	sql, args := generateWorkerQuery(cfg)
+	if cfg.recorded != nil {
+		*cfg.recorded = append(*cfg.recorded, tracedQuery(sql, args))
+	}
+	return sql, args
+}
+
+// generateWorkerQuery draws the worker's next query and its arguments: WorkerQuery
+// for one row, or WorkerRangeQuery starting where cfg.RowsPerQuery rows still fit
+func generateWorkerQuery(cfg WorkerConfig) (string, []any) {
+	args := cfg.Args
+	if args == nil {
+		args = ArgDistribution{Kind: ArgDistUniform}.Picker(cfg.Rand)
+	}
+	if cfg.RowsPerQuery <= 1 {
+		return WorkerQuery, []any{args.Next()}
+	}
+	return WorkerRangeQuery, []any{args.NextStart(cfg.RowsPerQuery), cfg.RowsPerQuery}
+}
+
+// streamWorkerRows drains the rows of a worker query, recording each backend PID
+// and how long the rows took to stream in cfg.StreamTimes. It returns how many
+// rows were read and the backend PID they came from, 0 when there were none.
+func streamWorkerRows(rows pgx.Rows, workerLog *slog.Logger, cfg WorkerConfig) (int, uint32, error) {
+	start := time.Now()
+	var servedBy uint32
+	n, err := drainRows(rows, func(rows pgx.Rows) error {
+		var id int
+		var name string
+		var pid uint32
+		if err := rows.Scan(&id, &name, &pid); err != nil {
+			return err
+		}
+		cfg.BackendPIDs.Add(pid)
+		servedBy = pid
+		workerLog.Info("query result", "id", id, "name", name, "backend_pid", pid)
+		return nil
+	})
+	if err == nil {
+		cfg.StreamTimes.Add(time.Since(start))
+	}
+	return n, servedBy, err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// NumBenchmarkRows is the number of rows in benchmark_data that queries select from
+const NumBenchmarkRows = 100
+
+// newWorkerRand returns a random source for one worker derived from the run seed.
+// Each worker gets its own source, so the sequence a worker draws is reproducible
+// no matter how goroutines are scheduled.
+func newWorkerRand(seed int64, workerID int) *rand.Rand {
+	return rand.New(rand.NewSource(seed*1000003 + int64(workerID)))
+}
+
+// nextQueryArg picks the benchmark_data id for a worker's next query
+func nextQueryArg(rng *rand.Rand) int {
+	return rng.Intn(NumBenchmarkRows) + 1
+}
+
+// Query argument distributions for -arg-dist
+const (
+	ArgDistUniform = "uniform" // Every id equally likely
+	ArgDistZipfian = "zipfian" // Id k drawn with probability proportional to 1/k^skew
+	ArgDistHotspot = "hotspot" // A share of queries on the first few ids, the rest uniform
+)
+
+// Defaults for the skewed distributions
+const (
+	DefaultZipfSkew     = 1.2
+	DefaultHotspotRows  = 0.1
+	DefaultHotspotShare = 0.9
+)
+
+// ArgDistribution describes how queries pick their benchmark_data id. Skewed
+// access concentrates reads on a few hot rows, which changes buffer-cache hit
+// rates and so query latency.
+type ArgDistribution struct {
+	Kind         string
+	ZipfSkew     float64 // Zipfian exponent; must be greater than 1
+	HotspotRows  float64 // Fraction of rows that are hot
+	HotspotShare float64 // Fraction of queries hitting the hot rows
+}
+
+func (d ArgDistribution) String() string {
+	switch d.Kind {
+	case ArgDistZipfian:
+		return fmt.Sprintf("zipfian (skew %g)", d.ZipfSkew)
+	case ArgDistHotspot:
+		return fmt.Sprintf("hotspot (%g%% of queries on %d hot rows)", d.HotspotShare*100, d.hotRows())
+	}
+	return ArgDistUniform
+}
+
+// validate checks the distribution's parameters
+func (d ArgDistribution) validate() error {
+	switch d.Kind {
+	case ArgDistUniform:
+	case ArgDistZipfian:
+		if d.ZipfSkew <= 1 {
+			return fmt.Errorf("-zipf-skew must be greater than 1")
+		}
+	case ArgDistHotspot:
+		if d.HotspotRows <= 0 || d.HotspotRows > 1 || d.HotspotShare > 1 {
+			return fmt.Errorf("-hotspot-rows must be above 0%% and -hotspot-share at most 100%%")
+		}
+	default:
+		return fmt.Errorf("-arg-dist must be %s, %s or %s", ArgDistUniform, ArgDistZipfian, ArgDistHotspot)
+	}
+	return nil
+}
+
+// hotRows is how many of the first ids are hot, at least one
+func (d ArgDistribution) hotRows() int {
+	return max(int(math.Ceil(d.HotspotRows*NumBenchmarkRows)), 1)
+}
+
+// ArgPicker draws query arguments from a distribution for one worker
+type ArgPicker struct {
+	dist ArgDistribution
+	rng  *rand.Rand
+	zipf *rand.Zipf
+}
+
+// Picker returns a picker drawing from rng, so a worker's arguments follow its seed
+func (d ArgDistribution) Picker(rng *rand.Rand) *ArgPicker {
+	p := &ArgPicker{dist: d, rng: rng}
+	if d.Kind == ArgDistZipfian {
+		p.zipf = rand.NewZipf(rng, d.ZipfSkew, 1, NumBenchmarkRows-1)
+	}
+	return p
+}
+
+// Next picks a benchmark_data id, 1 being the hottest under skewed distributions
+func (p *ArgPicker) Next() int {
+	switch p.dist.Kind {
+	case ArgDistZipfian:
+		return int(p.zipf.Uint64()) + 1
+	case ArgDistHotspot:
+		hot := p.dist.hotRows()
+		if hot < NumBenchmarkRows && p.rng.Float64() >= p.dist.HotspotShare {
+			return hot + p.rng.Intn(NumBenchmarkRows-hot) + 1
+		}
+		return p.rng.Intn(hot) + 1
+	}
+	return nextQueryArg(p.rng)
+}
+
+// NextStart picks the first id of a range of n consecutive rows. Skewed picks
+// are moved back where needed so the whole range fits.
+func (p *ArgPicker) NextStart(n int) int {
+	last := max(NumBenchmarkRows-n+1, 1)
+	if p.dist.Kind == ArgDistUniform || p.dist.Kind == "" {
+		return p.rng.Intn(last) + 1
+	}
+	return min(p.Next(), last)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+)
+
+// drawCounts draws n ids from dist with a fixed seed and counts each id
+func drawCounts(dist ArgDistribution, n int) map[int]int {
+	picker := dist.Picker(newWorkerRand(1, 0))
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		counts[picker.Next()]++
+	}
+	return counts
+}
+
+func TestArgPickerUniformMatchesDefaultWorkload(t *testing.T) {
+	picker := ArgDistribution{Kind: ArgDistUniform}.Picker(newWorkerRand(7, 3))
+	rng := newWorkerRand(7, 3)
+	for i := 0; i < 100; i++ {
+		if got, want := picker.Next(), nextQueryArg(rng); got != want {
+			t.Fatalf("draw %d = %d, want %d as without a distribution", i, got, want)
+		}
+	}
+}
+
+func TestArgPickerZipfianFavorsLowIds(t *testing.T) {
+	const n = 20000
+	counts := drawCounts(ArgDistribution{Kind: ArgDistZipfian, ZipfSkew: 1.2}, n)
+
+	for id := range counts {
+		if id < 1 || id > NumBenchmarkRows {
+			t.Fatalf("id %d outside 1..%d", id, NumBenchmarkRows)
+		}
+	}
+	if !(counts[1] > counts[2] && counts[2] > counts[10] && counts[10] > counts[NumBenchmarkRows]) {
+		t.Errorf("counts not decreasing with id: 1=%d 2=%d 10=%d %d=%d",
+			counts[1], counts[2], counts[10], NumBenchmarkRows, counts[NumBenchmarkRows])
+	}
+	// Uniform would give id 1 about 1% of queries
+	if share := float64(counts[1]) / n; share < 0.2 {
+		t.Errorf("hottest id got %.1f%% of queries, want a heavy skew", share*100)
+	}
+}
+
+func TestArgPickerHotspotShare(t *testing.T) {
+	const n = 20000
+	dist := ArgDistribution{Kind: ArgDistHotspot, HotspotRows: 0.1, HotspotShare: 0.9}
+	counts := drawCounts(dist, n)
+
+	hot := 0
+	for id, count := range counts {
+		if id <= 10 {
+			hot += count
+		}
+	}
+	if share := float64(hot) / n; share < 0.88 || share > 0.92 {
+		t.Errorf("hot rows got %.1f%% of queries, want about 90%%", share*100)
+	}
+	if counts[NumBenchmarkRows] == 0 {
+		t.Error("cold rows were never queried")
+	}
+}
+
+func TestArgPickerNextStartFitsRange(t *testing.T) {
+	for _, kind := range []string{ArgDistUniform, ArgDistZipfian, ArgDistHotspot} {
+		dist := ArgDistribution{Kind: kind, ZipfSkew: 1.2, HotspotRows: 0.5, HotspotShare: 0.5}
+		picker := dist.Picker(newWorkerRand(1, 0))
+		for i := 0; i < 1000; i++ {
+			if start := picker.NextStart(30); start < 1 || start+30-1 > NumBenchmarkRows {
+				t.Fatalf("%s: range starts at %d, so 30 rows don't fit in %d", kind, start, NumBenchmarkRows)
+			}
+		}
+	}
+}
+
+func TestParseOptionsArgDistribution(t *testing.T) {
+	opts, err := parseOptions([]string{"-arg-dist", "hotspot", "-hotspot-rows", "5%", "-hotspot-share", "80%"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ArgDist.Kind != ArgDistHotspot || opts.ArgDist.HotspotRows != 0.05 || opts.ArgDist.HotspotShare != 0.8 {
+		t.Errorf("ArgDist = %+v", opts.ArgDist)
+	}
+
+	for _, args := range [][]string{
+		{"-arg-dist", "pareto"},
+		{"-arg-dist", "zipfian", "-zipf-skew", "1"},
+		{"-arg-dist", "hotspot", "-hotspot-rows", "0%"},
+	} {
+		if _, err := parseOptions(args); err == nil {
+			t.Errorf("parseOptions(%q) succeeded, want an error", args)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WorkloadHeader holds the options that decide how many queries each dispatch
+// runs and of what shape. A trace only replays under the same ones.
+type WorkloadHeader struct {
+	QueriesPerConn int  `json:"queries_per_conn"`
+	BatchSize      int  `json:"batch_size"`
+	RowsPerQuery   int  `json:"rows_per_query"`
+	Transactions   bool `json:"transactions"`
+}
+
+// workloadHeader is the header of the workload opts generates
+func workloadHeader(opts Options) WorkloadHeader {
+	return WorkloadHeader{
+		QueriesPerConn: max(opts.QueriesPerConn, 1),
+		BatchSize:      max(opts.BatchSize, 1),
+		RowsPerQuery:   max(opts.RowsPerQuery, 1),
+		Transactions:   opts.Transactions,
+	}
+}
+
+// check returns an error naming the first option in which h, a recorded
+// header, differs from the run's
+func (h WorkloadHeader) check(run WorkloadHeader) error {
+	for _, f := range []struct {
+		flag          string
+		recorded, now any
+	}{
+		{"-queries-per-conn", h.QueriesPerConn, run.QueriesPerConn},
+		{"-batch-size", h.BatchSize, run.BatchSize},
+		{"-rows-per-query", h.RowsPerQuery, run.RowsPerQuery},
+		{"-transactions", h.Transactions, run.Transactions},
+	} {
+		if f.recorded != f.now {
+			return fmt.Errorf("recorded with %s %v, this run uses %v", f.flag, f.recorded, f.now)
+		}
+	}
+	return nil
+}
+
+// TracedQuery is one statement a worker ran, with its arguments
+type TracedQuery struct {
+	SQL  string  `json:"sql"`
+	Args []int64 `json:"args"`
+}
+
+func (q TracedQuery) args() []any {
+	args := make([]any, len(q.Args))
+	for i, a := range q.Args {
+		args[i] = int(a)
+	}
+	return args
+}
+
+// TracedDispatch is one connection acquisition by a worker: when it was
+// dispatched, as an offset from the start of the run, and the queries it ran
+type TracedDispatch struct {
+	Concurrency int           `json:"concurrency"`
+	Worker      int           `json:"worker"`
+	OffsetNs    int64         `json:"offset_ns"`
+	Queries     []TracedQuery `json:"queries"`
+}
+
+// Offset is when the dispatch started, from the start of its run
+func (d TracedDispatch) Offset() time.Duration {
+	return time.Duration(d.OffsetNs)
+}
+
+// traceHeaderLine is the first line of a workload trace file
+type traceHeaderLine struct {
+	Header *WorkloadHeader `json:"header"`
+}
+
+// WorkloadRecorder writes the workload of a benchmark's runs to a trace file
+// (-record): a header line, then one JSON line per dispatch. Only the first
+// measured run at each concurrency level is recorded; it is safe for concurrent
+// use, and a nil recorder records nothing.
+type WorkloadRecorder struct {
+	mu       sync.Mutex
+	f        *os.File
+	w        *bufio.Writer
+	enc      *json.Encoder
+	recorded map[int]bool
+	err      error
+}
+
+// CreateWorkloadRecorder creates filename and writes header to it
+func CreateWorkloadRecorder(filename string, header WorkloadHeader) (*WorkloadRecorder, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workload trace: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	r := &WorkloadRecorder{f: f, w: w, enc: json.NewEncoder(w), recorded: make(map[int]bool)}
+	if err := r.enc.Encode(traceHeaderLine{Header: &header}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write workload trace: %w", err)
+	}
+	return r, nil
+}
+
+// Run starts recording a run at concurrency, or returns nil when a run at
+// that level was already recorded
+func (r *WorkloadRecorder) Run(concurrency int) *RunRecording {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recorded[concurrency] {
+		return nil
+	}
+	r.recorded[concurrency] = true
+	return &RunRecording{concurrency: concurrency, workers: make(map[int][]TracedDispatch)}
+}
+
+// Save writes a finished run's dispatches, worker by worker in dispatch order
+func (r *WorkloadRecorder) Save(run *RunRecording) {
+	if r == nil || run == nil {
+		return
+	}
+	dispatches := run.Dispatches()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range dispatches {
+		if r.err != nil {
+			return
+		}
+		r.err = r.enc.Encode(d)
+	}
+}
+
+// Close flushes the trace and closes the file
+func (r *WorkloadRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.err
+	if flushErr := r.w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := r.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write workload trace: %w", err)
+	}
+	return nil
+}
+
+// RunRecording collects the dispatches of one recorded run. It is safe for
+// concurrent use; a nil recording ignores additions.
+type RunRecording struct {
+	concurrency int
+	mu          sync.Mutex
+	workers     map[int][]TracedDispatch
+}
+
+// Add records a dispatch of worker at offset from the run's start
+func (rr *RunRecording) Add(worker int, offset time.Duration, queries []TracedQuery) {
+	if rr == nil {
+		return
+	}
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.workers[worker] = append(rr.workers[worker], TracedDispatch{
+		Concurrency: rr.concurrency, Worker: worker, OffsetNs: offset.Nanoseconds(), Queries: queries,
+	})
+}
+
+// Dispatches returns the recorded dispatches in worker order
+func (rr *RunRecording) Dispatches() []TracedDispatch {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	workers := make([]int, 0, len(rr.workers))
+	for w := range rr.workers {
+		workers = append(workers, w)
+	}
+	sort.Ints(workers)
+	var dispatches []TracedDispatch
+	for _, w := range workers {
+		dispatches = append(dispatches, rr.workers[w]...)
+	}
+	return dispatches
+}
+
+// WorkloadTrace is a trace file loaded for -replay
+type WorkloadTrace struct {
+	Header WorkloadHeader
+	runs   map[int]map[int][]TracedDispatch // By concurrency, then worker
+}
+
+// LoadWorkloadTrace reads a trace written by -record
+func LoadWorkloadTrace(filename string) (*WorkloadTrace, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workload trace: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var header traceHeaderLine
+	if err := dec.Decode(&header); err != nil || header.Header == nil {
+		return nil, fmt.Errorf("%s: not a workload trace, the first line should be its header", filename)
+	}
+	t := &WorkloadTrace{Header: *header.Header, runs: make(map[int]map[int][]TracedDispatch)}
+	for line := 2; dec.More(); line++ {
+		var d TracedDispatch
+		if err := dec.Decode(&d); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", filename, line, err)
+		}
+		if t.runs[d.Concurrency] == nil {
+			t.runs[d.Concurrency] = make(map[int][]TracedDispatch)
+		}
+		t.runs[d.Concurrency][d.Worker] = append(t.runs[d.Concurrency][d.Worker], d)
+	}
+	return t, nil
+}
+
+// Run returns the recorded dispatches of each worker at concurrency, or nil
+// when no run at that level was recorded
+func (t *WorkloadTrace) Run(concurrency int) map[int][]TracedDispatch {
+	if t == nil {
+		return nil
+	}
+	return t.runs[concurrency]
+}
+
+// Levels lists the recorded concurrency levels in ascending order
+func (t *WorkloadTrace) Levels() []int {
+	levels := make([]int, 0, len(t.runs))
+	for c := range t.runs {
+		levels = append(levels, c)
+	}
+	sort.Ints(levels)
+	return levels
+}
+
+// replayQueue hands out the queries of one replayed dispatch in order
+type replayQueue struct {
+	queries []TracedQuery
+	next    int
+}
+
+// pop returns the next recorded query, or false once they have all run
+func (q *replayQueue) pop() (TracedQuery, bool) {
+	if q == nil || q.next >= len(q.queries) {
+		return TracedQuery{}, false
+	}
+	q.next++
+	return q.queries[q.next-1], true
+}
+
+// tracedQuery converts a generated query for the trace. Worker query
+// arguments are all integers.
+func tracedQuery(sql string, args []any) TracedQuery {
+	q := TracedQuery{SQL: sql, Args: make([]int64, len(args))}
+	for i, a := range args {
+		if n, ok := a.(int); ok {
+			q.Args[i] = int64(n)
+		}
+	}
+	return q
+}
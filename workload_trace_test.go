@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// argsPooler wraps a fakePooler to log the first argument of every query
+type argsPooler struct {
+	*fakePooler
+	mu   sync.Mutex
+	args []int
+}
+
+func (p *argsPooler) Acquire(ctx context.Context) (PooledConn, error) {
+	conn, err := p.fakePooler.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &argsConn{conn.(*fakeConn), p}, nil
+}
+
+// sortedArgs returns the logged arguments in ascending order, as workers
+// interleave differently from run to run
+func (p *argsPooler) sortedArgs() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Sorted(slices.Values(p.args))
+}
+
+type argsConn struct {
+	*fakeConn
+	pool *argsPooler
+}
+
+func (c *argsConn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	c.pool.mu.Lock()
+	c.pool.args = append(c.pool.args, args[0].(int))
+	c.pool.mu.Unlock()
+	return c.fakeConn.Query(ctx, sql, args...)
+}
+
+func TestWorkloadRecorderRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "workload.ndjson")
+	header := WorkloadHeader{QueriesPerConn: 2, BatchSize: 1, RowsPerQuery: 1}
+	recorder, err := CreateWorkloadRecorder(filename, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := recorder.Run(4)
+	if recorder.Run(4) != nil {
+		t.Error("only the first run at a level should be recorded")
+	}
+	run.Add(1, 5*time.Millisecond, []TracedQuery{{SQL: WorkerQuery, Args: []int64{7}}})
+	run.Add(0, 0, []TracedQuery{{SQL: WorkerQuery, Args: []int64{3}}})
+	run.Add(1, 9*time.Millisecond, []TracedQuery{{SQL: WorkerQuery, Args: []int64{8}}})
+	recorder.Save(run)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	trace, err := LoadWorkloadTrace(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Header != header || !slices.Equal(trace.Levels(), []int{4}) || trace.Run(8) != nil {
+		t.Errorf("header %+v, levels %v", trace.Header, trace.Levels())
+	}
+	worker1 := trace.Run(4)[1]
+	if len(worker1) != 2 || worker1[0].Offset() != 5*time.Millisecond || worker1[1].Queries[0].Args[0] != 8 {
+		t.Errorf("worker 1 dispatches = %+v, want both in dispatch order", worker1)
+	}
+
+	if err := header.check(WorkloadHeader{QueriesPerConn: 1, BatchSize: 1, RowsPerQuery: 1}); err == nil ||
+		!strings.Contains(err.Error(), "-queries-per-conn 2") {
+		t.Errorf("check = %v, want the mismatched -queries-per-conn named", err)
+	}
+}
+
+func TestRunBenchmarkReplaysRecordedWorkload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "workload.ndjson")
+	recorded := &argsPooler{fakePooler: newFakePooler(0)}
+	opts := Options{Seed: 1, QueriesPerConn: 3}
+	recorder, err := CreateWorkloadRecorder(filename, workloadHeader(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Recorder = recorder
+	runBenchmark(Config{ConnType: PgBouncerSession}, []Pooler{recorded}, nil, 4, true, nil, opts)
+	runBenchmark(Config{ConnType: PgBouncerSession}, []Pooler{recorded}, nil, 4, false, nil, opts)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another seed and connection type replay the recorded queries exactly,
+	// the measured run's and not the warmup's
+	trace, err := LoadWorkloadTrace(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := &argsPooler{fakePooler: newFakePooler(0)}
+	opts = Options{Seed: 99, QueriesPerConn: 3, ReplayTrace: trace}
+	result := runBenchmark(Config{ConnType: PgBouncerTransaction}, []Pooler{replayed}, nil, 4, false, nil, opts)
+
+	recorded.args = recorded.args[12:] // The warmup ran first
+	want := recorded.sortedArgs()
+	if got := replayed.sortedArgs(); !slices.Equal(got, want) || len(got) != 12 {
+		t.Errorf("replayed args %v, want the measured run's %v", got, want)
+	}
+	if !result.Replayed || result.TotalQueries != 4 {
+		t.Errorf("Replayed = %v, TotalQueries = %d; want a replayed run of 4 dispatches", result.Replayed, result.TotalQueries)
+	}
+}
+
+func TestRunBenchmarkReplayKeepsDispatchTimes(t *testing.T) {
+	trace := &WorkloadTrace{
+		Header: WorkloadHeader{QueriesPerConn: 1, BatchSize: 1, RowsPerQuery: 1},
+		runs: map[int]map[int][]TracedDispatch{2: {
+			0: {{Concurrency: 2, Worker: 0, Queries: []TracedQuery{{SQL: WorkerQuery, Args: []int64{1}}}}},
+			1: {{Concurrency: 2, Worker: 1, OffsetNs: (60 * time.Millisecond).Nanoseconds(), Queries: []TracedQuery{{SQL: WorkerQuery, Args: []int64{2}}}}},
+		}},
+	}
+	pool := &argsPooler{fakePooler: newFakePooler(0)}
+	result := runBenchmark(Config{ConnType: PgBouncerSession}, []Pooler{pool}, nil, 2, false, nil, Options{Seed: 1, ReplayTrace: trace})
+	if result.TotalDuration < 60*time.Millisecond {
+		t.Errorf("run took %v, want worker 1 dispatched at its recorded 60ms", result.TotalDuration)
+	}
+	if got := pool.sortedArgs(); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("args = %v, want the recorded [1 2]", got)
+	}
+}
+
+func TestRunBenchmarkReplayCountsLagBehindSchedule(t *testing.T) {
+	query := []TracedQuery{{SQL: WorkerQuery, Args: []int64{1}}}
+	trace := &WorkloadTrace{
+		Header: WorkloadHeader{QueriesPerConn: 1, BatchSize: 1, RowsPerQuery: 1},
+		runs: map[int]map[int][]TracedDispatch{1: {0: {
+			{Concurrency: 1, Queries: query},
+			{Concurrency: 1, OffsetNs: (5 * time.Millisecond).Nanoseconds(), Queries: query},
+		}}},
+	}
+	// The first dispatch holds the only worker well past the second's offset
+	pool := newFakePooler(30 * time.Millisecond)
+	result := runBenchmark(Config{ConnType: PgBouncerSession}, []Pooler{pool}, nil, 1, false, nil, Options{Seed: 1, ReplayTrace: trace})
+
+	if result.MaxQueueWait < 25*time.Millisecond {
+		t.Errorf("MaxQueueWait = %v, want the second dispatch's lag of at least 25ms", result.MaxQueueWait)
+	}
+	if late := slices.Max(result.AcquisitionTimes); late < 55*time.Millisecond {
+		t.Errorf("slowest query = %v, want it measured from its recorded dispatch (at least 55ms)", late)
+	}
+}